@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/apps/allinone"
+	"github.com/ASsssker/proxy/internal/config"
+)
+
+func main() {
+	cfg := config.MustLoad()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	app := allinone.MustNewAllInOneApp(ctx, cfg)
+	go app.MustRun(ctx)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+
+	<-stop
+
+	// Запас сверх времени ожидания задач нужен на возврат прерванных задач и закрытие соединений.
+	ctx, cancel = context.WithTimeout(context.Background(), cfg.RequesterDrainGracePeriod()+time.Second*10)
+	defer cancel()
+
+	app.Stop(ctx)
+}
@@ -25,7 +25,8 @@ func main() {
 
 	<-stop
 
-	ctx, cancel = context.WithTimeout(context.Background(), time.Second*10)
+	// Запас сверх времени ожидания задач нужен на возврат прерванных задач и закрытие соединений.
+	ctx, cancel = context.WithTimeout(context.Background(), cfg.RequesterDrainGracePeriod()+time.Second*10)
 	defer cancel()
 
 	app.Stop(ctx)
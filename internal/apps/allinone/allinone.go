@@ -0,0 +1,156 @@
+package allinone
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/ASsssker/proxy/internal/apps/requester"
+	"github.com/ASsssker/proxy/internal/config"
+	prom "github.com/ASsssker/proxy/internal/monitoring/prometheus"
+	"github.com/ASsssker/proxy/internal/mq"
+	"github.com/ASsssker/proxy/internal/netpolicy"
+	v1 "github.com/ASsssker/proxy/internal/rest/v1"
+	"github.com/ASsssker/proxy/internal/services"
+	"github.com/ASsssker/proxy/internal/storage/blob"
+	"github.com/ASsssker/proxy/internal/storage/memory"
+	"github.com/ASsssker/proxy/internal/validation"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	envLocal = "local"
+	envDev   = "dev"
+	envProd  = "prod"
+)
+
+const (
+	defaultPort         = "8080"
+	defaultWorkersCount = 10
+)
+
+// AllInOneApp запускает proxy и requester в одном процессе поверх хранилища и брокера в памяти.
+// Задачи теряются при перезапуске, поэтому режим предназначен для локальной разработки и демонстраций.
+type AllInOneApp struct {
+	log       *slog.Logger
+	proxy     *services.ProxyService
+	requester *services.RequesterService
+	srv       *http.Server
+}
+
+func MustNewAllInOneApp(ctx context.Context, cfg config.Config) *AllInOneApp {
+	if cfg.ProxyPort == "" {
+		cfg.ProxyPort = defaultPort
+	}
+	if cfg.RequesterWorkersCount == 0 {
+		cfg.RequesterWorkersCount = defaultWorkersCount
+	}
+	cfg.MQDriver = mq.DriverMemory
+
+	log := setupLogger(cfg.Env)
+	log.InfoContext(ctx, "starting all-in-one service", slog.String("env", cfg.Env))
+	log.DebugContext(ctx, "credential all-in-one service", slog.String("host", cfg.ProxyHost),
+		slog.String("port", cfg.ProxyPort))
+
+	blobStore, err := blob.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	db := memory.NewMemoryDB(log, blobStore)
+	broker := mq.NewMemoryMQ(log)
+
+	validator, err := validation.NewValidator()
+	if err != nil {
+		panic(err)
+	}
+
+	policy, err := netpolicy.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	proxyService := services.NewProxyService(log, cfg, db, broker, validator)
+
+	taskExecutor := services.NewRequestExecutor(cfg, log, blobStore, policy)
+	callbackSender := services.NewCallbackService(cfg, log, policy)
+	requesterService := services.NewRequesterService(log, cfg, db, broker, taskExecutor, callbackSender)
+
+	if cfg.Env == envProd {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	handler := gin.Default()
+	prom.MustRegisterAllMetrics(handler)
+	requester.RegisterReadiness(handler, requesterService)
+	v1.Register(handler, log, proxyService)
+
+	return &AllInOneApp{
+		log:       log,
+		proxy:     proxyService,
+		requester: requesterService,
+		srv: &http.Server{
+			Handler:      handler,
+			Addr:         net.JoinHostPort(cfg.ProxyHost, cfg.ProxyPort),
+			ReadTimeout:  cfg.ProxyHTTPReadTimeout,
+			WriteTimeout: cfg.ProxyHTTPWriteTimeout,
+			IdleTimeout:  cfg.ProxyHTTPIdleTimeout,
+		},
+	}
+}
+
+func (a *AllInOneApp) MustRun(ctx context.Context) {
+	go func() {
+		if err := a.requester.Run(ctx); err != nil {
+			a.log.ErrorContext(ctx, "failed to run requester service", slog.String("error", err.Error()))
+		}
+	}()
+
+	go func() {
+		if err := a.proxy.Run(ctx); err != nil {
+			a.log.ErrorContext(ctx, "failed to run proxy service", slog.String("error", err.Error()))
+		}
+	}()
+
+	if err := a.srv.ListenAndServe(); err != nil {
+		if !errors.Is(err, http.ErrServerClosed) {
+			a.log.ErrorContext(ctx, "failed to run http server", slog.String("error", err.Error()))
+			panic(err)
+		}
+	}
+}
+
+// Stop сначала перестаёт принимать запросы, затем дожидается выполняемых задач и только после этого
+// останавливает proxy, чтобы ожидающие клиенты успели получить результат.
+func (a *AllInOneApp) Stop(ctx context.Context) {
+	a.log.InfoContext(ctx, "start stopping server")
+	if err := a.srv.Shutdown(ctx); err != nil {
+		a.log.ErrorContext(ctx, "failed to stopping server", slog.String("error", err.Error()))
+	}
+
+	if err := a.requester.Close(ctx); err != nil {
+		a.log.ErrorContext(ctx, "failed to stopping requester service", slog.String("error", err.Error()))
+	}
+
+	if err := a.proxy.Close(ctx); err != nil {
+		a.log.ErrorContext(ctx, "failed to stopping proxy service", slog.String("error", err.Error()))
+	}
+}
+
+func setupLogger(env string) *slog.Logger {
+	var log *slog.Logger
+	switch env {
+	case envLocal:
+		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	case envDev:
+		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	case envProd:
+		log = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	default:
+		log = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	}
+	return log
+}
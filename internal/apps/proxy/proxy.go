@@ -13,6 +13,7 @@ import (
 	"github.com/ASsssker/proxy/internal/mq"
 	v1 "github.com/ASsssker/proxy/internal/rest/v1"
 	"github.com/ASsssker/proxy/internal/services"
+	"github.com/ASsssker/proxy/internal/storage/blob"
 	"github.com/ASsssker/proxy/internal/storage/postgres"
 	"github.com/ASsssker/proxy/internal/validation"
 	"github.com/gin-gonic/gin"
@@ -35,24 +36,29 @@ func MustNewProxyApp(ctx context.Context, cfg config.Config) ProxyApp {
 	log.InfoContext(ctx, "starting proxy service", slog.String("env", cfg.Env))
 	log.DebugContext(ctx, "credential proxy service", slog.String("host", cfg.ProxyHost), slog.String("port", cfg.ProxyPort))
 
-	taskProvider, err := postgres.NewPostgresDB(ctx, log, cfg.PostgresDNS())
+	blobStore, err := blob.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	taskProvider, err := postgres.NewPostgresDB(ctx, log, cfg.PostgresDNS(), blobStore)
 	if err != nil {
 		panic(err)
 	}
 	log.InfoContext(ctx, "successful connection to the database")
 
-	msgSender, err := mq.NewNatsMQ(cfg, log)
+	msgSender, err := mq.New(cfg, log)
 	if err != nil {
 		panic(err)
 	}
-	log.InfoContext(ctx, "successful connection to the mq")
+	log.InfoContext(ctx, "successful connection to the mq", slog.String("driver", cfg.MQDriver))
 
 	validator, err := validation.NewValidator()
 	if err != nil {
 		panic(err)
 	}
 
-	service := services.NewProxyService(log, taskProvider, msgSender, validator)
+	service := services.NewProxyService(log, cfg, taskProvider, msgSender, validator)
 
 	if cfg.Env == envProd {
 		gin.SetMode(gin.ReleaseMode)
@@ -78,6 +84,12 @@ func MustNewProxyApp(ctx context.Context, cfg config.Config) ProxyApp {
 }
 
 func (p ProxyApp) MustRun(ctx context.Context) {
+	go func() {
+		if err := p.service.Run(ctx); err != nil {
+			p.log.ErrorContext(ctx, "failed to run proxy service", slog.String("error", err.Error()))
+		}
+	}()
+
 	if err := p.srv.ListenAndServe(); err != nil {
 		if !errors.Is(err, http.ErrServerClosed) {
 			p.log.ErrorContext(ctx, "failed to run http server", slog.String("error", err.Error()))
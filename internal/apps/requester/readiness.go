@@ -0,0 +1,20 @@
+package requester
+
+import (
+	"net/http"
+
+	"github.com/ASsssker/proxy/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterReadiness(handler *gin.Engine, service *services.RequesterService) {
+	handler.GET("/readyz", func(ctx *gin.Context) {
+		state := service.State()
+		status := http.StatusOK
+		if state != services.RequesterRunning {
+			status = http.StatusServiceUnavailable
+		}
+
+		ctx.JSON(status, gin.H{"status": state})
+	})
+}
@@ -11,7 +11,9 @@ import (
 	"github.com/ASsssker/proxy/internal/config"
 	prom "github.com/ASsssker/proxy/internal/monitoring/prometheus"
 	"github.com/ASsssker/proxy/internal/mq"
+	"github.com/ASsssker/proxy/internal/netpolicy"
 	"github.com/ASsssker/proxy/internal/services"
+	"github.com/ASsssker/proxy/internal/storage/blob"
 	"github.com/ASsssker/proxy/internal/storage/postgres"
 	"github.com/gin-gonic/gin"
 )
@@ -32,23 +34,35 @@ func MustNewRequester(ctx context.Context, cfg config.Config) *RequesterApp {
 	log := setupLogger(cfg.Env)
 	log.InfoContext(ctx, "starting requester service", slog.String("env", cfg.Env))
 
-	taskUpdater, err := postgres.NewPostgresDB(ctx, log, cfg.PostgresDNS())
+	blobStore, err := blob.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	taskUpdater, err := postgres.NewPostgresDB(ctx, log, cfg.PostgresDNS(), blobStore)
 	if err != nil {
 		panic(err)
 	}
 	log.InfoContext(ctx, "successful connection to the database")
 
-	msgReceiver, err := mq.NewNatsMQ(cfg, log)
+	msgReceiver, err := mq.New(cfg, log)
+	if err != nil {
+		panic(err)
+	}
+	log.InfoContext(ctx, "successful connection to the mq", slog.String("driver", cfg.MQDriver))
+
+	policy, err := netpolicy.New(cfg)
 	if err != nil {
 		panic(err)
 	}
-	log.InfoContext(ctx, "successful connection to the mq")
 
-	taskExecutor := services.NewRequestExecutor(cfg, log)
-	service := services.NewRequesterService(log, cfg, taskUpdater, msgReceiver, taskExecutor)
+	taskExecutor := services.NewRequestExecutor(cfg, log, blobStore, policy)
+	callbackSender := services.NewCallbackService(cfg, log, policy)
+	service := services.NewRequesterService(log, cfg, taskUpdater, msgReceiver, taskExecutor, callbackSender)
 
 	handler := gin.Default()
 	prom.MustRegisterRequesterMetrics(handler)
+	RegisterReadiness(handler, service)
 
 	return &RequesterApp{
 		log:     log,
@@ -8,11 +8,22 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
+const (
+	defaultRequesterDrainTimeout     = 20 * time.Second
+	defaultRequesterMaxResponseBytes = 100 << 20
+
+	defaultProxyBatchMaxSize = 500
+	// maxProxyBatchSize не даёт многострочной вставке пачки выйти за предел числа параметров запроса postgres.
+	maxProxyBatchSize = 5000
+)
+
 type Config struct {
 	Env string `env:"ENV"`
 	ProxyServiceConfig
 	RequesterServiceConfig
 	PostgresConfig
+	BlobStoreConfig
+	MQConfig
 	RabbitMQConfig
 	NatsMQCOnfig
 }
@@ -23,14 +34,93 @@ type ProxyServiceConfig struct {
 	ProxyHTTPReadTimeout  time.Duration `env:"PROXY_HTTP_READ_TIMEOUT"`
 	ProxyHTTPWriteTimeout time.Duration `env:"PROXY_HTTP_WRITE_TIMEOUT"`
 	ProxyHTTPIdleTimeout  time.Duration `env:"PROXY_HTTP_IDLE_TIMEOUT"`
+	ProxyMaxWaitTimeout   time.Duration `env:"PROXY_MAX_WAIT_TIMEOUT"`
+
+	ProxyOutboxPollInterval time.Duration `env:"PROXY_OUTBOX_POLL_INTERVAL"`
+	ProxyOutboxBatchSize    int           `env:"PROXY_OUTBOX_BATCH_SIZE"`
+	ProxyOutboxMaxAttempts  int           `env:"PROXY_OUTBOX_MAX_ATTEMPTS"`
+
+	ProxySchedulerInterval  time.Duration `env:"PROXY_SCHEDULER_INTERVAL"`
+	ProxySchedulerBatchSize int           `env:"PROXY_SCHEDULER_BATCH_SIZE"`
+
+	ProxyIdempotencyKeyTTL        time.Duration `env:"PROXY_IDEMPOTENCY_KEY_TTL"`
+	ProxyIdempotencySweepInterval time.Duration `env:"PROXY_IDEMPOTENCY_SWEEP_INTERVAL"`
+	ProxyBatchMaxSize             int           `env:"PROXY_BATCH_MAX_SIZE"`
+}
+
+func (p ProxyServiceConfig) ProxyBatchLimit() int {
+	if p.ProxyBatchMaxSize <= 0 {
+		return defaultProxyBatchMaxSize
+	}
+
+	return min(p.ProxyBatchMaxSize, maxProxyBatchSize)
 }
 
 type RequesterServiceConfig struct {
 	RequesterWorkersCount      uint          `env:"REQUESTER_WORKERS_COUNT"`
+	RequesterQueueSize         uint          `env:"REQUESTER_QUEUE_SIZE"`
 	RequesterHTTPClientTimeout time.Duration `env:"REQUESTER_HTTP_CLIENT_TIMEOUT"`
 	RequesterRetryCount        uint          `env:"REQUESTER_RETRY_COUNT"`
+	RequesterRetryBaseDelay    time.Duration `env:"REQUESTER_RETRY_BASE_DELAY"`
+	RequesterRetryMaxDelay     time.Duration `env:"REQUESTER_RETRY_MAX_DELAY"`
 	RequesterMetricHost        string        `env:"REQUESTER_METRIC_HOST"`
 	RequesterMetricPort        string        `env:"REQUESTER_METRIC_PORT"`
+	RequesterBlobThreshold     int64         `env:"REQUESTER_BLOB_THRESHOLD"`
+	RequesterMaxResponseBytes  int64         `env:"REQUESTER_MAX_RESPONSE_BYTES"`
+
+	RequesterWorkerID       string        `env:"REQUESTER_WORKER_ID"`
+	RequesterLeaseTTL       time.Duration `env:"REQUESTER_LEASE_TTL"`
+	RequesterReaperInterval time.Duration `env:"REQUESTER_REAPER_INTERVAL"`
+	RequesterDrainTimeout   time.Duration `env:"REQUESTER_DRAIN_TIMEOUT"`
+
+	RequesterDenyCIDRs    []string `env:"REQUESTER_DENY_CIDRS"`
+	RequesterAllowCIDRs   []string `env:"REQUESTER_ALLOW_CIDRS"`
+	RequesterDenyHosts    []string `env:"REQUESTER_DENY_HOSTS"`
+	RequesterAllowHosts   []string `env:"REQUESTER_ALLOW_HOSTS"`
+	RequesterBlockedPorts []int    `env:"REQUESTER_BLOCKED_PORTS"`
+
+	RequesterCallbackTimeout    time.Duration `env:"REQUESTER_CALLBACK_TIMEOUT"`
+	RequesterCallbackRetryCount uint          `env:"REQUESTER_CALLBACK_RETRY_COUNT"`
+	RequesterCallbackBackoff    time.Duration `env:"REQUESTER_CALLBACK_BACKOFF"`
+	RequesterCallbackSecret     string        `env:"REQUESTER_CALLBACK_SECRET"`
+}
+
+// RequesterQueueCapacity возвращает размер очереди задач, ожидающих свободного воркера. По умолчанию он равен
+// числу воркеров.
+func (r RequesterServiceConfig) RequesterQueueCapacity() int {
+	if r.RequesterQueueSize > 0 {
+		return int(r.RequesterQueueSize)
+	}
+
+	return int(r.RequesterWorkersCount)
+}
+
+// RequesterPrefetch возвращает число задач, которые requester может держать одновременно: выполняемые
+// и ожидающие в очереди. Брокеру не стоит выдавать requester больше неподтверждённых сообщений.
+func (r RequesterServiceConfig) RequesterPrefetch() int {
+	return int(r.RequesterWorkersCount) + r.RequesterQueueCapacity()
+}
+
+// RequesterDrainGracePeriod возвращает время, которое requester при остановке ждёт завершения выполняемых задач.
+func (r RequesterServiceConfig) RequesterDrainGracePeriod() time.Duration {
+	if r.RequesterDrainTimeout > 0 {
+		return r.RequesterDrainTimeout
+	}
+
+	return defaultRequesterDrainTimeout
+}
+
+// RequesterResponseLimit возвращает глобальный лимит размера тела ответа. Без REQUESTER_MAX_RESPONSE_BYTES
+// действует лимит по умолчанию, отрицательное значение снимает ограничение.
+func (r RequesterServiceConfig) RequesterResponseLimit() int64 {
+	switch {
+	case r.RequesterMaxResponseBytes == 0:
+		return defaultRequesterMaxResponseBytes
+	case r.RequesterMaxResponseBytes < 0:
+		return 0
+	default:
+		return r.RequesterMaxResponseBytes
+	}
 }
 
 type PostgresConfig struct {
@@ -53,6 +143,21 @@ func (p PostgresConfig) PostgresDNS() string {
 	return u.String()
 }
 
+type BlobStoreConfig struct {
+	BlobStoreDriver    string        `env:"BLOB_STORE_DRIVER"`
+	BlobStoreFSPath    string        `env:"BLOB_STORE_FS_PATH"`
+	BlobStoreS3URL     string        `env:"BLOB_STORE_S3_URL"`
+	BlobStoreS3Region  string        `env:"BLOB_STORE_S3_REGION"`
+	BlobStoreS3Bucket  string        `env:"BLOB_STORE_S3_BUCKET"`
+	BlobStoreS3Access  string        `env:"BLOB_STORE_S3_ACCESS_KEY"`
+	BlobStoreS3Secret  string        `env:"BLOB_STORE_S3_SECRET_KEY"`
+	BlobStoreS3Timeout time.Duration `env:"BLOB_STORE_S3_TIMEOUT"`
+}
+
+type MQConfig struct {
+	MQDriver string `env:"MQ_DRIVER"`
+}
+
 type RabbitMQConfig struct {
 	RabbitHost          string `env:"RABBIT_HOST"`
 	RabbitPort          string `env:"RABBIT_PORT"`
@@ -76,6 +181,11 @@ type NatsMQCOnfig struct {
 	NatsHost          string `env:"NATS_HOST"`
 	NatsPort          string `env:"NATS_PORT"`
 	NatsTaskQueueName string `env:"NATS_TASK_QUEUE_NAME"`
+
+	NatsStreamName   string        `env:"NATS_STREAM_NAME"`
+	NatsConsumerName string        `env:"NATS_CONSUMER_NAME"`
+	NatsAckWait      time.Duration `env:"NATS_ACK_WAIT"`
+	NatsMaxDeliver   int           `env:"NATS_MAX_DELIVER"`
 }
 
 func (n NatsMQCOnfig) NatsDNS() string {
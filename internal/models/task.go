@@ -1,10 +1,21 @@
 package models
 
 import (
+	"crypto/sha256"
 	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type TaskStatus string
@@ -14,36 +25,392 @@ var (
 	StatusInProcess = TaskStatus("in process")
 	StatusError     = TaskStatus("error")
 	StatusNew       = TaskStatus("new")
+	StatusCancelled = TaskStatus("cancelled")
+	StatusBlocked   = TaskStatus("blocked")
+	StatusScheduled = TaskStatus("scheduled")
+)
+
+func (s TaskStatus) IsTerminal() bool {
+	return s == StatusDone || s == StatusError || s == StatusCancelled || s == StatusBlocked
+}
+
+type CallbackStatus string
+
+var (
+	CallbackNone      = CallbackStatus("none")
+	CallbackPending   = CallbackStatus("pending")
+	CallbackDelivered = CallbackStatus("delivered")
+	CallbackFailed    = CallbackStatus("failed")
+)
+
+type BodyEncoding string
+
+var (
+	BodyEncodingText   = BodyEncoding("text")
+	BodyEncodingBase64 = BodyEncoding("base64")
+)
+
+func EncodeBody(body []byte, encoding BodyEncoding) string {
+	if encoding == BodyEncodingBase64 {
+		return base64.StdEncoding.EncodeToString(body)
+	}
+
+	return string(body)
+}
+
+func DecodeBody(body string, encoding BodyEncoding) ([]byte, error) {
+	if encoding == BodyEncodingBase64 {
+		return base64.StdEncoding.DecodeString(body)
+	}
+
+	return []byte(body), nil
+}
+
+// ErrorCode - стабильный код класса ошибки выполнения запроса.
+type ErrorCode string
+
+var (
+	ErrorDNSFailure         = ErrorCode("dns_failure")
+	ErrorConnectTimeout     = ErrorCode("connect_timeout")
+	ErrorTLS                = ErrorCode("tls_error")
+	ErrorReadTimeout        = ErrorCode("read_timeout")
+	ErrorBodyTooLarge       = ErrorCode("body_too_large")
+	ErrorBlockedDestination = ErrorCode("blocked_destination")
+	ErrorInvalidRequest     = ErrorCode("invalid_request")
+	ErrorConnection         = ErrorCode("connection_error")
+	ErrorCancelled          = ErrorCode("cancelled")
+	ErrorWorkerLost         = ErrorCode("worker_lost")
+)
+
+// RetryPolicy задаёт повторы задачи. Не заданные (nil) списки заменяются значениями по умолчанию,
+// пустой список отключает повторы по этому признаку.
+type RetryPolicy struct {
+	MaxAttempts      int         `json:"max_attempts" validate:"min=0,max=20"`
+	RetryStatusCodes []int       `json:"retry_status_codes" validate:"dive,min=100,max=599"`
+	RetryErrors      []ErrorCode `json:"retry_errors" validate:"dive,oneof=dns_failure connect_timeout tls_error read_timeout connection_error"`
+}
+
+// OversizePolicy определяет, что делать с ответом, тело которого превышает лимит размера.
+type OversizePolicy string
+
+var (
+	OversizeTruncate = OversizePolicy("truncate")
+	OversizeFail     = OversizePolicy("fail")
+)
+
+type SortOrder string
+
+var (
+	SortAsc  = SortOrder("asc")
+	SortDesc = SortOrder("desc")
 )
 
 type TaskResult struct {
-	ID            string     `json:"id"`
-	Status        TaskStatus `json:"status"`
-	StatusCode    int        `json:"http_status_code"`
-	Headers       Headers    `json:"headers"`
-	Body          string     `json:"body"`
-	ContentLength int        `json:"content_length"`
+	ID            string       `json:"id"`
+	Status        TaskStatus   `json:"status"`
+	StatusCode    int          `json:"http_status_code"`
+	Headers       Headers      `json:"headers"`
+	Body          string       `json:"body"`
+	BodyEncoding  BodyEncoding `json:"body_encoding"`
+	ContentLength int          `json:"content_length"`
+	// BodyRef - ключ тела ответа во внешнем хранилище, если оно не поместилось в таблицу задач.
+	BodyRef string `json:"-"`
+	// Truncated означает, что тело обрезано по лимиту размера, OriginalContentLength в этом случае
+	// хранит Content-Length исходного ответа, если upstream его передал.
+	Truncated             bool  `json:"truncated,omitempty"`
+	OriginalContentLength int64 `json:"original_content_length,omitempty"`
+	// ErrorCode и ErrorMessage объясняют, почему задача завершилась статусом error или blocked.
+	ErrorCode    ErrorCode `json:"error_code,omitempty"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+
+	CallbackStatus   CallbackStatus `json:"callback_status,omitempty"`
+	CallbackAttempts int            `json:"callback_attempts,omitempty"`
+
+	RunAt *time.Time `json:"run_at,omitempty"`
+}
+
+func (r TaskResult) RawBody() ([]byte, error) {
+	return DecodeBody(r.Body, r.BodyEncoding)
+}
+
+// TaskBody - тело ответа задачи в исходном виде для отдачи клиенту без упаковки в JSON.
+type TaskBody struct {
+	Status    TaskStatus
+	Headers   Headers
+	Content   io.ReadSeekCloser
+	UpdatedAt time.Time
+}
+
+// TaskAttempt - запись об одной попытке выполнения задачи. Для попытки, завершившейся ответом upstream,
+// заполняется StatusCode, для неудачной - ErrorCode и ErrorMessage.
+type TaskAttempt struct {
+	TaskID       string    `json:"-"`
+	Attempt      int       `json:"attempt"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	StatusCode   int       `json:"http_status_code,omitempty"`
+	ErrorCode    ErrorCode `json:"error_code,omitempty"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+	RemoteAddr   string    `json:"remote_addr,omitempty"`
+}
+
+type TaskAttemptList struct {
+	Attempts []TaskAttempt `json:"attempts"`
+}
+
+// TaskMessage - задача, полученная из брокера. Сообщение подтверждается Ack, когда обработка задачи
+// завершена, и возвращается в очередь через Nack, если обработать его не удалось.
+type TaskMessage struct {
+	Task Task
+	ack  func() error
+	nack func(requeue bool) error
+}
+
+// NewTaskMessage создаёт сообщение с задачей. ack и nack могут быть nil, если брокер не поддерживает
+// подтверждения, тогда соответствующие методы ничего не делают.
+func NewTaskMessage(task Task, ack func() error, nack func(requeue bool) error) TaskMessage {
+	return TaskMessage{Task: task, ack: ack, nack: nack}
+}
+
+func (m TaskMessage) Ack() error {
+	if m.ack == nil {
+		return nil
+	}
+
+	return m.ack()
+}
+
+func (m TaskMessage) Nack(requeue bool) error {
+	if m.nack == nil {
+		return nil
+	}
+
+	return m.nack(requeue)
+}
+
+// TaskLease - аренда задачи экземпляром requester. TTL отсчитывается от времени хранилища.
+type TaskLease struct {
+	TaskID   string
+	WorkerID string
+	Attempt  int
+	TTL      time.Duration
+}
+
+type ExpiredTask struct {
+	Task     Task
+	Attempt  int
+	WorkerID string
+}
+
+type OutboxMessage struct {
+	ID        int64
+	RequestID string
+	Task      Task
+}
+
+type DeadLetterReason string
+
+var (
+	DeadLetterRetriesExhausted = DeadLetterReason("retries_exhausted")
+	DeadLetterWorkerLost       = DeadLetterReason("worker_lost")
+	DeadLetterMaxDeliveries    = DeadLetterReason("max_deliveries")
+	DeadLetterInvalidMessage   = DeadLetterReason("invalid_message")
+	DeadLetterPublishFailed    = DeadLetterReason("publish_failed")
+)
+
+type DeadLetter struct {
+	ID           string           `json:"id"`
+	TaskID       string           `json:"task_id,omitempty"`
+	Reason       DeadLetterReason `json:"reason"`
+	ErrorMessage string           `json:"error_message,omitempty"`
+	Payload      []byte           `json:"payload,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	ReplayedAt   *time.Time       `json:"replayed_at,omitempty"`
+	ReplayCount  int              `json:"replay_count"`
+}
+
+func NewDeadLetter(taskID string, reason DeadLetterReason, errMessage string, payload []byte) DeadLetter {
+	return DeadLetter{
+		ID:           uuid.NewString(),
+		TaskID:       taskID,
+		Reason:       reason,
+		ErrorMessage: errMessage,
+		Payload:      payload,
+		CreatedAt:    time.Now().UTC(),
+	}
+}
+
+type DeadLetterFilter struct {
+	Reason   DeadLetterReason `validate:"omitempty,deadletterreason"`
+	Replayed *bool
+	Limit    int `validate:"required,min=1,max=100"`
+	Cursor   string
+}
+
+// DeadLetterList - страница записей без Payload, от новых к старым.
+type DeadLetterList struct {
+	DeadLetters []DeadLetter `json:"dead_letters"`
+	NextCursor  string       `json:"next_cursor,omitempty"`
+}
+
+// DeadLetterReplay запускает записи IDs, а без них - до Limit ещё не запускавшихся записей с причиной Reason.
+type DeadLetterReplay struct {
+	IDs    []string         `json:"ids" validate:"max=100,dive,uuid"`
+	Reason DeadLetterReason `json:"reason" validate:"omitempty,deadletterreason"`
+	Limit  int              `json:"limit" validate:"min=0,max=100"`
+}
+
+type DeadLetterReplayResult struct {
+	ID     string `json:"id"`
+	TaskID string `json:"task_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type DeadLetterReplayList struct {
+	Results []DeadLetterReplayResult `json:"results"`
+}
+
+type TaskCompletion struct {
+	TaskID string     `json:"task_id"`
+	Status TaskStatus `json:"status"`
 }
 
 type NewTask struct {
-	URL     string            `json:"url" validate:"required,http_url"`
-	Method  string            `json:"method" validate:"required,httpmethod"`
-	Headers map[string]string `json:"headers"`
-	Body    string            `json:"body"`
+	URL            string              `json:"url" validate:"required,http_url"`
+	Method         string              `json:"method" validate:"required,httpmethod"`
+	Headers        Headers             `json:"headers"`
+	Query          map[string][]string `json:"query"`
+	Body           string              `json:"body"`
+	BodyEncoding   BodyEncoding        `json:"body_encoding" validate:"omitempty,oneof=text base64"`
+	CallbackURL    string              `json:"callback_url" validate:"omitempty,http_url"`
+	CallbackSecret string              `json:"callback_secret"`
+
+	MaxResponseBytes int64          `json:"max_response_bytes" validate:"min=0"`
+	OversizePolicy   OversizePolicy `json:"oversize_policy" validate:"omitempty,oneof=truncate fail"`
+	Retry            *RetryPolicy   `json:"retry"`
+
+	// Delay - срок вроде "90s" или число секунд. Задаётся не больше одного из RunAt и Delay.
+	RunAt *time.Time `json:"run_at"`
+	Delay string     `json:"delay"`
+}
+
+func (t NewTask) StartAt(now time.Time) (*time.Time, error) {
+	if t.RunAt != nil && t.Delay != "" {
+		return nil, errors.New("run_at and delay cannot be set together")
+	}
+
+	runAt := t.RunAt
+	if t.Delay != "" {
+		delay, err := time.ParseDuration(t.Delay)
+		if err != nil {
+			seconds, errSeconds := strconv.Atoi(t.Delay)
+			if errSeconds != nil {
+				return nil, fmt.Errorf("invalid delay %q: %w", t.Delay, err)
+			}
+			delay = time.Duration(seconds) * time.Second
+		}
+
+		if delay < 0 {
+			return nil, fmt.Errorf("invalid delay %q: delay is negative", t.Delay)
+		}
+
+		at := now.Add(delay)
+		runAt = &at
+	}
+
+	if runAt == nil || !runAt.After(now) {
+		return nil, nil
+	}
+
+	at := runAt.UTC()
+
+	return &at, nil
+}
+
+func (t NewTask) Fingerprint() (string, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+type NewTaskBatch struct {
+	Tasks []NewTask `json:"tasks"`
+}
+
+// TaskBatchResult - итог создания задачи из пачки, Index - её позиция в запросе.
+type TaskBatchResult struct {
+	Index int    `json:"index"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type TaskBatchList struct {
+	Results []TaskBatchResult `json:"results"`
+}
+
+type IdempotencyKey struct {
+	Key         string
+	Fingerprint string
+	TaskID      string
+	ExpiresAt   time.Time
 }
 
 type Task struct {
-	ID      string            `json:"id"`
-	URL     string            `json:"url"`
-	Method  string            `json:"method"`
-	Headers map[string]string `json:"headers"`
-	Body    string            `json:"body"`
+	ID             string              `json:"id"`
+	URL            string              `json:"url"`
+	Method         string              `json:"method"`
+	Headers        Headers             `json:"headers"`
+	Query          map[string][]string `json:"query,omitempty"`
+	Body           string              `json:"body"`
+	BodyEncoding   BodyEncoding        `json:"body_encoding,omitempty"`
+	CallbackURL    string              `json:"callback_url,omitempty"`
+	CallbackSecret string              `json:"callback_secret,omitempty"`
+
+	MaxResponseBytes int64          `json:"max_response_bytes,omitempty"`
+	OversizePolicy   OversizePolicy `json:"oversize_policy,omitempty"`
+	Retry            *RetryPolicy   `json:"retry,omitempty"`
+	// Attempt - номер текущей попытки, начиная с 1.
+	Attempt int `json:"attempt,omitempty"`
+	// Replay - номер повторного запуска из dead letters, попытки после него снова считаются с 1.
+	Replay int        `json:"replay,omitempty"`
+	RunAt  *time.Time `json:"run_at,omitempty"`
+}
+
+func (t Task) InitialStatus() TaskStatus {
+	if t.RunAt != nil {
+		return StatusScheduled
+	}
+
+	return StatusNew
+}
+
+// AttemptNumber возвращает номер текущей попытки. В сообщениях без номера попытка считается первой.
+func (t Task) AttemptNumber() int {
+	return max(t.Attempt, 1)
+}
+
+func (t Task) Host() string {
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+func (t Task) RawBody() ([]byte, error) {
+	return DecodeBody(t.Body, t.BodyEncoding)
 }
 
 func (t Task) TaskHeadersToHTTPHeaders() http.Header {
-	headers := make(http.Header)
+	headers := make(http.Header, len(t.Headers))
 	for key, values := range t.Headers {
-		for _, value := range strings.Split(values, ";") {
+		for _, value := range values {
 			headers.Add(key, value)
 		}
 	}
@@ -51,7 +418,89 @@ func (t Task) TaskHeadersToHTTPHeaders() http.Header {
 	return headers
 }
 
-type Headers map[string]string
+func (t Task) RequestURL() (string, error) {
+	if len(t.Query) == 0 {
+		return t.URL, nil
+	}
+
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return "", err
+	}
+
+	// Исходная строка запроса сохраняется как есть, параметры задачи дописываются в конец.
+	query := url.Values(t.Query).Encode()
+	if u.RawQuery == "" {
+		u.RawQuery = query
+	} else {
+		u.RawQuery += "&" + query
+	}
+
+	return u.String(), nil
+}
+
+type TaskSummary struct {
+	ID            string     `json:"id"`
+	Status        TaskStatus `json:"status"`
+	Method        string     `json:"method"`
+	URL           string     `json:"url"`
+	StatusCode    int        `json:"http_status_code"`
+	ContentLength int        `json:"content_length"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type TaskList struct {
+	Tasks      []TaskSummary `json:"tasks"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type TaskFilter struct {
+	Status      TaskStatus `validate:"omitempty,taskstatus"`
+	Host        string
+	Method      string `validate:"omitempty,httpmethod"`
+	StatusCode  int    `validate:"omitempty,min=100,max=599"`
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Order       SortOrder `validate:"required,oneof=asc desc"`
+	Limit       int       `validate:"required,min=1,max=100"`
+	Cursor      string
+}
+
+type Headers map[string][]string
+
+// UnmarshalJSON принимает как списки значений, так и одиночные строки,
+// в которых заголовки хранились и передавались раньше.
+func (h *Headers) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if raw == nil {
+		*h = nil
+		return nil
+	}
+
+	headers := make(Headers, len(raw))
+	for key, value := range raw {
+		var values []string
+		if err := json.Unmarshal(value, &values); err == nil {
+			headers[key] = values
+			continue
+		}
+
+		var single string
+		if err := json.Unmarshal(value, &single); err != nil {
+			return fmt.Errorf("invalid value of header %q: %w", key, err)
+		}
+		headers[key] = []string{single}
+	}
+
+	*h = headers
+
+	return nil
+}
 
 func (h Headers) Value() (driver.Value, error) {
 	if h == nil {
@@ -60,7 +509,7 @@ func (h Headers) Value() (driver.Value, error) {
 	return json.Marshal(h)
 }
 
-func (h Headers) Scan(src any) error {
+func (h *Headers) Scan(src any) error {
 	var data []byte
 	switch v := src.(type) {
 	case string:
@@ -68,5 +517,5 @@ func (h Headers) Scan(src any) error {
 	case []byte:
 		data = v
 	}
-	return json.Unmarshal(data, &h)
+	return json.Unmarshal(data, h)
 }
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadersUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected Headers
+		hasError bool
+	}{
+		{
+			name:     "list values",
+			data:     `{"Accept": ["text/html", "application/json"]}`,
+			expected: Headers{"Accept": {"text/html", "application/json"}},
+		},
+		{
+			name:     "legacy string value",
+			data:     `{"Content-Type": "text/html; charset=utf-8"}`,
+			expected: Headers{"Content-Type": {"text/html; charset=utf-8"}},
+		},
+		{
+			name: "null",
+			data: `null`,
+		},
+		{
+			name:     "invalid value",
+			data:     `{"Accept": 1}`,
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers Headers
+			err := json.Unmarshal([]byte(tt.data), &headers)
+			if tt.hasError {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, headers)
+		})
+	}
+}
+
+func TestTaskRequestURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		task     Task
+		expected string
+	}{
+		{
+			name:     "without query",
+			task:     Task{URL: "http://example.com/path?b=2&a=1"},
+			expected: "http://example.com/path?b=2&a=1",
+		},
+		{
+			name:     "with query",
+			task:     Task{URL: "http://example.com/path", Query: map[string][]string{"tag": {"x", "y z"}}},
+			expected: "http://example.com/path?tag=x&tag=y+z",
+		},
+		{
+			name:     "merge query",
+			task:     Task{URL: "http://example.com/path?b=2", Query: map[string][]string{"a": {"1"}}},
+			expected: "http://example.com/path?b=2&a=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestURL, err := tt.task.RequestURL()
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, requestURL)
+		})
+	}
+}
+
+func TestNewTaskStartAt(t *testing.T) {
+	now := time.Date(2025, 7, 30, 12, 0, 0, 0, time.UTC)
+	future := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		task     NewTask
+		expected *time.Time
+		hasError bool
+	}{
+		{
+			name: "immediately",
+		},
+		{
+			name:     "run at",
+			task:     NewTask{RunAt: &future},
+			expected: &future,
+		},
+		{
+			name: "run at in the past",
+			task: NewTask{RunAt: &past},
+		},
+		{
+			name:     "delay duration",
+			task:     NewTask{Delay: "90s"},
+			expected: ptr(now.Add(90 * time.Second)),
+		},
+		{
+			name:     "delay seconds",
+			task:     NewTask{Delay: "30"},
+			expected: ptr(now.Add(30 * time.Second)),
+		},
+		{
+			name:     "negative delay",
+			task:     NewTask{Delay: "-5s"},
+			hasError: true,
+		},
+		{
+			name:     "invalid delay",
+			task:     NewTask{Delay: "soon"},
+			hasError: true,
+		},
+		{
+			name:     "run at and delay",
+			task:     NewTask{RunAt: &future, Delay: "5s"},
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startAt, err := tt.task.StartAt(now)
+			if tt.hasError {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, startAt)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,19 @@
+package prom
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MustRegisterAllMetrics регистрирует метрики proxy и requester для запуска обоих сервисов в одном процессе.
+func MustRegisterAllMetrics(handler *gin.Engine) {
+	prometheus.MustRegister(proxyCollectors()...)
+	prometheus.MustRegister(requesterCollectors()...)
+
+	registerMetricsHandler(handler)
+}
+
+func registerMetricsHandler(handler *gin.Engine) {
+	handler.GET("/metrics", gin.WrapH(promhttp.Handler()))
+}
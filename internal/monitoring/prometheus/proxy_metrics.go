@@ -3,7 +3,6 @@ package prom
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
@@ -22,10 +21,37 @@ var (
 		},
 		[]string{"method", "path"},
 	)
+
+	ProxyDeadLetters = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "proxy_dead_letters_stored",
+			Help: "Total number of dead letters stored by the proxy",
+		},
+		[]string{"reason"},
+	)
+
+	ProxyDeadLettersReplayed = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "proxy_dead_letters_replayed",
+			Help: "Total number of tasks replayed from the dead-letter queue",
+		},
+	)
+
+	ProxyScheduledTasksPromoted = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "proxy_scheduled_tasks_promoted",
+			Help: "Total number of scheduled tasks moved to the outbox when their run time came",
+		},
+	)
 )
 
 func MustRegisterProxyMetrics(handler *gin.Engine) {
-	prometheus.MustRegister(ProxyPingCounter, ProxyHttpRequestDuration)
+	prometheus.MustRegister(proxyCollectors()...)
+
+	registerMetricsHandler(handler)
+}
 
-	handler.GET("/metrics", gin.WrapH(promhttp.Handler()))
+func proxyCollectors() []prometheus.Collector {
+	return []prometheus.Collector{ProxyPingCounter, ProxyHttpRequestDuration, ProxyDeadLetters,
+		ProxyDeadLettersReplayed, ProxyScheduledTasksPromoted}
 }
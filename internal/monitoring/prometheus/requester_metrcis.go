@@ -3,7 +3,6 @@ package prom
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
@@ -26,13 +25,56 @@ var (
 	RequesterTasksRejected = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "requester_tasks_rejected",
-			Help: "Total number of tasks rejected due to fill worker pool",
+			Help: "Total number of tasks returned to the broker because the worker pool is stopped",
 		},
 	)
+
+	RequesterQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "requester_queue_depth",
+			Help: "Number of received tasks waiting for a free worker",
+		},
+	)
+
+	RequesterTasksRetried = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "requester_tasks_retried",
+			Help: "Total number of task attempts requeued for retry",
+		},
+	)
+
+	RequesterLeasesLost = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "requester_task_leases_lost",
+			Help: "Total number of task executions cancelled because the task lease was lost",
+		},
+	)
+
+	RequesterReaperTasks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "requester_reaper_tasks",
+			Help: "Total number of tasks with expired lease handled by the reaper",
+		},
+		[]string{"action"},
+	)
+
+	RequesterDeadLetters = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "requester_dead_letters",
+			Help: "Total number of tasks sent to the dead-letter queue",
+		},
+		[]string{"reason"},
+	)
 )
 
 func MustRegisterRequesterMetrics(handler *gin.Engine) {
-	prometheus.MustRegister(RequesterTaskExecuteDuration, RequesterTasksStarted, RequesterTasksRejected)
+	prometheus.MustRegister(requesterCollectors()...)
+
+	registerMetricsHandler(handler)
+}
 
-	handler.GET("/metrics", gin.WrapH(promhttp.Handler()))
+func requesterCollectors() []prometheus.Collector {
+	return []prometheus.Collector{RequesterTaskExecuteDuration, RequesterTasksStarted, RequesterTasksRejected,
+		RequesterQueueDepth,
+		RequesterTasksRetried, RequesterLeasesLost, RequesterReaperTasks, RequesterDeadLetters}
 }
@@ -0,0 +1,453 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/ASsssker/proxy/internal/services"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	defaultStreamName   = "TASKS"
+	defaultConsumerName = "requester"
+	defaultAckWait      = 30 * time.Second
+	defaultMaxDeliver   = 5
+
+	deadLetterConsumerName = "dead-letters"
+
+	jetStreamSetupTimeout = 10 * time.Second
+
+	// deliverAtHeader хранит unix-время в миллисекундах, раньше которого задачу нельзя выполнять.
+	deliverAtHeader = "Proxy-Deliver-At"
+)
+
+// JetStreamMQ хранит задачи в потоке JetStream и раздаёт их requester-ам через общий durable pull consumer,
+// поэтому каждая задача выполняется одним экземпляром и не теряется, если requester-ы не запущены.
+// Сигналы отмены и завершения не требуют хранения и передаются через core NATS.
+type JetStreamMQ struct {
+	*NatsMQ
+	js            jetstream.JetStream
+	streamName    string
+	consumerName  string
+	ackWait       time.Duration
+	maxDeliver    int
+	maxAckPending int
+}
+
+func NewJetStreamMQ(cfg config.Config, log *slog.Logger) (*JetStreamMQ, error) {
+	natsMQ, err := NewNatsMQ(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(natsMQ.conn)
+	if err != nil {
+		natsMQ.conn.Close()
+		return nil, fmt.Errorf("failed to create jetstream context: %v", err)
+	}
+
+	j := &JetStreamMQ{
+		NatsMQ:        natsMQ,
+		js:            js,
+		streamName:    cfg.NatsStreamName,
+		consumerName:  cfg.NatsConsumerName,
+		ackWait:       cfg.NatsAckWait,
+		maxDeliver:    cfg.NatsMaxDeliver,
+		maxAckPending: cfg.RequesterPrefetch(),
+	}
+	if j.streamName == "" {
+		j.streamName = defaultStreamName
+	}
+	if j.consumerName == "" {
+		j.consumerName = defaultConsumerName
+	}
+	if j.ackWait <= 0 {
+		j.ackWait = defaultAckWait
+	}
+	if j.maxDeliver == 0 {
+		j.maxDeliver = defaultMaxDeliver
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamSetupTimeout)
+	defer cancel()
+
+	// WorkQueue удаляет сообщение из потока после подтверждения, поток хранит только невыполненные задачи
+	// и ещё не сохранённые dead-letter записи.
+	if _, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:      j.streamName,
+		Subjects:  []string{j.queueName, j.deadLetterSubject()},
+		Retention: jetstream.WorkQueuePolicy,
+		Storage:   jetstream.FileStorage,
+	}); err != nil {
+		natsMQ.conn.Close()
+		return nil, fmt.Errorf("failed to create jetstream stream: %v", err)
+	}
+
+	return j, nil
+}
+
+func (j *JetStreamMQ) SendTask(ctx context.Context, task models.Task) error {
+	const op = "jetstream.SendMessage"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := j.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	msg, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("%s request_id=%s failed to marshal task: %v", op, requestID, err)
+	}
+
+	if _, err := j.js.PublishMsg(ctx, &nats.Msg{Subject: j.queueName, Data: msg},
+		jetstream.WithMsgID(messageID(task))); err != nil {
+		return fmt.Errorf("%s request_id=%s failed to publish task: %v", op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+// SendTasks задаёт идентификатор сообщения, чтобы JetStream отбросил повтор задачи, уже опубликованной
+// из outbox.
+func (j *JetStreamMQ) SendTasks(ctx context.Context, tasks []models.Task) []error {
+	const op = "jetstream.SendTasks"
+
+	log := j.log.With(slog.String("op", op), slog.Int("tasks", len(tasks)))
+	log.DebugContext(ctx, "start operation")
+
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	errs := make([]error, len(tasks))
+	futures := make([]jetstream.PubAckFuture, len(tasks))
+	for i, task := range tasks {
+		msg, err := json.Marshal(task)
+		if err != nil {
+			errs[i] = fmt.Errorf("%s task_id=%s failed to marshal task: %v", op, task.ID, err)
+			continue
+		}
+
+		future, err := j.js.PublishMsgAsync(&nats.Msg{Subject: j.queueName, Data: msg},
+			jetstream.WithMsgID(messageID(task)))
+		if err != nil {
+			errs[i] = fmt.Errorf("%s task_id=%s failed to publish task: %v", op, task.ID, err)
+			continue
+		}
+		futures[i] = future
+	}
+
+	for i, future := range futures {
+		if future == nil {
+			continue
+		}
+
+		select {
+		case <-future.Ok():
+		case err := <-future.Err():
+			errs[i] = fmt.Errorf("%s task_id=%s failed to publish task: %v", op, tasks[i].ID, err)
+		case <-ctx.Done():
+			errs[i] = fmt.Errorf("%s task_id=%s failed to wait publish ack: %v", op, tasks[i].ID, ctx.Err())
+		}
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return errs
+}
+
+// RequeueTask публикует следующую попытку задачи с заголовком времени доставки. Получив её раньше срока,
+// Subscribe откладывает сообщение через NakWithDelay, поэтому такая задача расходует одну доставку
+// из NATS_MAX_DELIVER.
+func (j *JetStreamMQ) RequeueTask(ctx context.Context, task models.Task, delay time.Duration) error {
+	const op = "jetstream.RequeueTask"
+
+	log := j.log.With(slog.String("op", op), slog.String("task_id", task.ID))
+	log.DebugContext(ctx, "start operation")
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to marshal task: %v", op, task.ID, err)
+	}
+
+	msg := nats.NewMsg(j.queueName)
+	msg.Data = data
+	if delay > 0 {
+		// Время округляется вверх до миллисекунды, чтобы задача не была доставлена раньше delay.
+		deliverAt := time.Now().Add(delay + time.Millisecond - 1)
+		msg.Header.Set(deliverAtHeader, strconv.FormatInt(deliverAt.UnixMilli(), 10))
+	}
+
+	if _, err := j.js.PublishMsg(ctx, msg, jetstream.WithMsgID(messageID(task))); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to publish delayed task: %v", op, task.ID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+// Subscribe получает задачи через durable consumer, общий для всех requester-ов. Пока задача обрабатывается,
+// срок подтверждения продлевается, поэтому NATS_ACK_WAIT не ограничивает время выполнения задачи.
+func (j *JetStreamMQ) Subscribe(_ context.Context, taskChan chan models.TaskMessage) (context.CancelFunc, error) {
+	setupCtx, cancelSetup := context.WithTimeout(context.Background(), jetStreamSetupTimeout)
+	defer cancelSetup()
+
+	consumer, err := j.js.CreateOrUpdateConsumer(setupCtx, j.streamName, jetstream.ConsumerConfig{
+		Durable:       j.consumerName,
+		FilterSubject: j.queueName,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       j.ackWait,
+		MaxDeliver:    j.maxDeliver,
+		MaxAckPending: j.maxAckPending,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jetstream consumer: %v", err)
+	}
+
+	var opts []jetstream.PullMessagesOpt
+	if j.maxAckPending > 0 {
+		opts = append(opts, jetstream.PullMaxMessages(j.maxAckPending))
+	}
+
+	messages, err := consumer.Messages(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subcribe jetstream consumer: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		<-ctx.Done()
+		messages.Stop()
+	}()
+
+	go func() {
+		defer close(taskChan)
+
+		for {
+			msg, err := messages.Next()
+			if err != nil {
+				if errors.Is(err, jetstream.ErrMsgIteratorClosed) || ctx.Err() != nil {
+					return
+				}
+
+				j.log.Error("failed to receive jetstream message", slog.String("error", err.Error()))
+				continue
+			}
+
+			if delay := deliverDelay(msg.Headers()); delay > 0 {
+				if err := msg.NakWithDelay(delay); err != nil {
+					j.log.Error("failed to delay jetstream message", slog.String("error", err.Error()))
+				}
+				continue
+			}
+
+			j.log.Debug("mq receiver message", slog.String("message_body", string(msg.Data())))
+
+			task := models.Task{}
+			if err := json.Unmarshal(msg.Data(), &task); err != nil {
+				j.log.Error("failed to unmrashelled mq message", slog.String("message_body", string(msg.Data())))
+				j.terminate(msg, invalidMessage(msg.Data(), err))
+				continue
+			}
+
+			taskMsg := j.newTaskMessage(task, msg)
+
+			select {
+			case taskChan <- taskMsg:
+			case <-ctx.Done():
+				if err := taskMsg.Nack(true); err != nil {
+					j.log.Error("failed to requeue jetstream message", slog.String("error", err.Error()))
+				}
+				return
+			}
+		}
+	}()
+
+	return cancel, nil
+}
+
+// newTaskMessage оборачивает сообщение JetStream и продлевает срок его подтверждения до вызова Ack или Nack.
+func (j *JetStreamMQ) newTaskMessage(task models.Task, msg jetstream.Msg) models.TaskMessage {
+	done := make(chan struct{})
+	var once sync.Once
+	stop := func() { once.Do(func() { close(done) }) }
+
+	go func() {
+		ticker := time.NewTicker(j.ackWait / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := msg.InProgress(); err != nil {
+					j.log.Warn("failed to extend jetstream ack wait", slog.String("task_id", task.ID),
+						slog.String("error", err.Error()))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ack := func() error {
+		stop()
+		return msg.Ack()
+	}
+	nack := func(requeue bool) error {
+		stop()
+		if !requeue {
+			return msg.Term()
+		}
+
+		if j.lastDelivery(msg) {
+			j.log.Warn("task message exhausted deliveries", slog.String("task_id", task.ID))
+			j.terminate(msg, models.NewDeadLetter(task.ID, models.DeadLetterMaxDeliveries,
+				fmt.Sprintf("message was not processed in %d deliveries", j.maxDeliver), msg.Data()))
+			return nil
+		}
+
+		return msg.Nak()
+	}
+
+	return models.NewTaskMessage(task, ack, nack)
+}
+
+// terminate возвращает сообщение в поток, если dead-letter запись не удалось записать.
+func (j *JetStreamMQ) terminate(msg jetstream.Msg, deadLetter models.DeadLetter) {
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamSetupTimeout)
+	defer cancel()
+
+	if err := j.SendDeadLetter(ctx, deadLetter); err != nil {
+		j.log.Error("failed to send message to dead letters", slog.String("error", err.Error()))
+		if err := msg.Nak(); err != nil {
+			j.log.Error("failed to requeue jetstream message", slog.String("error", err.Error()))
+		}
+		return
+	}
+
+	if err := msg.Term(); err != nil {
+		j.log.Error("failed to terminate jetstream message", slog.String("error", err.Error()))
+	}
+}
+
+func (j *JetStreamMQ) lastDelivery(msg jetstream.Msg) bool {
+	if j.maxDeliver <= 0 {
+		return false
+	}
+
+	meta, err := msg.Metadata()
+	if err != nil {
+		return false
+	}
+
+	return meta.NumDelivered >= uint64(j.maxDeliver)
+}
+
+func (j *JetStreamMQ) SendDeadLetter(ctx context.Context, deadLetter models.DeadLetter) error {
+	const op = "jetstream.SendDeadLetter"
+
+	log := j.log.With(slog.String("op", op), slog.String("dead_letter_id", deadLetter.ID))
+	log.DebugContext(ctx, "start operation")
+
+	data, err := json.Marshal(deadLetter)
+	if err != nil {
+		return fmt.Errorf("%s dead_letter_id=%s failed to marshal dead letter: %v", op, deadLetter.ID, err)
+	}
+
+	if _, err := j.js.PublishMsg(ctx, &nats.Msg{Subject: j.deadLetterSubject(), Data: data},
+		jetstream.WithMsgID(deadLetter.ID)); err != nil {
+		return fmt.Errorf("%s dead_letter_id=%s failed to publish dead letter: %v", op, deadLetter.ID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (j *JetStreamMQ) SubscribeDeadLetters(_ context.Context,
+	handle func(ctx context.Context, deadLetter models.DeadLetter) error) (context.CancelFunc, error) {
+	setupCtx, cancelSetup := context.WithTimeout(context.Background(), jetStreamSetupTimeout)
+	defer cancelSetup()
+
+	consumer, err := j.js.CreateOrUpdateConsumer(setupCtx, j.streamName, jetstream.ConsumerConfig{
+		Durable:       deadLetterConsumerName,
+		FilterSubject: j.deadLetterSubject(),
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       j.ackWait,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jetstream dead letter consumer: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	consumeCtx, err := consumer.Consume(func(msg jetstream.Msg) {
+		deadLetter := models.DeadLetter{}
+		if err := json.Unmarshal(msg.Data(), &deadLetter); err != nil {
+			j.log.Error("failed to unmrashelled dead letter", slog.String("message_body", string(msg.Data())))
+			if err := msg.Term(); err != nil {
+				j.log.Error("failed to terminate jetstream message", slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		if err := handle(ctx, deadLetter); err != nil {
+			j.log.Error("failed to handle dead letter", slog.String("dead_letter_id", deadLetter.ID),
+				slog.String("error", err.Error()))
+			if err := msg.NakWithDelay(deadLetterRetryDelay); err != nil {
+				j.log.Error("failed to requeue jetstream message", slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		if err := msg.Ack(); err != nil {
+			j.log.Error("failed to ack jetstream message", slog.String("error", err.Error()))
+		}
+	})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to subcribe jetstream dead letter consumer: %v", err)
+	}
+
+	return func() {
+		cancel()
+		consumeCtx.Stop()
+	}, nil
+}
+
+// messageID используется JetStream для отбрасывания повторных публикаций одной и той же попытки. Номер
+// повторного запуска не даёт отбросить попытки, которые после replay снова нумеруются с 1.
+func messageID(task models.Task) string {
+	id := task.ID + "." + strconv.Itoa(task.AttemptNumber())
+	if task.Replay > 0 {
+		id += ".replay." + strconv.Itoa(task.Replay)
+	}
+
+	return id
+}
+
+func deliverDelay(header nats.Header) time.Duration {
+	value := header.Get(deliverAtHeader)
+	if value == "" {
+		return 0
+	}
+
+	deliverAt, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return time.Until(time.UnixMilli(deliverAt))
+}
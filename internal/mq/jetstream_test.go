@@ -0,0 +1,330 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/ASsssker/proxy/internal/services"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJetStreamMQ_Ack(t *testing.T) {
+	cfg := newJetStreamConfig(t)
+
+	sender := newJetStreamMQ(t, cfg)
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET", Attempt: 1}
+	// Задача публикуется до подписки: поток хранит её, пока requester не подключится.
+	require.NoError(t, sender.SendTask(newContextWithRequestID(), task))
+
+	receiver := newJetStreamMQ(t, cfg)
+	taskChan := make(chan models.TaskMessage)
+	cancel, err := receiver.Subscribe(context.Background(), taskChan)
+	require.NoError(t, err)
+	defer cancel()
+
+	msg := receiveTask(t, taskChan)
+	require.Equal(t, task, msg.Task)
+	require.NoError(t, msg.Ack())
+
+	expectNoTask(t, taskChan, 300*time.Millisecond)
+}
+
+func TestJetStreamMQ_SendTasks(t *testing.T) {
+	mq := newJetStreamMQ(t, newJetStreamConfig(t))
+
+	tasks := make([]models.Task, 3)
+	for i := range tasks {
+		tasks[i] = models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET", Attempt: 1}
+	}
+	// Повтор уже опубликованной задачи отбрасывается потоком по идентификатору сообщения.
+	for _, err := range mq.SendTasks(context.Background(), append(tasks, tasks[0])) {
+		require.NoError(t, err)
+	}
+
+	taskChan := make(chan models.TaskMessage)
+	cancel, err := mq.Subscribe(context.Background(), taskChan)
+	require.NoError(t, err)
+	defer cancel()
+
+	for _, task := range tasks {
+		msg := receiveTask(t, taskChan)
+		require.Equal(t, task, msg.Task)
+		require.NoError(t, msg.Ack())
+	}
+
+	expectNoTask(t, taskChan, 300*time.Millisecond)
+}
+
+func TestJetStreamMQ_SendReplayedTask(t *testing.T) {
+	mq := newJetStreamMQ(t, newJetStreamConfig(t))
+
+	// После каждого повторного запуска попытки снова нумеруются с 1, но публикация не должна отбрасываться.
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET", Attempt: 1}
+	tasks := []models.Task{task}
+	for replay := 1; replay <= 2; replay++ {
+		task.Replay = replay
+		tasks = append(tasks, task)
+	}
+	for _, task := range tasks {
+		require.NoError(t, mq.SendTask(newContextWithRequestID(), task))
+	}
+
+	taskChan := make(chan models.TaskMessage)
+	cancel, err := mq.Subscribe(context.Background(), taskChan)
+	require.NoError(t, err)
+	defer cancel()
+
+	for _, task := range tasks {
+		msg := receiveTask(t, taskChan)
+		require.Equal(t, task, msg.Task)
+		require.NoError(t, msg.Ack())
+	}
+
+	expectNoTask(t, taskChan, 300*time.Millisecond)
+}
+
+func TestJetStreamMQ_Nack(t *testing.T) {
+	cfg := newJetStreamConfig(t)
+	cfg.NatsMaxDeliver = 2
+
+	mq := newJetStreamMQ(t, cfg)
+	taskChan := make(chan models.TaskMessage)
+	cancel, err := mq.Subscribe(context.Background(), taskChan)
+	require.NoError(t, err)
+	defer cancel()
+
+	task := models.Task{ID: uuid.NewString(), Attempt: 1}
+	require.NoError(t, mq.SendTask(newContextWithRequestID(), task))
+
+	msg := receiveTask(t, taskChan)
+	require.NoError(t, msg.Nack(true))
+
+	msg = receiveTask(t, taskChan)
+	require.Equal(t, task.ID, msg.Task.ID)
+	require.NoError(t, msg.Nack(true))
+
+	// Число доставок ограничено NATS_MAX_DELIVER.
+	expectNoTask(t, taskChan, 300*time.Millisecond)
+}
+
+func TestJetStreamMQ_DeadLetters(t *testing.T) {
+	cfg := newJetStreamConfig(t)
+	cfg.NatsMaxDeliver = 2
+
+	mq := newJetStreamMQ(t, cfg)
+	deadLetters := make(chan models.DeadLetter, 2)
+	cancelDeadLetters, err := mq.SubscribeDeadLetters(context.Background(),
+		func(_ context.Context, deadLetter models.DeadLetter) error {
+			deadLetters <- deadLetter
+			return nil
+		})
+	require.NoError(t, err)
+	defer cancelDeadLetters()
+
+	taskChan := make(chan models.TaskMessage)
+	cancel, err := mq.Subscribe(context.Background(), taskChan)
+	require.NoError(t, err)
+	defer cancel()
+
+	task := models.Task{ID: uuid.NewString(), Attempt: 1}
+	require.NoError(t, mq.SendTask(newContextWithRequestID(), task))
+
+	require.NoError(t, receiveTask(t, taskChan).Nack(true))
+	require.NoError(t, receiveTask(t, taskChan).Nack(true))
+
+	deadLetter := receiveDeadLetter(t, deadLetters)
+	require.Equal(t, models.DeadLetterMaxDeliveries, deadLetter.Reason)
+	require.Equal(t, task.ID, deadLetter.TaskID)
+	require.JSONEq(t, mustMarshal(t, task), string(deadLetter.Payload))
+
+	_, err = mq.js.Publish(context.Background(), cfg.NatsTaskQueueName, []byte("not a task"))
+	require.NoError(t, err)
+
+	deadLetter = receiveDeadLetter(t, deadLetters)
+	require.Equal(t, models.DeadLetterInvalidMessage, deadLetter.Reason)
+	require.Equal(t, "not a task", string(deadLetter.Payload))
+
+	expectNoTask(t, taskChan, 300*time.Millisecond)
+}
+
+func TestJetStreamMQ_SharedConsumer(t *testing.T) {
+	cfg := newJetStreamConfig(t)
+
+	taskChan := make(chan models.TaskMessage)
+	first, second := newJetStreamMQ(t, cfg), newJetStreamMQ(t, cfg)
+	firstChan, secondChan := make(chan models.TaskMessage), make(chan models.TaskMessage)
+	cancelFirst, err := first.Subscribe(context.Background(), firstChan)
+	require.NoError(t, err)
+	defer cancelFirst()
+	cancelSecond, err := second.Subscribe(context.Background(), secondChan)
+	require.NoError(t, err)
+	defer cancelSecond()
+
+	go func() {
+		for {
+			select {
+			case msg, ok := <-firstChan:
+				if !ok {
+					return
+				}
+				taskChan <- msg
+			case msg, ok := <-secondChan:
+				if !ok {
+					return
+				}
+				taskChan <- msg
+			}
+		}
+	}()
+
+	const tasksCount = 10
+	for i := range tasksCount {
+		require.NoError(t, first.SendTask(newContextWithRequestID(), models.Task{ID: strconv.Itoa(i), Attempt: 1}))
+	}
+
+	received := make(map[string]int)
+	for range tasksCount {
+		msg := receiveTask(t, taskChan)
+		received[msg.Task.ID]++
+		require.NoError(t, msg.Ack())
+	}
+
+	require.Len(t, received, tasksCount)
+	for id, count := range received {
+		require.Equal(t, 1, count, "task %s delivered more than once", id)
+	}
+	expectNoTask(t, taskChan, 300*time.Millisecond)
+}
+
+func TestJetStreamMQ_RequeueTask(t *testing.T) {
+	cfg := newJetStreamConfig(t)
+
+	mq := newJetStreamMQ(t, cfg)
+	taskChan := make(chan models.TaskMessage)
+	cancel, err := mq.Subscribe(context.Background(), taskChan)
+	require.NoError(t, err)
+	defer cancel()
+
+	task := models.Task{ID: uuid.NewString(), Attempt: 2}
+	start := time.Now()
+	require.NoError(t, mq.RequeueTask(context.Background(), task, 500*time.Millisecond))
+
+	msg := receiveTask(t, taskChan)
+	require.Equal(t, task, msg.Task)
+	require.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+	require.NoError(t, msg.Ack())
+}
+
+func TestJetStreamMQ_AckWaitExtended(t *testing.T) {
+	cfg := newJetStreamConfig(t)
+	cfg.NatsAckWait = 200 * time.Millisecond
+
+	mq := newJetStreamMQ(t, cfg)
+	taskChan := make(chan models.TaskMessage)
+	cancel, err := mq.Subscribe(context.Background(), taskChan)
+	require.NoError(t, err)
+	defer cancel()
+
+	require.NoError(t, mq.SendTask(newContextWithRequestID(), models.Task{ID: uuid.NewString(), Attempt: 1}))
+
+	msg := receiveTask(t, taskChan)
+	// Обработка длится дольше ack wait, но сообщение не доставляется повторно.
+	expectNoTask(t, taskChan, time.Second)
+	require.NoError(t, msg.Ack())
+}
+
+func newJetStreamConfig(t *testing.T) config.Config {
+	t.Helper()
+
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+
+	srv.Start()
+	t.Cleanup(srv.Shutdown)
+	require.True(t, srv.ReadyForConnections(5*time.Second))
+
+	host, port, err := net.SplitHostPort(srv.Addr().String())
+	require.NoError(t, err)
+
+	return config.Config{
+		RequesterServiceConfig: config.RequesterServiceConfig{RequesterWorkersCount: 2},
+		NatsMQCOnfig: config.NatsMQCOnfig{
+			NatsHost:          host,
+			NatsPort:          port,
+			NatsTaskQueueName: "task_queue",
+		},
+	}
+}
+
+func newJetStreamMQ(t *testing.T, cfg config.Config) *JetStreamMQ {
+	t.Helper()
+
+	mq, err := NewJetStreamMQ(cfg, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mq.Close(context.Background()) })
+
+	return mq
+}
+
+func receiveTask(t *testing.T, taskChan chan models.TaskMessage) models.TaskMessage {
+	t.Helper()
+
+	select {
+	case msg := <-taskChan:
+		return msg
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "task was not delivered")
+		return models.TaskMessage{}
+	}
+}
+
+func receiveDeadLetter(t *testing.T, deadLetters chan models.DeadLetter) models.DeadLetter {
+	t.Helper()
+
+	select {
+	case deadLetter := <-deadLetters:
+		return deadLetter
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "dead letter was not delivered")
+		return models.DeadLetter{}
+	}
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+func expectNoTask(t *testing.T, taskChan chan models.TaskMessage, wait time.Duration) {
+	t.Helper()
+
+	select {
+	case msg := <-taskChan:
+		require.FailNow(t, "unexpected task delivery", "task_id=%s", msg.Task.ID)
+	case <-time.After(wait):
+	}
+}
+
+func newContextWithRequestID() context.Context {
+	return context.WithValue(context.Background(), services.RequestIDKey, uuid.NewString())
+}
@@ -0,0 +1,250 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/models"
+)
+
+var (
+	errMemoryMQClosed       = errors.New("memory mq is closed")
+	errNoDeadLetterConsumer = errors.New("memory mq has no dead letter subscriber")
+)
+
+// MemoryMQ - брокер в памяти процесса. Он не переживает перезапуск и связывает только сервисы,
+// запущенные в одном процессе, поэтому подходит для локального запуска и тестов.
+type MemoryMQ struct {
+	log *slog.Logger
+
+	mu     sync.Mutex
+	tasks  []models.Task
+	ready  chan struct{}
+	closed bool
+
+	cancelSubs     subscribers[string]
+	completionSubs subscribers[models.TaskCompletion]
+
+	deadLetterHandler func(ctx context.Context, deadLetter models.DeadLetter) error
+}
+
+func NewMemoryMQ(log *slog.Logger) *MemoryMQ {
+	return &MemoryMQ{
+		log:   log,
+		ready: make(chan struct{}, 1),
+	}
+}
+
+func (m *MemoryMQ) SendTask(_ context.Context, task models.Task) error {
+	return m.push(task)
+}
+
+func (m *MemoryMQ) SendTasks(_ context.Context, tasks []models.Task) []error {
+	errs := make([]error, len(tasks))
+	for i, task := range tasks {
+		errs[i] = m.push(task)
+	}
+
+	return errs
+}
+
+func (m *MemoryMQ) RequeueTask(_ context.Context, task models.Task, delay time.Duration) error {
+	m.mu.Lock()
+	closed := m.closed
+	m.mu.Unlock()
+
+	if closed {
+		return errMemoryMQClosed
+	}
+
+	time.AfterFunc(delay, func() {
+		if err := m.push(task); err != nil {
+			m.log.Warn("delayed task dropped", slog.String("task_id", task.ID), slog.String("error", err.Error()))
+		}
+	})
+
+	return nil
+}
+
+// Subscribe отдаёт задачи из очереди. Nack с requeue возвращает задачу в конец очереди.
+func (m *MemoryMQ) Subscribe(_ context.Context, taskChan chan models.TaskMessage) (context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		defer close(taskChan)
+
+		for {
+			task, ok := m.pop()
+			if !ok {
+				select {
+				case <-m.ready:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			nack := func(requeue bool) error {
+				if !requeue {
+					return nil
+				}
+				return m.push(task)
+			}
+
+			select {
+			case taskChan <- models.NewTaskMessage(task, nil, nack):
+			case <-ctx.Done():
+				if err := m.push(task); err != nil {
+					m.log.Warn("task dropped", slog.String("task_id", task.ID), slog.String("error", err.Error()))
+				}
+				return
+			}
+		}
+	}()
+
+	return cancel, nil
+}
+
+func (m *MemoryMQ) SendCancel(ctx context.Context, taskID string) error {
+	m.cancelSubs.publish(ctx, taskID)
+	return nil
+}
+
+func (m *MemoryMQ) SubscribeCancel(_ context.Context, cancelChan chan string) (context.CancelFunc, error) {
+	return m.cancelSubs.add(cancelChan), nil
+}
+
+func (m *MemoryMQ) SendCompletion(ctx context.Context, completion models.TaskCompletion) error {
+	m.completionSubs.publish(ctx, completion)
+	return nil
+}
+
+func (m *MemoryMQ) SubscribeCompletions(_ context.Context, completionChan chan models.TaskCompletion) (
+	context.CancelFunc, error) {
+	return m.completionSubs.add(completionChan), nil
+}
+
+// SendDeadLetter синхронно передаёт запись подписчику, ошибка его обработки возвращается отправителю.
+func (m *MemoryMQ) SendDeadLetter(ctx context.Context, deadLetter models.DeadLetter) error {
+	m.mu.Lock()
+	handle := m.deadLetterHandler
+	m.mu.Unlock()
+
+	if handle == nil {
+		return errNoDeadLetterConsumer
+	}
+
+	return handle(ctx, deadLetter)
+}
+
+func (m *MemoryMQ) SubscribeDeadLetters(_ context.Context,
+	handle func(ctx context.Context, deadLetter models.DeadLetter) error) (context.CancelFunc, error) {
+	m.mu.Lock()
+	m.deadLetterHandler = handle
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		m.deadLetterHandler = nil
+		m.mu.Unlock()
+	}, nil
+}
+
+// Close останавливает приём задач. Задачи, оставшиеся в очереди, теряются.
+func (m *MemoryMQ) Close(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	m.tasks = nil
+
+	return nil
+}
+
+func (m *MemoryMQ) push(task models.Task) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return errMemoryMQClosed
+	}
+
+	m.tasks = append(m.tasks, task)
+
+	select {
+	case m.ready <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (m *MemoryMQ) pop() (models.Task, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.tasks) == 0 {
+		return models.Task{}, false
+	}
+
+	task := m.tasks[0]
+	m.tasks = m.tasks[1:]
+
+	return task, true
+}
+
+// subscribers рассылает сообщение всем подписчикам, как fanout-обмен брокера.
+type subscribers[T any] struct {
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]subscriber[T]
+}
+
+type subscriber[T any] struct {
+	ctx context.Context
+	ch  chan T
+}
+
+func (s *subscribers[T]) add(ch chan T) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subs == nil {
+		s.subs = make(map[int]subscriber[T])
+	}
+
+	id := s.nextID
+	s.nextID++
+	s.subs[id] = subscriber[T]{ctx: ctx, ch: ch}
+
+	return func() {
+		cancel()
+
+		s.mu.Lock()
+		delete(s.subs, id)
+		s.mu.Unlock()
+	}
+}
+
+func (s *subscribers[T]) publish(ctx context.Context, msg T) {
+	s.mu.Lock()
+	subs := make([]subscriber[T], 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	s.mu.Unlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.ch <- msg:
+		case <-sub.ctx.Done():
+		case <-ctx.Done():
+			return
+		}
+	}
+}
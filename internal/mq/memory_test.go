@@ -0,0 +1,74 @@
+package mq
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryMQ_Tasks(t *testing.T) {
+	mq := NewMemoryMQ(slog.New(slog.DiscardHandler))
+	defer mq.Close(context.Background())
+
+	task := models.Task{ID: uuid.NewString(), Attempt: 1}
+	require.NoError(t, mq.SendTask(newContextWithRequestID(), task))
+
+	taskChan := make(chan models.TaskMessage)
+	cancel, err := mq.Subscribe(context.Background(), taskChan)
+	require.NoError(t, err)
+	defer cancel()
+
+	msg := receiveTask(t, taskChan)
+	require.Equal(t, task, msg.Task)
+	require.NoError(t, msg.Nack(true))
+
+	msg = receiveTask(t, taskChan)
+	require.Equal(t, task, msg.Task)
+	require.NoError(t, msg.Ack())
+
+	expectNoTask(t, taskChan, 100*time.Millisecond)
+
+	retry := models.Task{ID: task.ID, Attempt: 2}
+	start := time.Now()
+	require.NoError(t, mq.RequeueTask(context.Background(), retry, 200*time.Millisecond))
+
+	msg = receiveTask(t, taskChan)
+	require.Equal(t, retry, msg.Task)
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestMemoryMQ_Signals(t *testing.T) {
+	mq := NewMemoryMQ(slog.New(slog.DiscardHandler))
+	defer mq.Close(context.Background())
+
+	first, second := make(chan string, 1), make(chan string, 1)
+	cancelFirst, err := mq.SubscribeCancel(context.Background(), first)
+	require.NoError(t, err)
+	defer cancelFirst()
+	cancelSecond, err := mq.SubscribeCancel(context.Background(), second)
+	require.NoError(t, err)
+
+	taskID := uuid.NewString()
+	require.NoError(t, mq.SendCancel(newContextWithRequestID(), taskID))
+	require.Equal(t, taskID, <-first)
+	require.Equal(t, taskID, <-second)
+
+	cancelSecond()
+	require.NoError(t, mq.SendCancel(newContextWithRequestID(), taskID))
+	require.Equal(t, taskID, <-first)
+	require.Empty(t, second)
+
+	completions := make(chan models.TaskCompletion, 1)
+	cancelCompletions, err := mq.SubscribeCompletions(context.Background(), completions)
+	require.NoError(t, err)
+	defer cancelCompletions()
+
+	completion := models.TaskCompletion{TaskID: taskID, Status: models.StatusDone}
+	require.NoError(t, mq.SendCompletion(context.Background(), completion))
+	require.Equal(t, completion, <-completions)
+}
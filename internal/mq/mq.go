@@ -0,0 +1,61 @@
+package mq
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/ASsssker/proxy/internal/services"
+)
+
+const (
+	DriverNats      = "nats"
+	DriverJetStream = "jetstream"
+	DriverRabbitMQ  = "rabbitmq"
+	DriverMemory    = "memory"
+
+	// deadLetterRetryDelay - пауза перед повторной доставкой dead-letter записи, которую не удалось сохранить.
+	deadLetterRetryDelay = 5 * time.Second
+)
+
+// Broker объединяет сторону отправки задач (proxy) и сторону их получения (requester).
+type Broker interface {
+	services.MessageSender
+	services.MessageReceiver
+}
+
+// New создаёт брокер по MQ_DRIVER. Если драйвер не задан, используется core NATS. Очередь в памяти
+// связывает только сервисы одного процесса, поэтому её создаёт allinone, а отдельные proxy и requester
+// с ней не запускаются.
+func New(cfg config.Config, log *slog.Logger) (Broker, error) {
+	switch cfg.MQDriver {
+	case "", DriverNats:
+		broker, err := NewNatsMQ(cfg, log)
+		if err != nil {
+			return nil, err
+		}
+		return broker, nil
+	case DriverJetStream:
+		broker, err := NewJetStreamMQ(cfg, log)
+		if err != nil {
+			return nil, err
+		}
+		return broker, nil
+	case DriverRabbitMQ:
+		broker, err := NewRabbitMQ(cfg, log)
+		if err != nil {
+			return nil, err
+		}
+		return broker, nil
+	case DriverMemory:
+		return nil, fmt.Errorf("mq driver %q is available only in the all-in-one mode", cfg.MQDriver)
+	default:
+		return nil, fmt.Errorf("unknown mq driver %q", cfg.MQDriver)
+	}
+}
+
+func invalidMessage(data []byte, err error) models.DeadLetter {
+	return models.NewDeadLetter("", models.DeadLetterInvalidMessage, "failed to unmarshal task: "+err.Error(), data)
+}
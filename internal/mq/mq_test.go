@@ -0,0 +1,37 @@
+package mq
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	cfg := newJetStreamConfig(t)
+
+	for _, driver := range []string{DriverNats, DriverJetStream} {
+		t.Run(driver, func(t *testing.T) {
+			cfg.MQDriver = driver
+
+			broker, err := New(cfg, slog.New(slog.DiscardHandler))
+			require.NoError(t, err)
+			require.NoError(t, broker.Close(context.Background()))
+		})
+	}
+
+	t.Run("memory driver", func(t *testing.T) {
+		cfg.MQDriver = DriverMemory
+
+		_, err := New(cfg, slog.New(slog.DiscardHandler))
+		require.Error(t, err)
+	})
+
+	t.Run("unknown driver", func(t *testing.T) {
+		cfg.MQDriver = "kafka"
+
+		_, err := New(cfg, slog.New(slog.DiscardHandler))
+		require.Error(t, err)
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/ASsssker/proxy/internal/config"
 	"github.com/ASsssker/proxy/internal/models"
@@ -12,6 +14,11 @@ import (
 	"github.com/nats-io/nats.go"
 )
 
+const (
+	deadLetterQueueGroup = "dead-letters"
+	publishTimeout       = 10 * time.Second
+)
+
 type NatsMQ struct {
 	conn      *nats.Conn
 	queueName string
@@ -52,22 +59,102 @@ func (n *NatsMQ) SendTask(ctx context.Context, task models.Task) error {
 	return nil
 }
 
-func (n *NatsMQ) Subscribe(_ context.Context, taskChan chan models.Task) (context.CancelFunc, error) {
+// SendTasks один раз сбрасывает буфер соединения, ошибка сброса относится ко всем задачам пачки.
+func (n *NatsMQ) SendTasks(ctx context.Context, tasks []models.Task) []error {
+	const op = "nats.SendTasks"
+
+	log := n.log.With(slog.String("op", op), slog.Int("tasks", len(tasks)))
+	log.DebugContext(ctx, "start operation")
+
+	errs := make([]error, len(tasks))
+	for i, task := range tasks {
+		msg, err := json.Marshal(task)
+		if err != nil {
+			errs[i] = fmt.Errorf("%s task_id=%s failed to marshal task: %v", op, task.ID, err)
+			continue
+		}
+
+		if err := n.conn.Publish(n.queueName, msg); err != nil {
+			errs[i] = fmt.Errorf("%s task_id=%s failed to publish task: %v", op, task.ID, err)
+		}
+	}
+
+	if err := n.conn.FlushTimeout(publishTimeout); err != nil {
+		for i, task := range tasks {
+			if errs[i] == nil {
+				errs[i] = fmt.Errorf("%s task_id=%s failed to flush tasks: %v", op, task.ID, err)
+			}
+		}
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return errs
+}
+
+// RequeueTask публикует задачу повторно через delay. Core NATS не поддерживает отложенную доставку,
+// поэтому задержка выдерживается таймером в процессе и теряется при его остановке.
+func (n *NatsMQ) RequeueTask(ctx context.Context, task models.Task, delay time.Duration) error {
+	const op = "nats.RequeueTask"
+
+	log := n.log.With(slog.String("op", op), slog.String("task_id", task.ID))
+	log.DebugContext(ctx, "start operation")
+
+	msg, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to marshal task: %v", op, task.ID, err)
+	}
+
+	time.AfterFunc(delay, func() {
+		if err := n.conn.Publish(n.queueName, msg); err != nil {
+			log.Error("failed to publish delayed task", slog.String("error", err.Error()))
+		}
+	})
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+// Subscribe подписывается на очередь задач. Core NATS не поддерживает подтверждения, поэтому Ack
+// ничего не делает, а Nack с requeue публикует задачу в очередь заново.
+func (n *NatsMQ) Subscribe(_ context.Context, taskChan chan models.TaskMessage) (context.CancelFunc, error) {
 	ctx, cancel := context.WithCancel(context.Background())
+	// inflight не даёт закрыть taskChan, пока обработчик держит полученное сообщение.
+	var inflight sync.RWMutex
 
 	sub, err := n.conn.Subscribe(n.queueName, func(msg *nats.Msg) {
+		inflight.RLock()
+		defer inflight.RUnlock()
+
 		n.log.Debug("mq receiver message", slog.String("message_body", string(msg.Data)))
 
 		task := models.Task{}
 		if err := json.Unmarshal(msg.Data, &task); err != nil {
 			n.log.Error("failed to unmrashelled mq message", slog.String("message_body", string(msg.Data)))
+			if err := n.SendDeadLetter(context.Background(), invalidMessage(msg.Data, err)); err != nil {
+				n.log.Error("failed to send invalid message to dead letters", slog.String("error", err.Error()))
+			}
 			return
 		}
 
-		select {
-		case taskChan <- task:
-		case <-ctx.Done():
-			close(taskChan)
+		nack := func(requeue bool) error {
+			if !requeue {
+				return nil
+			}
+			return n.conn.Publish(n.queueName, msg.Data)
+		}
+
+		if ctx.Err() == nil {
+			select {
+			case taskChan <- models.NewTaskMessage(task, nil, nack):
+				return
+			case <-ctx.Done():
+			}
+		}
+
+		if err := nack(true); err != nil {
+			n.log.Error("failed to requeue mq message", slog.String("error", err.Error()))
 		}
 	})
 
@@ -81,6 +168,158 @@ func (n *NatsMQ) Subscribe(_ context.Context, taskChan chan models.Task) (contex
 		if err := sub.Unsubscribe(); err != nil {
 			n.log.Error("failed to unsubscribe queue", slog.String("error", err.Error()))
 		}
+
+		inflight.Lock()
+		close(taskChan)
+		inflight.Unlock()
+	}()
+
+	return cancel, nil
+}
+
+func (n *NatsMQ) SendCancel(ctx context.Context, taskID string) error {
+	const op = "nats.SendCancel"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := n.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	if err := n.conn.Publish(n.cancelSubject(), []byte(taskID)); err != nil {
+		return fmt.Errorf("%s request_id=%s failed to publish cancel signal: %v", op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (n *NatsMQ) SubscribeCancel(_ context.Context, cancelChan chan string) (context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sub, err := n.conn.Subscribe(n.cancelSubject(), func(msg *nats.Msg) {
+		n.log.Debug("mq receiver cancel signal", slog.String("task_id", string(msg.Data)))
+
+		select {
+		case cancelChan <- string(msg.Data):
+		case <-ctx.Done():
+		}
+	})
+
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to subcribe cancel subject: %v", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := sub.Unsubscribe(); err != nil {
+			n.log.Error("failed to unsubscribe cancel subject", slog.String("error", err.Error()))
+		}
+	}()
+
+	return cancel, nil
+}
+
+func (n *NatsMQ) SendCompletion(ctx context.Context, completion models.TaskCompletion) error {
+	const op = "nats.SendCompletion"
+
+	log := n.log.With(slog.String("op", op), slog.String("task_id", completion.TaskID))
+	log.DebugContext(ctx, "start operation")
+
+	msg, err := json.Marshal(completion)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to marshal completion: %v", op, completion.TaskID, err)
+	}
+
+	if err := n.conn.Publish(n.completionSubject(), msg); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to publish completion: %v", op, completion.TaskID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (n *NatsMQ) SubscribeCompletions(_ context.Context, completionChan chan models.TaskCompletion) (
+	context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sub, err := n.conn.Subscribe(n.completionSubject(), func(msg *nats.Msg) {
+		completion := models.TaskCompletion{}
+		if err := json.Unmarshal(msg.Data, &completion); err != nil {
+			n.log.Error("failed to unmrashelled completion message", slog.String("message_body", string(msg.Data)))
+			return
+		}
+
+		select {
+		case completionChan <- completion:
+		case <-ctx.Done():
+		}
+	})
+
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to subcribe completion subject: %v", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := sub.Unsubscribe(); err != nil {
+			n.log.Error("failed to unsubscribe completion subject", slog.String("error", err.Error()))
+		}
+	}()
+
+	return cancel, nil
+}
+
+func (n *NatsMQ) SendDeadLetter(ctx context.Context, deadLetter models.DeadLetter) error {
+	const op = "nats.SendDeadLetter"
+
+	log := n.log.With(slog.String("op", op), slog.String("dead_letter_id", deadLetter.ID))
+	log.DebugContext(ctx, "start operation")
+
+	msg, err := json.Marshal(deadLetter)
+	if err != nil {
+		return fmt.Errorf("%s dead_letter_id=%s failed to marshal dead letter: %v", op, deadLetter.ID, err)
+	}
+
+	if err := n.conn.Publish(n.deadLetterSubject(), msg); err != nil {
+		return fmt.Errorf("%s dead_letter_id=%s failed to publish dead letter: %v", op, deadLetter.ID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+// SubscribeDeadLetters не подтверждает записи: в core NATS запись, которую handle не смог обработать, теряется.
+func (n *NatsMQ) SubscribeDeadLetters(_ context.Context,
+	handle func(ctx context.Context, deadLetter models.DeadLetter) error) (context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sub, err := n.conn.QueueSubscribe(n.deadLetterSubject(), deadLetterQueueGroup, func(msg *nats.Msg) {
+		deadLetter := models.DeadLetter{}
+		if err := json.Unmarshal(msg.Data, &deadLetter); err != nil {
+			n.log.Error("failed to unmrashelled dead letter", slog.String("message_body", string(msg.Data)))
+			return
+		}
+
+		if err := handle(ctx, deadLetter); err != nil {
+			n.log.Error("dead letter lost", slog.String("dead_letter_id", deadLetter.ID),
+				slog.String("error", err.Error()))
+		}
+	})
+
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to subcribe dead letter subject: %v", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := sub.Unsubscribe(); err != nil {
+			n.log.Error("failed to unsubscribe dead letter subject", slog.String("error", err.Error()))
+		}
 	}()
 
 	return cancel, nil
@@ -90,3 +329,15 @@ func (n *NatsMQ) Close(_ context.Context) error {
 	n.conn.Close()
 	return nil
 }
+
+func (n *NatsMQ) cancelSubject() string {
+	return n.queueName + ".cancel"
+}
+
+func (n *NatsMQ) completionSubject() string {
+	return n.queueName + ".completed"
+}
+
+func (n *NatsMQ) deadLetterSubject() string {
+	return n.queueName + ".dead"
+}
@@ -0,0 +1,53 @@
+package mq
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNatsMQ_SubscribeCancel(t *testing.T) {
+	cfg := newJetStreamConfig(t)
+
+	receiver, err := NewNatsMQ(cfg, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = receiver.Close(context.Background()) })
+
+	observer, err := nats.Connect(cfg.NatsDNS())
+	require.NoError(t, err)
+	t.Cleanup(observer.Close)
+	published, err := observer.SubscribeSync(cfg.NatsTaskQueueName)
+	require.NoError(t, err)
+	require.NoError(t, observer.Flush())
+
+	taskChan := make(chan models.TaskMessage)
+	cancel, err := receiver.Subscribe(context.Background(), taskChan)
+	require.NoError(t, err)
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET", Attempt: 1}
+	require.NoError(t, receiver.SendTask(newContextWithRequestID(), task))
+
+	_, err = published.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+
+	// Задачу никто не читает из taskChan: при отмене подписки она публикуется заново, а канал закрывается.
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	msg, err := published.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	require.JSONEq(t, mustMarshal(t, task), string(msg.Data))
+
+	select {
+	case _, ok := <-taskChan:
+		require.False(t, ok)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "task channel was not closed")
+	}
+}
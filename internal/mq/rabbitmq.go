@@ -3,8 +3,11 @@ package mq
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"time"
 
 	"github.com/ASsssker/proxy/internal/config"
 	"github.com/ASsssker/proxy/internal/models"
@@ -12,11 +15,16 @@ import (
 	"github.com/rabbitmq/amqp091-go"
 )
 
+const delayQueueIdleSeconds = 600
+
 type RabbitMQ struct {
-	conn      *amqp091.Connection
-	ch        *amqp091.Channel
-	queueName string
-	log       *slog.Logger
+	conn               *amqp091.Connection
+	ch                 *amqp091.Channel
+	queueName          string
+	cancelExchange     string
+	completionExchange string
+	deadLetterQueue    string
+	log                *slog.Logger
 }
 
 func NewRabbitMQ(cfg config.Config, log *slog.Logger) (*RabbitMQ, error) {
@@ -35,15 +43,42 @@ func NewRabbitMQ(cfg config.Config, log *slog.Logger) (*RabbitMQ, error) {
 		return nil, fmt.Errorf("failed to create rabbitMQ queue: %v", err)
 	}
 
-	if err := ch.Qos(int(cfg.RequesterWorkersCount), 0, false); err != nil {
+	cancelExchange := cfg.RabbitTaskQueueName + ".cancel"
+	if err := ch.ExchangeDeclare(cancelExchange, amqp091.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to create rabbitMQ cancel exchange: %v", err)
+	}
+
+	completionExchange := cfg.RabbitTaskQueueName + ".completed"
+	if err := ch.ExchangeDeclare(completionExchange, amqp091.ExchangeFanout, true, false, false, false,
+		nil); err != nil {
+		return nil, fmt.Errorf("failed to create rabbitMQ completion exchange: %v", err)
+	}
+
+	deadLetterQueue := cfg.RabbitTaskQueueName + ".dead"
+	if _, err := ch.QueueDeclare(deadLetterQueue, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to create rabbitMQ dead letter queue: %v", err)
+	}
+
+	if err := ch.Qos(cfg.RequesterPrefetch(), 0, false); err != nil {
 		return nil, fmt.Errorf("failed to set rabbitMQ QOS settigns: %v", err)
 	}
 
+	// В режиме подтверждений публикация дожидается, пока брокер примет сообщение, иначе outbox считал бы
+	// опубликованной задачу, которая только записана в сокет, а повтор подтверждал бы исходное сообщение раньше,
+	// чем брокер примет следующую попытку. Режим включается для всего канала, поэтому подтверждения ждут все
+	// публикации: отдельный канал без подтверждений ради сигналов отмены и завершения того не стоит.
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("failed to enable rabbitMQ publisher confirms: %v", err)
+	}
+
 	return &RabbitMQ{
-		conn:      conn,
-		ch:        ch,
-		queueName: cfg.RabbitTaskQueueName,
-		log:       log,
+		conn:               conn,
+		ch:                 ch,
+		queueName:          cfg.RabbitTaskQueueName,
+		cancelExchange:     cancelExchange,
+		completionExchange: completionExchange,
+		deadLetterQueue:    deadLetterQueue,
+		log:                log,
 	}, nil
 }
 
@@ -59,20 +94,117 @@ func (r *RabbitMQ) SendTask(ctx context.Context, task models.Task) error {
 		return fmt.Errorf("%s request_id=%s failed to marshal task: %v", op, requestID, err)
 	}
 
-	err = r.ch.PublishWithContext(
+	confirmation, err := r.publishTask(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("%s request_id=%s failed to publish task: %v", op, requestID, err)
+	}
+
+	if err := waitConfirmation(ctx, confirmation); err != nil {
+		return fmt.Errorf("%s request_id=%s %v", op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (r *RabbitMQ) SendTasks(ctx context.Context, tasks []models.Task) []error {
+	const op = "rabbitMQ.SendTasks"
+
+	log := r.log.With(slog.String("op", op), slog.Int("tasks", len(tasks)))
+	log.DebugContext(ctx, "start operation")
+
+	errs := make([]error, len(tasks))
+	confirmations := make([]*amqp091.DeferredConfirmation, len(tasks))
+	for i, task := range tasks {
+		msg, err := json.Marshal(task)
+		if err != nil {
+			errs[i] = fmt.Errorf("%s task_id=%s failed to marshal task: %v", op, task.ID, err)
+			continue
+		}
+
+		if confirmations[i], err = r.publishTask(ctx, msg); err != nil {
+			errs[i] = fmt.Errorf("%s task_id=%s failed to publish task: %v", op, task.ID, err)
+		}
+	}
+
+	for i, confirmation := range confirmations {
+		if confirmation == nil {
+			continue
+		}
+
+		if err := waitConfirmation(ctx, confirmation); err != nil {
+			errs[i] = fmt.Errorf("%s task_id=%s %v", op, tasks[i].ID, err)
+		}
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return errs
+}
+
+func (r *RabbitMQ) publishTask(ctx context.Context, msg []byte) (*amqp091.DeferredConfirmation, error) {
+	return r.ch.PublishWithDeferredConfirmWithContext(ctx, "", r.queueName, false, false, amqp091.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp091.Persistent,
+		Body:         msg,
+	})
+}
+
+func (r *RabbitMQ) publish(ctx context.Context, exchange, key string, msg amqp091.Publishing) error {
+	confirmation, err := r.ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, msg)
+	if err != nil {
+		return err
+	}
+
+	return waitConfirmation(ctx, confirmation)
+}
+
+func waitConfirmation(ctx context.Context, confirmation *amqp091.DeferredConfirmation) error {
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait publish confirmation: %v", err)
+	}
+
+	if !acked {
+		return errors.New("message was rejected by broker")
+	}
+
+	return nil
+}
+
+// RequeueTask публикует задачу в очередь задержки с TTL, равным delay (с точностью до секунды).
+// По истечении TTL RabbitMQ перекладывает сообщение обратно в очередь задач через dead-letter.
+// Для каждой задержки используется своя очередь, чтобы короткие задержки не ждали за длинными.
+func (r *RabbitMQ) RequeueTask(ctx context.Context, task models.Task, delay time.Duration) error {
+	const op = "rabbitMQ.RequeueTask"
+
+	log := r.log.With(slog.String("op", op), slog.String("task_id", task.ID))
+	log.DebugContext(ctx, "start operation")
+
+	msg, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to marshal task: %v", op, task.ID, err)
+	}
+
+	delayQueue, err := r.declareDelayQueue(delay)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to declare delay queue: %v", op, task.ID, err)
+	}
+
+	err = r.publish(
 		ctx,
 		"",
-		r.queueName,
-		false,
-		false,
+		delayQueue,
 		amqp091.Publishing{
-			ContentType: "application/json",
-			Body:        msg,
+			ContentType:  "application/json",
+			DeliveryMode: amqp091.Persistent,
+			Body:         msg,
 		},
 	)
 
 	if err != nil {
-		return fmt.Errorf("%s request_id=%s failed to publish task: %v", op, requestID, err)
+		return fmt.Errorf("%s task_id=%s failed to publish delayed task: %v", op, task.ID, err)
 	}
 
 	log.DebugContext(ctx, "the operation was successfully completed")
@@ -80,11 +212,12 @@ func (r *RabbitMQ) SendTask(ctx context.Context, task models.Task) error {
 	return nil
 }
 
-func (r *RabbitMQ) Subscribe(_ context.Context, taskChan chan models.Task) (context.CancelFunc, error) {
+// Subscribe ограничивает число неподтверждённых сообщений через QOS, равный RequesterPrefetch.
+func (r *RabbitMQ) Subscribe(_ context.Context, taskChan chan models.TaskMessage) (context.CancelFunc, error) {
 	msgChan, err := r.ch.Consume(
 		r.queueName,
 		"",
-		true,
+		false,
 		false,
 		false,
 		false,
@@ -100,18 +233,32 @@ func (r *RabbitMQ) Subscribe(_ context.Context, taskChan chan models.Task) (cont
 	go func() {
 		for {
 			select {
-			case msg := <-msgChan:
+			case msg, ok := <-msgChan:
+				if !ok {
+					close(taskChan)
+					return
+				}
+
 				r.log.Debug("mq receiver message", slog.String("message_body", string(msg.Body)))
 
 				task := models.Task{}
 				if err := json.Unmarshal(msg.Body, &task); err != nil {
 					r.log.Error("failed to unmrashelled mq message", slog.String("message_body", string(msg.Body)))
+					r.reject(ctx, msg, invalidMessage(msg.Body, err))
 					continue
 				}
 
+				taskMsg := models.NewTaskMessage(task,
+					func() error { return msg.Ack(false) },
+					func(requeue bool) error { return msg.Nack(false, requeue) },
+				)
+
 				select {
-				case taskChan <- task:
+				case taskChan <- taskMsg:
 				case <-ctx.Done():
+					if err := msg.Nack(false, true); err != nil {
+						r.log.Error("failed to requeue mq message", slog.String("error", err.Error()))
+					}
 					close(taskChan)
 					return
 				}
@@ -126,6 +273,228 @@ func (r *RabbitMQ) Subscribe(_ context.Context, taskChan chan models.Task) (cont
 	return cancel, nil
 }
 
+func (r *RabbitMQ) SendCancel(ctx context.Context, taskID string) error {
+	const op = "rabbitMQ.SendCancel"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := r.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	err := r.publish(
+		ctx,
+		r.cancelExchange,
+		"",
+		amqp091.Publishing{
+			ContentType: "text/plain",
+			Body:        []byte(taskID),
+		},
+	)
+
+	if err != nil {
+		return fmt.Errorf("%s request_id=%s failed to publish cancel signal: %v", op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (r *RabbitMQ) SubscribeCancel(_ context.Context, cancelChan chan string) (context.CancelFunc, error) {
+	msgChan, err := r.consumeExchange(r.cancelExchange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cunsume cancel exchange: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for {
+			select {
+			case msg, ok := <-msgChan:
+				if !ok {
+					return
+				}
+
+				r.log.Debug("mq receiver cancel signal", slog.String("task_id", string(msg.Body)))
+
+				select {
+				case cancelChan <- string(msg.Body):
+				case <-ctx.Done():
+					return
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return cancel, nil
+}
+
+func (r *RabbitMQ) SendCompletion(ctx context.Context, completion models.TaskCompletion) error {
+	const op = "rabbitMQ.SendCompletion"
+
+	log := r.log.With(slog.String("op", op), slog.String("task_id", completion.TaskID))
+	log.DebugContext(ctx, "start operation")
+
+	msg, err := json.Marshal(completion)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to marshal completion: %v", op, completion.TaskID, err)
+	}
+
+	err = r.publish(
+		ctx,
+		r.completionExchange,
+		"",
+		amqp091.Publishing{
+			ContentType: "application/json",
+			Body:        msg,
+		},
+	)
+
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to publish completion: %v", op, completion.TaskID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (r *RabbitMQ) SubscribeCompletions(_ context.Context, completionChan chan models.TaskCompletion) (
+	context.CancelFunc, error) {
+	msgChan, err := r.consumeExchange(r.completionExchange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cunsume completion exchange: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for {
+			select {
+			case msg, ok := <-msgChan:
+				if !ok {
+					return
+				}
+
+				completion := models.TaskCompletion{}
+				if err := json.Unmarshal(msg.Body, &completion); err != nil {
+					r.log.Error("failed to unmrashelled completion message", slog.String("message_body", string(msg.Body)))
+					continue
+				}
+
+				select {
+				case completionChan <- completion:
+				case <-ctx.Done():
+					return
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return cancel, nil
+}
+
+func (r *RabbitMQ) SendDeadLetter(ctx context.Context, deadLetter models.DeadLetter) error {
+	const op = "rabbitMQ.SendDeadLetter"
+
+	log := r.log.With(slog.String("op", op), slog.String("dead_letter_id", deadLetter.ID))
+	log.DebugContext(ctx, "start operation")
+
+	msg, err := json.Marshal(deadLetter)
+	if err != nil {
+		return fmt.Errorf("%s dead_letter_id=%s failed to marshal dead letter: %v", op, deadLetter.ID, err)
+	}
+
+	err = r.publish(
+		ctx,
+		"",
+		r.deadLetterQueue,
+		amqp091.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp091.Persistent,
+			MessageId:    deadLetter.ID,
+			Body:         msg,
+		},
+	)
+
+	if err != nil {
+		return fmt.Errorf("%s dead_letter_id=%s failed to publish dead letter: %v", op, deadLetter.ID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (r *RabbitMQ) SubscribeDeadLetters(_ context.Context,
+	handle func(ctx context.Context, deadLetter models.DeadLetter) error) (context.CancelFunc, error) {
+	msgChan, err := r.ch.Consume(
+		r.deadLetterQueue,
+		"",
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to cunsume dead letter queue: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for {
+			select {
+			case msg, ok := <-msgChan:
+				if !ok {
+					return
+				}
+
+				deadLetter := models.DeadLetter{}
+				if err := json.Unmarshal(msg.Body, &deadLetter); err != nil {
+					r.log.Error("failed to unmrashelled dead letter", slog.String("message_body", string(msg.Body)))
+					if err := msg.Nack(false, false); err != nil {
+						r.log.Error("failed to reject mq message", slog.String("error", err.Error()))
+					}
+					continue
+				}
+
+				if err := handle(ctx, deadLetter); err != nil {
+					r.log.Error("failed to handle dead letter", slog.String("dead_letter_id", deadLetter.ID),
+						slog.String("error", err.Error()))
+
+					select {
+					case <-time.After(deadLetterRetryDelay):
+					case <-ctx.Done():
+					}
+
+					if err := msg.Nack(false, true); err != nil {
+						r.log.Error("failed to requeue mq message", slog.String("error", err.Error()))
+					}
+					continue
+				}
+
+				if err := msg.Ack(false); err != nil {
+					r.log.Error("failed to ack mq message", slog.String("error", err.Error()))
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return cancel, nil
+}
+
 func (r *RabbitMQ) Close(_ context.Context) error {
 	if errChanClose := r.ch.Close(); errChanClose != nil {
 		if err := r.conn.Close(); err != nil {
@@ -141,3 +510,57 @@ func (r *RabbitMQ) Close(_ context.Context) error {
 
 	return nil
 }
+
+// reject возвращает сообщение в очередь задач, если dead-letter запись не удалось записать.
+func (r *RabbitMQ) reject(ctx context.Context, msg amqp091.Delivery, deadLetter models.DeadLetter) {
+	if err := r.SendDeadLetter(ctx, deadLetter); err != nil {
+		r.log.Error("failed to send message to dead letters", slog.String("error", err.Error()))
+		if err := msg.Nack(false, true); err != nil {
+			r.log.Error("failed to requeue mq message", slog.String("error", err.Error()))
+		}
+		return
+	}
+
+	if err := msg.Ack(false); err != nil {
+		r.log.Error("failed to ack mq message", slog.String("error", err.Error()))
+	}
+}
+
+func (r *RabbitMQ) declareDelayQueue(delay time.Duration) (string, error) {
+	seconds := max(int64(math.Ceil(delay.Seconds())), 1)
+	name := fmt.Sprintf("%s.delay.%ds", r.queueName, seconds)
+
+	_, err := r.ch.QueueDeclare(name, true, false, false, false, amqp091.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": r.queueName,
+		"x-message-ttl":             seconds * 1000,
+		// Неиспользуемая очередь задержки удаляется брокером.
+		"x-expires": (seconds + delayQueueIdleSeconds) * 1000,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+func (r *RabbitMQ) consumeExchange(exchange string) (<-chan amqp091.Delivery, error) {
+	queue, err := r.ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create queue: %v", err)
+	}
+
+	if err := r.ch.QueueBind(queue.Name, "", exchange, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to bind queue: %v", err)
+	}
+
+	return r.ch.Consume(
+		queue.Name,
+		"",
+		true,
+		true,
+		false,
+		false,
+		nil,
+	)
+}
@@ -0,0 +1,243 @@
+// Package netpolicy ограничивает адреса, к которым сервис может подключаться от имени клиентов.
+// Проверка IP выполняется в net.Dialer.Control, то есть уже после разрешения имени, поэтому
+// DNS rebinding и адреса-литералы проверяются так же, как обычные имена.
+package netpolicy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+)
+
+var ErrBlocked = errors.New("destination blocked by network policy")
+
+// defaultDenyCIDRs - адреса, которые никогда не должны быть доступны через прокси:
+// loopback, частные сети, link-local (включая метаданные облаков), CGNAT, служебные и multicast диапазоны.
+// NAT64 и 6to4 закрыты целиком: через шлюз они ведут на любые IPv4 адреса, в том числе внутренние.
+var defaultDenyCIDRs = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"64:ff9b:1::/48",
+	"2002::/16",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+type Policy struct {
+	denyNets     []netip.Prefix
+	allowNets    []netip.Prefix
+	denyHosts    []string
+	allowHosts   []string
+	blockedPorts map[int]struct{}
+	dialer       *net.Dialer
+}
+
+// New собирает политику из конфигурации. К REQUESTER_DENY_CIDRS всегда добавляются defaultDenyCIDRs,
+// а REQUESTER_ALLOW_CIDRS задаёт исключения из запрещённых сетей.
+func New(cfg config.Config) (*Policy, error) {
+	denyNets, err := parsePrefixes(append(append([]string(nil), defaultDenyCIDRs...), cfg.RequesterDenyCIDRs...))
+	if err != nil {
+		return nil, err
+	}
+
+	allowNets, err := parsePrefixes(cfg.RequesterAllowCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
+	denyHosts, err := parseHostPatterns(cfg.RequesterDenyHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	allowHosts, err := parseHostPatterns(cfg.RequesterAllowHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	blockedPorts := make(map[int]struct{}, len(cfg.RequesterBlockedPorts))
+	for _, port := range cfg.RequesterBlockedPorts {
+		blockedPorts[port] = struct{}{}
+	}
+
+	p := &Policy{
+		denyNets:     denyNets,
+		allowNets:    allowNets,
+		denyHosts:    denyHosts,
+		allowHosts:   allowHosts,
+		blockedPorts: blockedPorts,
+	}
+	p.dialer = &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   p.control,
+	}
+
+	return p, nil
+}
+
+// Transport возвращает http.Transport, все соединения которого проходят через политику.
+// Прокси из окружения отключён: иначе проверялся бы адрес прокси, а не конечного сервера.
+func (p *Policy) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = p.DialContext
+
+	return transport
+}
+
+func (p *Policy) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := splitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.CheckHost(host); err != nil {
+		return nil, err
+	}
+
+	if err := p.checkPort(port); err != nil {
+		return nil, err
+	}
+
+	return p.dialer.DialContext(ctx, network, address)
+}
+
+// CheckHost проверяет имя хоста по спискам шаблонов. Шаблоны сравниваются через path.Match,
+// поэтому "*.example.com" совпадает и с поддоменами любой вложенности.
+func (p *Policy) CheckHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, pattern := range p.denyHosts {
+		if matched, _ := path.Match(pattern, host); matched {
+			return fmt.Errorf("host %q matches deny rule %q: %w", host, pattern, ErrBlocked)
+		}
+	}
+
+	if len(p.allowHosts) == 0 {
+		return nil
+	}
+
+	for _, pattern := range p.allowHosts {
+		if matched, _ := path.Match(pattern, host); matched {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("host %q does not match any allow rule: %w", host, ErrBlocked)
+}
+
+// CheckAddr проверяет уже разрешённый адрес назначения.
+func (p *Policy) CheckAddr(addr netip.Addr, port int) error {
+	if err := p.checkPort(port); err != nil {
+		return err
+	}
+
+	addr = addr.Unmap().WithZone("")
+
+	for _, prefix := range p.allowNets {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+
+	for _, prefix := range p.denyNets {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("address %s is in denied network %s: %w", addr, prefix, ErrBlocked)
+		}
+	}
+
+	return nil
+}
+
+func (p *Policy) checkPort(port int) error {
+	if _, ok := p.blockedPorts[port]; ok {
+		return fmt.Errorf("port %d is blocked: %w", port, ErrBlocked)
+	}
+
+	return nil
+}
+
+func (p *Policy) control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("unexpected dial address %q: %w", address, ErrBlocked)
+	}
+
+	return p.CheckAddr(addrPort.Addr(), int(addrPort.Port()))
+}
+
+func splitHostPort(address string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid dial address %q: %v", address, err)
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid dial port %q: %v", portStr, err)
+	}
+
+	return host, port, nil
+}
+
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q: %v", value, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+func parseHostPatterns(values []string) ([]string, error) {
+	patterns := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("invalid host pattern %q: %v", value, err)
+		}
+		patterns = append(patterns, value)
+	}
+
+	return patterns, nil
+}
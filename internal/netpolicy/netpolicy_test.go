@@ -0,0 +1,144 @@
+package netpolicy
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strconv"
+	"testing"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAddr(t *testing.T) {
+	policy, err := New(config.Config{RequesterServiceConfig: config.RequesterServiceConfig{
+		RequesterDenyCIDRs:    []string{"8.8.4.0/24"},
+		RequesterAllowCIDRs:   []string{"10.1.0.0/16"},
+		RequesterBlockedPorts: []int{25},
+	}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		addr    string
+		port    int
+		blocked bool
+	}{
+		{name: "public address", addr: "93.184.216.34", port: 443},
+		{name: "loopback", addr: "127.0.0.1", port: 80, blocked: true},
+		{name: "cloud metadata", addr: "169.254.169.254", port: 80, blocked: true},
+		{name: "private network", addr: "192.168.1.10", port: 80, blocked: true},
+		{name: "ipv6 loopback", addr: "::1", port: 80, blocked: true},
+		{name: "ipv4-mapped loopback", addr: "::ffff:127.0.0.1", port: 80, blocked: true},
+		{name: "ipv6 unique local", addr: "fd00::1", port: 80, blocked: true},
+		{name: "nat64 loopback", addr: "64:ff9b::7f00:1", port: 80, blocked: true},
+		{name: "local-use nat64", addr: "64:ff9b:1::a00:1", port: 80, blocked: true},
+		{name: "6to4 private network", addr: "2002:c0a8:10a::1", port: 80, blocked: true},
+		{name: "configured deny cidr", addr: "8.8.4.4", port: 53, blocked: true},
+		{name: "allowed private subnet", addr: "10.1.2.3", port: 80},
+		{name: "private address outside of allowed subnet", addr: "10.2.0.1", port: 80, blocked: true},
+		{name: "blocked port", addr: "93.184.216.34", port: 25, blocked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.CheckAddr(netip.MustParseAddr(tt.addr), tt.port)
+			if tt.blocked {
+				require.ErrorIs(t, err, ErrBlocked)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	tests := []struct {
+		name       string
+		denyHosts  []string
+		allowHosts []string
+		host       string
+		blocked    bool
+	}{
+		{name: "no rules", host: "example.com"},
+		{name: "deny glob", denyHosts: []string{"*.internal"}, host: "db.internal", blocked: true},
+		{name: "deny glob is case insensitive", denyHosts: []string{"*.Internal"}, host: "DB.internal.", blocked: true},
+		{name: "host outside deny glob", denyHosts: []string{"*.internal"}, host: "example.com"},
+		{name: "allow glob", allowHosts: []string{"*.example.com"}, host: "api.example.com"},
+		{name: "host outside allow glob", allowHosts: []string{"*.example.com"}, host: "example.org", blocked: true},
+		{
+			name:       "deny has priority over allow",
+			denyHosts:  []string{"admin.example.com"},
+			allowHosts: []string{"*.example.com"},
+			host:       "admin.example.com",
+			blocked:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := New(config.Config{RequesterServiceConfig: config.RequesterServiceConfig{
+				RequesterDenyHosts:  tt.denyHosts,
+				RequesterAllowHosts: tt.allowHosts,
+			}})
+			require.NoError(t, err)
+
+			err = policy.CheckHost(tt.host)
+			if tt.blocked {
+				require.ErrorIs(t, err, ErrBlocked)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+
+	policy, err := New(config.Config{})
+	require.NoError(t, err)
+
+	// Имя проходит проверку шаблонов и блокируется только после разрешения в адрес loopback.
+	_, err = policy.DialContext(context.Background(), "tcp", net.JoinHostPort("localhost", port))
+	require.ErrorIs(t, err, ErrBlocked)
+
+	_, err = policy.DialContext(context.Background(), "tcp", net.JoinHostPort("127.0.0.1", port))
+	require.ErrorIs(t, err, ErrBlocked)
+
+	policy, err = New(config.Config{RequesterServiceConfig: config.RequesterServiceConfig{
+		RequesterAllowCIDRs: []string{"127.0.0.1/32"},
+	}})
+	require.NoError(t, err)
+
+	conn, err := policy.DialContext(context.Background(), "tcp", net.JoinHostPort("127.0.0.1", port))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+}
+
+func TestNew_InvalidRules(t *testing.T) {
+	_, err := New(config.Config{RequesterServiceConfig: config.RequesterServiceConfig{
+		RequesterDenyCIDRs: []string{"10.0.0.0/33"},
+	}})
+	require.Error(t, err)
+
+	_, err = New(config.Config{RequesterServiceConfig: config.RequesterServiceConfig{
+		RequesterAllowHosts: []string{"[example.com"},
+	}})
+	require.Error(t, err)
+}
@@ -3,8 +3,12 @@ package v1
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ASsssker/proxy/internal/models"
 	prom "github.com/ASsssker/proxy/internal/monitoring/prometheus"
@@ -13,8 +17,18 @@ import (
 )
 
 type ProxyService interface {
-	AddTask(ctx context.Context, newTask models.NewTask) (string, error)
-	GetTaskInfo(ctx context.Context, taskID string) (models.TaskResult, error)
+	AddTask(ctx context.Context, newTask models.NewTask, idempotencyKey string) (string, bool, error)
+	WaitTask(ctx context.Context, taskID string, timeout time.Duration) (models.TaskResult, bool, error)
+	GetTaskInfo(ctx context.Context, taskID string, omitBody bool) (models.TaskResult, error)
+	GetTaskBody(ctx context.Context, taskID string) (models.TaskBody, error)
+	GetTaskAttempts(ctx context.Context, taskID string) (models.TaskAttemptList, error)
+	AddTasks(ctx context.Context, batch models.NewTaskBatch) (models.TaskBatchList, error)
+	ListTasks(ctx context.Context, filter models.TaskFilter) (models.TaskList, error)
+	CancelTask(ctx context.Context, taskID string) error
+	ListDeadLetters(ctx context.Context, filter models.DeadLetterFilter) (models.DeadLetterList, error)
+	GetDeadLetter(ctx context.Context, id string) (models.DeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, id string) (models.DeadLetter, error)
+	ReplayDeadLetters(ctx context.Context, replay models.DeadLetterReplay) (models.DeadLetterReplayList, error)
 }
 
 type Handler struct {
@@ -28,27 +42,94 @@ func Register(server gin.IRouter, log *slog.Logger, proxyService ProxyService) {
 			Middlewares: []MiddlewareFunc{
 				MiddlewareFunc(RequestDurationMiddleware()),
 				MiddlewareFunc(RequestIDMiddleware()),
-			}})
+			},
+			ErrorHandler: func(ctx *gin.Context, err error, statusCode int) {
+				ctx.JSON(statusCode, newErrorResponse(statusCode, err.Error()))
+			},
+		})
 }
 
-func (h Handler) AddTask(ctx *gin.Context) {
+func (h Handler) AddTask(ctx *gin.Context, params AddTaskParams) {
+	wait, preferApplied, err := parseWait(params)
+	if err != nil {
+		h.handlingError(ctx, err)
+		return
+	}
+
 	var newTask models.NewTask
 	if err := ctx.ShouldBindBodyWithJSON(&newTask); err != nil {
 		h.handlingError(ctx, err)
 		return
 	}
 
-	taskID, err := h.proxyService.AddTask(ctx, newTask)
+	var idempotencyKey string
+	if params.IdempotencyKey != nil {
+		idempotencyKey = *params.IdempotencyKey
+	}
+
+	taskID, replayed, err := h.proxyService.AddTask(ctx, newTask, idempotencyKey)
 	if err != nil {
 		h.handlingError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{"id": taskID})
+	if replayed {
+		ctx.JSON(http.StatusOK, gin.H{"id": taskID})
+		return
+	}
+
+	if wait == 0 {
+		ctx.JSON(http.StatusCreated, gin.H{"id": taskID})
+		return
+	}
+
+	if preferApplied {
+		ctx.Header("Preference-Applied", fmt.Sprintf("wait=%d", int(wait.Seconds())))
+	}
+
+	taskInfo, completed, err := h.proxyService.WaitTask(ctx, taskID, wait)
+	if err != nil {
+		h.handlingError(ctx, err)
+		return
+	}
+
+	if !completed {
+		ctx.JSON(http.StatusAccepted, gin.H{"id": taskID})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, taskInfo)
 }
 
-func (h Handler) GetTaskResult(ctx *gin.Context, id string) {
-	taskInfo, err := h.proxyService.GetTaskInfo(ctx, id)
+// batchAction - значение параметра пути batch для маршрута /v1/tasks:batch. Gin считает ":batch" параметром,
+// поэтому маршрут совпадает с любым путём /v1/tasks<...>, и лишние пути отсекаются здесь.
+const batchAction = ":batch"
+
+func (h Handler) AddTasksBatch(ctx *gin.Context) {
+	if ctx.Param("batch") != batchAction {
+		ctx.JSON(http.StatusNotFound, newErrorResponse(http.StatusNotFound, http.StatusText(http.StatusNotFound)))
+		return
+	}
+
+	var batch models.NewTaskBatch
+	if err := ctx.ShouldBindBodyWithJSON(&batch); err != nil {
+		h.handlingError(ctx, fmt.Errorf("invalid task batch: %w: %w", services.ErrValidation, err))
+		return
+	}
+
+	batchList, err := h.proxyService.AddTasks(ctx, batch)
+	if err != nil {
+		h.handlingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, batchList)
+}
+
+func (h Handler) GetTaskResult(ctx *gin.Context, id string, params GetTaskResultParams) {
+	omitBody := params.OmitBody != nil && *params.OmitBody
+
+	taskInfo, err := h.proxyService.GetTaskInfo(ctx, id, omitBody)
 	if err != nil {
 		h.handlingError(ctx, err)
 		return
@@ -57,6 +138,104 @@ func (h Handler) GetTaskResult(ctx *gin.Context, id string) {
 	ctx.JSON(http.StatusOK, taskInfo)
 }
 
+func (h Handler) GetTaskBody(ctx *gin.Context, id string) {
+	taskBody, err := h.proxyService.GetTaskBody(ctx, id)
+	if err != nil {
+		h.handlingError(ctx, err)
+		return
+	}
+	defer taskBody.Content.Close()
+
+	headers := http.Header(taskBody.Headers)
+	contentType := headers.Get("Content-Type")
+	if contentType == "" {
+		// Без явного типа http.ServeContent попытается угадать его по содержимому.
+		contentType = "application/octet-stream"
+	}
+	ctx.Header("Content-Type", contentType)
+	if contentEncoding := headers.Get("Content-Encoding"); contentEncoding != "" {
+		ctx.Header("Content-Encoding", contentEncoding)
+	}
+	ctx.Header("ETag", taskBodyETag(id, taskBody.UpdatedAt))
+
+	http.ServeContent(ctx.Writer, ctx.Request, "", taskBody.UpdatedAt, taskBody.Content)
+}
+
+func (h Handler) GetTaskAttempts(ctx *gin.Context, id string) {
+	attempts, err := h.proxyService.GetTaskAttempts(ctx, id)
+	if err != nil {
+		h.handlingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, attempts)
+}
+
+func (h Handler) CancelTask(ctx *gin.Context, id string) {
+	if err := h.proxyService.CancelTask(ctx, id); err != nil {
+		h.handlingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"id": id, "status": models.StatusCancelled})
+}
+
+func (h Handler) ListTasks(ctx *gin.Context, params ListTasksParams) {
+	taskList, err := h.proxyService.ListTasks(ctx, toTaskFilter(params))
+	if err != nil {
+		h.handlingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, taskList)
+}
+
+func (h Handler) ListDeadLetters(ctx *gin.Context, params ListDeadLettersParams) {
+	deadLetterList, err := h.proxyService.ListDeadLetters(ctx, toDeadLetterFilter(params))
+	if err != nil {
+		h.handlingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, deadLetterList)
+}
+
+func (h Handler) GetDeadLetter(ctx *gin.Context, id string) {
+	deadLetter, err := h.proxyService.GetDeadLetter(ctx, id)
+	if err != nil {
+		h.handlingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, deadLetter)
+}
+
+func (h Handler) ReplayDeadLetter(ctx *gin.Context, id string) {
+	deadLetter, err := h.proxyService.ReplayDeadLetter(ctx, id)
+	if err != nil {
+		h.handlingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, deadLetter)
+}
+
+func (h Handler) ReplayDeadLetters(ctx *gin.Context) {
+	var replay models.DeadLetterReplay
+	if err := ctx.ShouldBindBodyWithJSON(&replay); err != nil {
+		h.handlingError(ctx, fmt.Errorf("invalid replay request: %w: %w", services.ErrValidation, err))
+		return
+	}
+
+	replayList, err := h.proxyService.ReplayDeadLetters(ctx, replay)
+	if err != nil {
+		h.handlingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, replayList)
+}
+
 func (h Handler) PingService(ctx *gin.Context) {
 	prom.ProxyPingCounter.Inc()
 	ctx.JSON(http.StatusOK, gin.H{"status": "service started"})
@@ -86,6 +265,27 @@ func (h Handler) handlingError(ctx *gin.Context, err error) {
 		h.log.Debug("task not found", slog.String(services.RequestIDKey, requestIDWithStr))
 		ctx.JSON(http.StatusNotFound, newErrorResponse(http.StatusNotFound, "task not found"))
 
+	case errors.Is(err, services.ErrTaskNotCancellable):
+		h.log.Debug("task cannot be cancelled", slog.String(services.RequestIDKey, requestIDWithStr))
+		ctx.JSON(http.StatusConflict, newErrorResponse(http.StatusConflict, "task already finalized"))
+
+	case errors.Is(err, services.ErrTaskNotCompleted):
+		h.log.Debug("task not completed", slog.String(services.RequestIDKey, requestIDWithStr))
+		ctx.JSON(http.StatusConflict, newErrorResponse(http.StatusConflict, "task not completed"))
+
+	case errors.Is(err, services.ErrDeadLetterNotFound):
+		h.log.Debug("dead letter not found", slog.String(services.RequestIDKey, requestIDWithStr))
+		ctx.JSON(http.StatusNotFound, newErrorResponse(http.StatusNotFound, "dead letter not found"))
+
+	case errors.Is(err, services.ErrDeadLetterNotReplayable):
+		h.log.Debug("dead letter cannot be replayed", slog.String(services.RequestIDKey, requestIDWithStr))
+		ctx.JSON(http.StatusConflict, newErrorResponse(http.StatusConflict, "dead letter cannot be replayed"))
+
+	case errors.Is(err, services.ErrIdempotencyKeyMismatch):
+		h.log.Debug("idempotency key mismatch", slog.String(services.RequestIDKey, requestIDWithStr))
+		ctx.JSON(http.StatusUnprocessableEntity, newErrorResponse(http.StatusUnprocessableEntity,
+			"idempotency key is used with a different request"))
+
 	case errors.Is(err, services.ErrValidation):
 		h.log.Debug("validation error", slog.String(services.RequestIDKey, requestIDWithStr),
 			slog.String("error", err.Error()))
@@ -100,3 +300,106 @@ func (h Handler) handlingError(ctx *gin.Context, err error) {
 			newErrorResponse(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)))
 	}
 }
+
+func toTaskFilter(params ListTasksParams) models.TaskFilter {
+	var filter models.TaskFilter
+	if params.Status != nil {
+		filter.Status = models.TaskStatus(*params.Status)
+	}
+	if params.Host != nil {
+		filter.Host = *params.Host
+	}
+	if params.Method != nil {
+		filter.Method = *params.Method
+	}
+	if params.HttpStatusCode != nil {
+		filter.StatusCode = *params.HttpStatusCode
+	}
+	if params.CreatedFrom != nil {
+		filter.CreatedFrom = *params.CreatedFrom
+	}
+	if params.CreatedTo != nil {
+		filter.CreatedTo = *params.CreatedTo
+	}
+	if params.Order != nil {
+		filter.Order = models.SortOrder(*params.Order)
+	}
+	if params.Limit != nil {
+		filter.Limit = *params.Limit
+	}
+	if params.Cursor != nil {
+		filter.Cursor = *params.Cursor
+	}
+
+	return filter
+}
+
+func toDeadLetterFilter(params ListDeadLettersParams) models.DeadLetterFilter {
+	var filter models.DeadLetterFilter
+	if params.Reason != nil {
+		filter.Reason = models.DeadLetterReason(*params.Reason)
+	}
+	filter.Replayed = params.Replayed
+	if params.Limit != nil {
+		filter.Limit = *params.Limit
+	}
+	if params.Cursor != nil {
+		filter.Cursor = *params.Cursor
+	}
+
+	return filter
+}
+
+func parseWait(params AddTaskParams) (time.Duration, bool, error) {
+	if params.Wait != nil {
+		wait, err := parseWaitValue(*params.Wait)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid wait parameter %q: %w", *params.Wait, services.ErrValidation)
+		}
+
+		return wait, false, nil
+	}
+
+	if params.Prefer == nil {
+		return 0, false, nil
+	}
+
+	for _, preference := range strings.Split(*params.Prefer, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(preference), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "wait") {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || seconds < 0 {
+			return 0, false, fmt.Errorf("invalid wait preference %q: %w", preference, services.ErrValidation)
+		}
+
+		return time.Duration(seconds) * time.Second, true, nil
+	}
+
+	return 0, false, nil
+}
+
+func parseWaitValue(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		value = strconv.Itoa(seconds) + "s"
+	}
+
+	wait, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if wait < 0 {
+		return 0, errors.New("negative wait duration")
+	}
+
+	return wait, nil
+}
+
+// taskBodyETag учитывает время обновления задачи: после повторного запуска из dead letters у задачи
+// появляется новый результат.
+func taskBodyETag(id string, updatedAt time.Time) string {
+	return strconv.Quote(id + "." + strconv.FormatInt(updatedAt.UnixNano(), 36))
+}
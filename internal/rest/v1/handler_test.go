@@ -0,0 +1,105 @@
+package v1
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWait(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        AddTaskParams
+		wait          time.Duration
+		preferApplied bool
+		errExpected   error
+	}{
+		{
+			name: "without wait",
+		},
+		{
+			name:   "wait duration",
+			params: AddTaskParams{Wait: ptr("1m30s")},
+			wait:   90 * time.Second,
+		},
+		{
+			name:   "wait seconds",
+			params: AddTaskParams{Wait: ptr("30")},
+			wait:   30 * time.Second,
+		},
+		{
+			name:          "prefer header",
+			params:        AddTaskParams{Prefer: ptr("respond-async, wait=10")},
+			wait:          10 * time.Second,
+			preferApplied: true,
+		},
+		{
+			name:   "wait parameter has priority",
+			params: AddTaskParams{Wait: ptr("5s"), Prefer: ptr("wait=10")},
+			wait:   5 * time.Second,
+		},
+		{
+			name:   "prefer header without wait",
+			params: AddTaskParams{Prefer: ptr("respond-async")},
+		},
+		{
+			name:        "invalid wait",
+			params:      AddTaskParams{Wait: ptr("soon")},
+			errExpected: services.ErrValidation,
+		},
+		{
+			name:        "negative wait",
+			params:      AddTaskParams{Wait: ptr("-5s")},
+			errExpected: services.ErrValidation,
+		},
+		{
+			name:        "invalid prefer wait",
+			params:      AddTaskParams{Prefer: ptr("wait=30s")},
+			errExpected: services.ErrValidation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, preferApplied, err := parseWait(tt.params)
+			if tt.errExpected != nil {
+				require.ErrorIs(t, err, tt.errExpected)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wait, wait)
+			require.Equal(t, tt.preferApplied, preferApplied)
+		})
+	}
+}
+
+func TestAddTasksBatch_Route(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := gin.New()
+	Register(handler, slog.New(slog.DiscardHandler), nil)
+
+	// Путь, совпавший с параметром ":batch" только по префиксу, не доходит до сервиса.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/tasksbatch", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestTaskBodyETag(t *testing.T) {
+	id := "0f6b7f3c-1d2e-4a5b-9c8d-7e6f5a4b3c2d"
+	updatedAt := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	require.Equal(t, taskBodyETag(id, updatedAt), taskBodyETag(id, updatedAt))
+	// Повторно запущенная задача получает новый результат и новый ETag.
+	require.NotEqual(t, taskBodyETag(id, updatedAt), taskBodyETag(id, updatedAt.Add(time.Microsecond)))
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
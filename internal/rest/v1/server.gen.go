@@ -16,12 +16,39 @@ type ServerInterface interface {
 	// Service healthcheck
 	// (GET /ping)
 	PingService(c *gin.Context)
+	// Get a list of messages that could not be processed
+	// (GET /v1/dead-letters)
+	ListDeadLetters(c *gin.Context, params ListDeadLettersParams)
+	// Run the tasks of several dead letters again
+	// (POST /v1/dead-letters/replay)
+	ReplayDeadLetters(c *gin.Context)
+	// Get a dead letter with its raw payload and failure reason
+	// (GET /v1/dead-letters/{id})
+	GetDeadLetter(c *gin.Context, id string)
+	// Run the task of a dead letter again with a fresh attempt budget
+	// (POST /v1/dead-letters/{id}/replay)
+	ReplayDeadLetter(c *gin.Context, id string)
 	// Add a request task
 	// (POST /v1/task)
-	AddTask(c *gin.Context)
+	AddTask(c *gin.Context, params AddTaskParams)
 	// Get the result of completing a task
 	// (GET /v1/task/{id})
-	GetTaskResult(c *gin.Context, id string)
+	GetTaskResult(c *gin.Context, id string, params GetTaskResultParams)
+	// Get the history of task execution attempts
+	// (GET /v1/task/{id}/attempts)
+	GetTaskAttempts(c *gin.Context, id string)
+	// Download the raw response body of a completed task
+	// (GET /v1/task/{id}/body)
+	GetTaskBody(c *gin.Context, id string)
+	// Cancel a task that has not been completed yet
+	// (POST /v1/task/{id}/cancel)
+	CancelTask(c *gin.Context, id string)
+	// Get a list of tasks
+	// (GET /v1/tasks)
+	ListTasks(c *gin.Context, params ListTasksParams)
+	// Add several request tasks at once
+	// (POST /v1/tasks:batch)
+	AddTasksBatch(c *gin.Context)
 }
 
 // ServerInterfaceWrapper converts contexts to parameters.
@@ -46,9 +73,173 @@ func (siw *ServerInterfaceWrapper) PingService(c *gin.Context) {
 	siw.Handler.PingService(c)
 }
 
+// ListDeadLetters operation middleware
+func (siw *ServerInterfaceWrapper) ListDeadLetters(c *gin.Context) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListDeadLettersParams
+
+	// ------------- Optional query parameter "reason" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "reason", c.Request.URL.Query(), &params.Reason)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter reason: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "replayed" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "replayed", c.Request.URL.Query(), &params.Replayed)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter replayed: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", c.Request.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter limit: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", c.Request.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter cursor: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ListDeadLetters(c, params)
+}
+
+// ReplayDeadLetters operation middleware
+func (siw *ServerInterfaceWrapper) ReplayDeadLetters(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ReplayDeadLetters(c)
+}
+
+// GetDeadLetter operation middleware
+func (siw *ServerInterfaceWrapper) GetDeadLetter(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetDeadLetter(c, id)
+}
+
+// ReplayDeadLetter operation middleware
+func (siw *ServerInterfaceWrapper) ReplayDeadLetter(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ReplayDeadLetter(c, id)
+}
+
 // AddTask operation middleware
 func (siw *ServerInterfaceWrapper) AddTask(c *gin.Context) {
 
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params AddTaskParams
+
+	// ------------- Optional query parameter "wait" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "wait", c.Request.URL.Query(), &params.Wait)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter wait: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	headers := c.Request.Header
+
+	// ------------- Optional header parameter "Prefer" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Prefer")]; found {
+		var Prefer string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandler(c, fmt.Errorf("Expected one value for Prefer, got %d", n), http.StatusBadRequest)
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Prefer", valueList[0], &Prefer, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter Prefer: %w", err), http.StatusBadRequest)
+			return
+		}
+
+		params.Prefer = &Prefer
+
+	}
+
+	// ------------- Optional header parameter "Idempotency-Key" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Idempotency-Key")]; found {
+		var IdempotencyKey string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandler(c, fmt.Errorf("Expected one value for Idempotency-Key, got %d", n), http.StatusBadRequest)
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Idempotency-Key", valueList[0], &IdempotencyKey, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter Idempotency-Key: %w", err), http.StatusBadRequest)
+			return
+		}
+
+		params.IdempotencyKey = &IdempotencyKey
+
+	}
+
 	for _, middleware := range siw.HandlerMiddlewares {
 		middleware(c)
 		if c.IsAborted() {
@@ -56,7 +247,7 @@ func (siw *ServerInterfaceWrapper) AddTask(c *gin.Context) {
 		}
 	}
 
-	siw.Handler.AddTask(c)
+	siw.Handler.AddTask(c, params)
 }
 
 // GetTaskResult operation middleware
@@ -73,6 +264,192 @@ func (siw *ServerInterfaceWrapper) GetTaskResult(c *gin.Context) {
 		return
 	}
 
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetTaskResultParams
+
+	// ------------- Optional query parameter "omit_body" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "omit_body", c.Request.URL.Query(), &params.OmitBody)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter omit_body: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetTaskResult(c, id, params)
+}
+
+// GetTaskAttempts operation middleware
+func (siw *ServerInterfaceWrapper) GetTaskAttempts(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetTaskAttempts(c, id)
+}
+
+// GetTaskBody operation middleware
+func (siw *ServerInterfaceWrapper) GetTaskBody(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetTaskBody(c, id)
+}
+
+// CancelTask operation middleware
+func (siw *ServerInterfaceWrapper) CancelTask(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", c.Param("id"), &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.CancelTask(c, id)
+}
+
+// ListTasks operation middleware
+func (siw *ServerInterfaceWrapper) ListTasks(c *gin.Context) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListTasksParams
+
+	// ------------- Optional query parameter "status" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "status", c.Request.URL.Query(), &params.Status)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter status: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "host" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "host", c.Request.URL.Query(), &params.Host)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter host: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "method" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "method", c.Request.URL.Query(), &params.Method)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter method: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "http_status_code" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "http_status_code", c.Request.URL.Query(), &params.HttpStatusCode)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter http_status_code: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "created_from" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "created_from", c.Request.URL.Query(), &params.CreatedFrom)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter created_from: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "created_to" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "created_to", c.Request.URL.Query(), &params.CreatedTo)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter created_to: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "order" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "order", c.Request.URL.Query(), &params.Order)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter order: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", c.Request.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter limit: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", c.Request.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter cursor: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ListTasks(c, params)
+}
+
+// AddTasksBatch operation middleware
+func (siw *ServerInterfaceWrapper) AddTasksBatch(c *gin.Context) {
+
 	for _, middleware := range siw.HandlerMiddlewares {
 		middleware(c)
 		if c.IsAborted() {
@@ -80,7 +457,7 @@ func (siw *ServerInterfaceWrapper) GetTaskResult(c *gin.Context) {
 		}
 	}
 
-	siw.Handler.GetTaskResult(c, id)
+	siw.Handler.AddTasksBatch(c)
 }
 
 // GinServerOptions provides options for the Gin server.
@@ -111,6 +488,15 @@ func RegisterHandlersWithOptions(router gin.IRouter, si ServerInterface, options
 	}
 
 	router.GET(options.BaseURL+"/ping", wrapper.PingService)
+	router.GET(options.BaseURL+"/v1/dead-letters", wrapper.ListDeadLetters)
+	router.POST(options.BaseURL+"/v1/dead-letters/replay", wrapper.ReplayDeadLetters)
+	router.GET(options.BaseURL+"/v1/dead-letters/:id", wrapper.GetDeadLetter)
+	router.POST(options.BaseURL+"/v1/dead-letters/:id/replay", wrapper.ReplayDeadLetter)
 	router.POST(options.BaseURL+"/v1/task", wrapper.AddTask)
 	router.GET(options.BaseURL+"/v1/task/:id", wrapper.GetTaskResult)
+	router.GET(options.BaseURL+"/v1/task/:id/attempts", wrapper.GetTaskAttempts)
+	router.GET(options.BaseURL+"/v1/task/:id/body", wrapper.GetTaskBody)
+	router.POST(options.BaseURL+"/v1/task/:id/cancel", wrapper.CancelTask)
+	router.GET(options.BaseURL+"/v1/tasks", wrapper.ListTasks)
+	router.POST(options.BaseURL+"/v1/tasks:batch", wrapper.AddTasksBatch)
 }
@@ -18,18 +18,68 @@ import (
 // Base64 encoded, gzipped, json marshaled Swagger object
 var swaggerSpec = []string{
 
-	"H4sIAAAAAAAC/8xUwW7bOBD9FWJ2gb0IkbObveiWAkHQU4MmPQWBwZBji4lEsuTIiWHo34uhFFu25DZB",
-	"iqAnW+TM8M2b92YDytXeWbQUodhAVCXWMv29CMEF/uOD8xjIYDrWGFUwnoyz/Elrj1BApGDsEtoMkNPm",
-	"ymkcXBtLuMQAbZtBwO+NCaihuB0G32Uvwe7+ARVxrRsZH8cI7p1eTz5dotQYUozU2jBEWV3t5Y5yRm/W",
-	"SKXTHIu2qRnk5cUNZHD15fpmAHJXoQnVROWDRjloW/tYq18xNhW9oWHlLKGleYV2SeUU37+JlJLIzyNJ",
-	"auLR0WZg9GS1Lm/IqHYWIQNj5z44hTFCrxvIwOLTBM0HdBoN27pjNjna2IUbyTX1IXxwz2sRMayMYhhk",
-	"qOL0w/MVhtilnZ7MTmbcivNopTdQwH/pKAMvqUy95Z6BFhtYYhohEyz52c8aCrgydnm9rRwwemdjx/6/",
-	"sxn/9LNMc/K+Miol5w+x81lnzLE2duSOGZugZZ+OHpGIJAOhTkmxqWsZ1oPbEmVFpSpRPaaIfHWa04sz",
-	"XZzo9lzrZN1uaBjpU6/gVzf5d8AFFPBXvltPeb+b8lS6bTtN7BF5+g4iJ8X7GhIZjpBaoxaxUazmRVNV",
-	"a5bL2RtH+7Ouu3U89X6JgschnmQU1pFQASWhzgSVKJKthInC2fTdRAz/RBGNRkb4/0cg/Gbx2aMi1D2c",
-	"HgtbDUOPZU9651oLKXrxpO72lJdvjG6Peu0SabBN2aJB1khpCd5uwDAkti0vG1mnHaZhuF8oNJgNmj4U",
-	"xd07HfwrcffIj8w6pFvhFonD7eSH2hMBFZoVO5pFePYBImQYLL6Fa6z+g5V1iZQidizyexWSsUshX6TW",
-	"tj8CAAD//2xh/N0ZCQAA",
+	"H4sIAAAAAAAC/+xce28bN7b/KgTvBW4LjG3FcXK3BvYPJ3EbY5PGsJ1td+tAoIZHEpsZckpybGsDf/cF",
+	"D8l5aChLbmzHaftPa0kk5/A8fuc5+URzVVZKgrSG7n+iJp9DyfDPF4ovDmWuuJAz95nDlNWFpfvUwpWl",
+	"GeVgci0qK5Sk+zQuJWpK7BzIRPEFmQoo+DZ5ISTTC/eVAEOYBmI1k2YKWgMnzJAJM/B8LyPu5LjsUtg5",
+	"uWCF4OT92fdbf0vtE2b7XNKMgqxLuv9LpMwfRz9k1C4qoPvUWO1ucZ3RV8D4G7AWtLtTpVUF2grAG+ca",
+	"mAU+ZtZ9mipdur8oZxa2rCiBJs4DrZUel2AMm4HbNlghePLrii0KxbjnbJeRJ+ySTLT6CJqEYwk43vY5",
+	"pUphLXAiJCmEsYZmLcGThU3SqoEZ94hP9H81TOk+/Z+dVvo7QfQ7LYdO/HrcWRVsMc5VLW3nNkJamIFu",
+	"V9ySeZaZj+Mkf/DE32qhgTu5Ck4b8rOunJZIayWuJr9CbvsSfyOMHUqdA+PjAhfgZ2GhNJsziV43z2Ra",
+	"s4X7LOHKjvNaG6XX3633/JsvcNIIsK8yP80XaHNRXy6ZIe7YLX8s8H382bGbwNWc1QZVxxrCrIWysoYo",
+	"SRjRYPWCTQogUyaKWkOGiy6VdtroTi2UsW6tO65gxsYDMm/zXm3bR3AoxAVoASbrEZiruuBEKksmQCqm",
+	"jVdu5klUGhdXWl0tOkurelIIM2+u0jd9R7sAM24eTjPqCR87omlGS3Y1bgmiGRUS4aWx3oyGR4zd9YGv",
+	"AZAT1LyhNNwKEgRKrCJeQ7fJT8LOVW2J4IbUlfulEKWweLcFWBJNiPDuAQiD7sozcQGSeCNALIzrPR/6",
+	"Si14X5cH1yjZ1ZH/8cloNNRgpKyH+rsj3CTKugx7SiH9p1GWBITfBzbXayzA3TltyBpMXdjfY8P+1BPc",
+	"P7TnJXuNj/mwAaHhyAGp6DVW23FHAdDqnIZEaadwdIWLuQ28pm5zGKlcBswOzZ9WuUTnsVKuYunRncUp",
+	"Et7WhRX/ZEUNb1nljmOcC/dkVhz3FX6lpi+rdp/lb1nlIhbJSjDeJI1Dw6mLPGow2+R7pcmE5R8vmebE",
+	"aRGzYiIKYReEESPkrADiH+a3EGEIK4wiLM+hCi7a3RiMDdHK4JYnDnePVSHyBJzgj6TCX2NwhfBH3oUY",
+	"wNPcQEIOvEUNA/oCNAlmbDLCJHGAvcBdhAvj8N6QAJ8e25kluZKe0Sl0cUgaPceQ4DNlWUFkXU5AO4Lj",
+	"SiJkXtQYIzrKpkKjL4GMjEgJTJoEwbQDOrsbYI7VizGqVIouzaSplLbEr/AXtVrMZqCj98vIZBEf7pgg",
+	"IbdjF7+o2mYOfXn8RJjkcYVQ0j+WZq0mRs/EpRkHf0ozunQmzagtTLO5+4B2cXt8yiMtK7jngrHM1gYt",
+	"K8GL95WxGlhJ/DKCy9YzZG/3u4w8G+26/zxFBjwb7XXvPJTJAEsH2n/GzMchyrj8IWnP7ocxdBKTmzC+",
+	"l8RcZzRnReGseWwg12CHjDnF70ltgDs4MGLmg5240ec1aF6v3x683Dp9fbD77Dn55uetYxeubJ2KmWS2",
+	"1kDmwDjob1N43VBR6yIhm5M3XhIachAXYNrgzfseciEYOX53ekYu5yDbX4UhUyGFmae9BIcV4UrBFmQC",
+	"U6WB6FrKaKB4JkZlvNbMrSbfnNPvRuacZuScPnlWntNvXbDGOsZuwCGH8SvP6bfb5MiSnMkQ6+WqnAgZ",
+	"AUrXcsxsDxRbcj3/1nrxvofwkc1Yg6mUNDB2qVBC/d96TCFG/AcipsY9KOEu2ZoJA11sMoKDj9260MUc",
+	"mBWL9EL0ClVViBiuNQmSkPb5Hl2HbCXYueJdVPnh8Ixm1GmB+997/O/B2cvXNKOvDt8cnh3SjL4+PHhF",
+	"M/ru+Ozo3Y+nSfBQF6AdF8ZVx/00ib6uZc4wmVwOVBxQKMJVq4I9/hG4ygG4V13kQDdLb491uJik67ca",
+	"9OLWskfEWrer623dHlTChLsQJbg76rpjYsxmLktxPwn/deWyIF3LjpWKsgQumIViQbMNU+GABDfHaW5R",
+	"owsfVkDpgXe3Q0Rl7Q9DBesHbXfguhCmrVLjgmnMrSaFyj8CH3MwVkjEk04OFiKklM9zeClzKFZlZOtL",
+	"MBEVb1WcmFtbdb1owle0vjOCSB39arSGjLCJAWmJ8AuCEIjPMCPsdu2HpiObUlkYM84TacMB5xqMGdAQ",
+	"UMgfjuzFbALJsSp1Z2OZvl0FbElDo471juoLYI3epnO7brS5UXLXOXFtRtccvoq0F8zm8yFRztiTsZWD",
+	"jeOTdz//a/zCQfL47cHP49Ojfx8SvyPb/AqI/ULGVH3NRfzxN97iblLn5rg7yJmXz7ptsoyQG7PkUBq8",
+	"RZIsJIer4enHymD60824IuS3WLUmufVnr7p0WhQ31w6zVuk2FtRpXZZML9YK6WbtWSWde47Tu4Y/hMVm",
+	"mcfpruuSSmJFDySemdFQ+kPluKG+lytpQdpxAXJm55s4y77ivCxYC8XBd5Kp0t72mwC9zdDxMBdGBwcZ",
+	"UjKaPTYv3K2mbuSHl/OM5tMSe+4y+k957aEAV2CB0mImJCvGQyVYErL/fesN/n6D89dQKW2Bew0IcS+P",
+	"LS4x7TprFyTYVGowpP/mmNUlIPkceO0iDJ83uviU2Y2j0aE5cW9OQrqyfA7G0CBwmlEJl70grdEymtGG",
+	"kHTpInIkcZXYQ3TQ3nLOqn6ygRlcTDDC+ROlCmAy3UUKV1sFcxEsh83BDXDh9zQQP0th27Twi4mwrvit",
+	"77xRstOVVnPTLORAvRZgh4ShWK/Rx0/VUMMc50OTyUXKIkdahS3c9uXvMU3GbU+2R9sjRIsKJKsE3adP",
+	"8auMVszOkeU7VfB5M19jcoqEWHvEXWwh5Oy0OTmqMm7cHY06yub+xKpBjpt3fg0dFY9+Qx1tZT5kbYIt",
+	"y5UvpIiEwB03mWgNza9zYIWd53PIP+KKnYsnO51Wo1l5aRfttO0Rg9zSrAS/6ZdPVDgifNqfUclKR23T",
+	"8m1vfNtu0qqDm25Ke/QQPtKbI9y0Ozfpkj1JhYvpB4TwL0FbI9APn6k4m7HRCS2lK2dY1THWeb5Bt9LV",
+	"xsN0g8mIhEsw1lf7ndXs3SGhvkGVoO/IxzNkKgoLGFwFll5n9NlDEPBewlUFufNYIb6Ty4XBJQP7ASxh",
+	"DVNDFGViM6bbLvfgDTxpfzu66UpXyiTs0Dcm+5YYwr4XIZS/YyXyT/RcagHe6hquH0SJOz3jhKT8r7Gy",
+	"7sI0uAC96Kr1g6ttkMcj1taTTlHU+Lr/BWhW9NGAzZiQaTX9JPj1Sl/xA3RcxQpP4Xxti5hhQKirXF8e",
+	"PVch55dUrc6zieD+8Xv3//jOTAzi2FTVkj96NO7NYbh8XVhDNLuM7g07oDHH1+0AS1Lbb43MfzzFb0p1",
+	"GmytZZvO/VZDDX96W9gbfXf/Tz+bQ6O+whdN+2N3sZVsrCiK2BD+SlwRjpv05IouyBsvI1MNZt60YCY1",
+	"d94nGqyNYwhJ4zzgHOvxA5tcKkoz4YOIhiKrQs0Pv/Zzf+04X9tZh+3ZNjmnT1173YnC/Yn9cyewS+Zb",
+	"yJh5uPrRsM0csg7fXU4lFe6MG1OKbDB59P1L8v+7eyNSaZiCBpmDa/y7g/5+Xo9GT/PQ7ccPcE5xUjSM",
+	"8bDSkb0gzH/GGyBFpGFgJNTX+lpKj/Fxt6P1ZSFAWjID6aQGnHyERdvZx3BqmxwQDRUWDtqGWDMo5Qh2",
+	"mxymY+HJg1SnrxuKDpH7foApHiSksU7v1NSvc1MUzGU/REnoSGX5skccykpZkPli6x+w6N26ZFdvQrlp",
+	"99mzLI3mdx+6+8ZTiNfvyVl0WgrrnAXjHIKr7RbPhWwVy4oSsghg3V5nI+++nJeYjoc3wxuCR8WBK2FQ",
+	"kBETo986x6n43dGTzyjWrBrMXFuocawLTDF1noMx07ooFp6i3UdEEZnUlnDhM9cAginJPZjjP1vRLsw6",
+	"jRhhosupDej/M8HlZHRvd/dhKFxWTrSBQgPjCz+XFrwZF1NE5RaDsBf3eD31AeeENcRajzKt812bE3Yw",
+	"4x5C44FLeaVQRzBSGgw4ZS1eoEaVYBlnlnVRYoUjdq/ujFFUyRLilBUGEr2MD18UjUN9pNsNd3rZM/cw",
+	"r/hwkTTizteSTtoeF93zCghRQtoUdrq975ts4iCu+8oSxuWpnwSrD5r3k/pjGHAFee1byZp/gTKKp+Mv",
+	"TV+p6XNhrNI+BPevnUWBNVo9VPg4SzJLTWY3DeEOwhI337sFVyzvRPJNSz126c8WFWyTEyZnkJGj6Rb+",
+	"hTHf0XTrRyVh662bP2o8U5g9wDcqTF35Dr4P4ZP298Jj+Re2PZVbsFv+6n3Rty+G4nu4iSG+VZDf+jsf",
+	"XT5/2OejOIATjQJLzWk7sp56A1yhLfMY6s3dIfxPiBQPVlbCq0Z+TwBk9HJLeQGG2xp8+clLaQG+Ofjk",
+	"eVqUy7oQKleGWWGmwr3C9Igx8ZW6lG0UyS6XRuWxZtVyakUw4GczVheoXuLv6RrVlw8DNkg0s88bXzhb",
+	"GZa2Uy1/2f79237MVJfsH1+Wa18D6orkkVqtN6hYHMc+/ApsWyxVkW8egjnDFRuNvzTTT+3l73OYa9U4",
+	"yty/P39j6pza18xs3XrnYCguccb6KZowIzbVqqRZKh658b2Gmw+16s6O9BlMshaAStyZBGb4Cb/cXHx/",
+	"9HGlZpZ+zaCSn1VYU7V48OGkjBilrU9jv8JBJY93PfTbnzSvy4RIZelfCsL5mlhSR14w/548hwokB2ld",
+	"jCg6TrR5u90PU/sCstChnmLQu3Bfrqs0XOALTnMgys5BG+IAgEzA1VqC+aaSudDhM/5ln/vrrPjzH6C9",
+	"0r7vs8Iwuvy9BA2+hP+g1XhUFacG/t8kULr34ujgNdpHXtyOQ1DdErch+GJwDijy/w4AJq8n03BLAAA=",
 }
 
 // GetSwagger returns the content of the embedded swagger specification file
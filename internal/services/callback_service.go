@@ -0,0 +1,127 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/ASsssker/proxy/internal/netpolicy"
+)
+
+const (
+	CallbackSignatureHeader = "X-Proxy-Signature"
+	CallbackTimestampHeader = "X-Proxy-Timestamp"
+	CallbackTaskIDHeader    = "X-Proxy-Task-Id"
+)
+
+type CallbackService struct {
+	log        *slog.Logger
+	client     *http.Client
+	retryCount uint
+	backoff    time.Duration
+	secret     string
+}
+
+func NewCallbackService(cfg config.Config, log *slog.Logger, policy *netpolicy.Policy) *CallbackService {
+	return &CallbackService{
+		log: log,
+		client: &http.Client{
+			Timeout:   cfg.RequesterCallbackTimeout,
+			Transport: policy.Transport(),
+		},
+		retryCount: max(cfg.RequesterCallbackRetryCount, 1),
+		backoff:    cfg.RequesterCallbackBackoff,
+		secret:     cfg.RequesterCallbackSecret,
+	}
+}
+
+func (c CallbackService) SendCallback(ctx context.Context, task models.Task, taskResult models.TaskResult) (int, error) {
+	const op = "callback_service.SendCallback"
+	log := c.log.With(slog.String("op", op), slog.String("task_id", task.ID))
+	log.DebugContext(ctx, "start operation")
+
+	body, err := json.Marshal(taskResult)
+	if err != nil {
+		return 0, fmt.Errorf("%s task_id=%s failed to marshal task result: %v", op, task.ID, err)
+	}
+
+	secret := task.CallbackSecret
+	if secret == "" {
+		secret = c.secret
+	}
+
+	backoff := c.backoff
+	attempts := 0
+	for attempt := range c.retryCount {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return attempts, fmt.Errorf("%s task_id=%s callback delivery interrupted: %v", op, task.ID, ctx.Err())
+			}
+			backoff *= 2
+		}
+
+		attempts++
+		err = c.send(ctx, task, body, secret)
+		if err == nil {
+			log.DebugContext(ctx, "the operation was successfully completed")
+			return attempts, nil
+		}
+
+		log.WarnContext(ctx, "failed to deliver callback", slog.Int("attempt", attempts),
+			slog.String("error", err.Error()))
+	}
+
+	return attempts, fmt.Errorf("%s task_id=%s failed to deliver callback: %v", op, task.ID, err)
+}
+
+func (c CallbackService) send(ctx context.Context, task models.Task, body []byte, secret string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, task.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create callback request: %v", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(CallbackTaskIDHeader, task.ID)
+	request.Header.Set(CallbackTimestampHeader, timestamp)
+	if secret != "" {
+		request.Header.Set(CallbackSignatureHeader, SignCallback(secret, timestamp, body))
+	}
+
+	resp, err := c.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send callback request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected callback response status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// SignCallback подписывает тело колбэка вместе с меткой времени, чтобы получатель мог отбросить повторы.
+func SignCallback(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
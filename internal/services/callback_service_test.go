@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendCallback(t *testing.T) {
+	const secret = "secret"
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		signature := SignCallback(secret, r.Header.Get(CallbackTimestampHeader), body)
+		require.Equal(t, signature, r.Header.Get(CallbackSignatureHeader))
+
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	service := NewCallbackService(config.Config{
+		RequesterServiceConfig: config.RequesterServiceConfig{RequesterCallbackRetryCount: 5},
+	}, slog.New(slog.DiscardHandler), newLoopbackPolicy(t))
+
+	task := models.Task{ID: uuid.NewString(), CallbackURL: server.URL, CallbackSecret: secret}
+	attempts, err := service.SendCallback(context.Background(), task,
+		models.TaskResult{ID: task.ID, Status: models.StatusDone})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+
+	service.retryCount = 2
+	calls.Store(0)
+	attempts, err = service.SendCallback(context.Background(), task,
+		models.TaskResult{ID: task.ID, Status: models.StatusDone})
+	require.Error(t, err)
+	require.Equal(t, 2, attempts)
+}
@@ -2,4 +2,6 @@ package services
 
 const (
 	RequestIDKey = "request_id"
+
+	defaultTasksListLimit = 20
 )
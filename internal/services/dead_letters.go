@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/ASsssker/proxy/internal/models"
+	prom "github.com/ASsssker/proxy/internal/monitoring/prometheus"
+	"github.com/ASsssker/proxy/internal/storage"
+)
+
+const defaultDeadLettersLimit = 20
+
+func (r *RequesterService) sendDeadLetter(ctx context.Context, task models.Task, reason models.DeadLetterReason,
+	errMessage string) {
+	task.CallbackSecret = ""
+	payload, err := json.Marshal(task)
+	if err != nil {
+		r.log.Error("failed to marshal dead letter task", slog.String("task_id", task.ID),
+			slog.String("error", err.Error()))
+		return
+	}
+
+	deadLetter := models.NewDeadLetter(task.ID, reason, errMessage, payload)
+	if err := r.msgReceiver.SendDeadLetter(ctx, deadLetter); err != nil {
+		r.log.Error("failed to send task to dead letters", slog.String("task_id", task.ID),
+			slog.String("reason", string(reason)), slog.String("error", err.Error()))
+		return
+	}
+
+	prom.RequesterDeadLetters.WithLabelValues(string(reason)).Inc()
+}
+
+func (p *ProxyService) storeDeadLetter(ctx context.Context, deadLetter models.DeadLetter) error {
+	deadLetter.Payload = redactPayload(deadLetter.Payload)
+	if err := p.taskProvider.AddDeadLetter(ctx, deadLetter); err != nil {
+		return fmt.Errorf("failed to add dead letter: %w", err)
+	}
+
+	p.log.Warn("dead letter stored", slog.String("dead_letter_id", deadLetter.ID),
+		slog.String("task_id", deadLetter.TaskID), slog.String("reason", string(deadLetter.Reason)))
+	prom.ProxyDeadLetters.WithLabelValues(string(deadLetter.Reason)).Inc()
+
+	return nil
+}
+
+// redactPayload убирает callback_secret: запись хранится без шифрования и отдаётся через API.
+func redactPayload(payload []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return payload
+	}
+
+	if _, ok := fields["callback_secret"]; !ok {
+		return payload
+	}
+	delete(fields, "callback_secret")
+
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+
+	return redacted
+}
+
+func (p *ProxyService) ListDeadLetters(ctx context.Context, filter models.DeadLetterFilter) (models.DeadLetterList,
+	error) {
+	const op = "proxy_service.ListDeadLetters"
+	requestID := ctx.Value(RequestIDKey).(string)
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultDeadLettersLimit
+	}
+
+	if err := p.validator.Struct(filter); err != nil {
+		return models.DeadLetterList{}, fmt.Errorf("%s request_id=%s failed to validate filter: %w: %w",
+			op, requestID, ErrValidation, err)
+	}
+
+	log := p.log.With(slog.String("op", op), slog.String(RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	deadLetterList, err := p.taskProvider.ListDeadLetters(ctx, filter)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			return models.DeadLetterList{}, fmt.Errorf("%s request_id=%s invalid cursor: %w: %w",
+				op, requestID, ErrValidation, err)
+		}
+
+		return models.DeadLetterList{}, fmt.Errorf("%s request_id=%s failed to list dead letters: %w",
+			op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return deadLetterList, nil
+}
+
+func (p *ProxyService) GetDeadLetter(ctx context.Context, id string) (models.DeadLetter, error) {
+	const op = "proxy_service.GetDeadLetter"
+	requestID := ctx.Value(RequestIDKey).(string)
+
+	if err := p.validator.Var(id, "uuid"); err != nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to validate dead letter id: %w: %w",
+			op, requestID, ErrValidation, err)
+	}
+
+	log := p.log.With(slog.String("op", op), slog.String(RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	deadLetter, err := p.taskProvider.GetDeadLetter(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrDeadLetterNotFound) {
+			return models.DeadLetter{}, fmt.Errorf("%s request_id=%s dead letter not found: %w",
+				op, requestID, ErrDeadLetterNotFound)
+		}
+
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to get dead letter: %w", op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return deadLetter, nil
+}
+
+func (p *ProxyService) ReplayDeadLetter(ctx context.Context, id string) (models.DeadLetter, error) {
+	const op = "proxy_service.ReplayDeadLetter"
+	requestID := ctx.Value(RequestIDKey).(string)
+
+	if err := p.validator.Var(id, "uuid"); err != nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to validate dead letter id: %w: %w",
+			op, requestID, ErrValidation, err)
+	}
+
+	log := p.log.With(slog.String("op", op), slog.String(RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	deadLetter, err := p.replayDeadLetter(ctx, id)
+	if err != nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s %w", op, requestID, err)
+	}
+	p.outbox.notify()
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return deadLetter, nil
+}
+
+func (p *ProxyService) ReplayDeadLetters(ctx context.Context, replay models.DeadLetterReplay) (
+	models.DeadLetterReplayList, error) {
+	const op = "proxy_service.ReplayDeadLetters"
+	requestID := ctx.Value(RequestIDKey).(string)
+
+	if replay.Limit == 0 {
+		replay.Limit = defaultDeadLettersLimit
+	}
+
+	if err := p.validator.Struct(replay); err != nil {
+		return models.DeadLetterReplayList{}, fmt.Errorf("%s request_id=%s failed to validate replay: %w: %w",
+			op, requestID, ErrValidation, err)
+	}
+
+	log := p.log.With(slog.String("op", op), slog.String(RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	ids := replay.IDs
+	if len(ids) == 0 {
+		replayed := false
+		deadLetterList, err := p.taskProvider.ListDeadLetters(ctx, models.DeadLetterFilter{
+			Reason:   replay.Reason,
+			Replayed: &replayed,
+			Limit:    replay.Limit,
+		})
+		if err != nil {
+			return models.DeadLetterReplayList{}, fmt.Errorf("%s request_id=%s failed to list dead letters: %w",
+				op, requestID, err)
+		}
+
+		for _, deadLetter := range deadLetterList.DeadLetters {
+			ids = append(ids, deadLetter.ID)
+		}
+	}
+
+	replayList := models.DeadLetterReplayList{Results: make([]models.DeadLetterReplayResult, 0, len(ids))}
+	for _, id := range ids {
+		result := models.DeadLetterReplayResult{ID: id}
+
+		deadLetter, err := p.replayDeadLetter(ctx, id)
+		switch {
+		case err == nil:
+			result.TaskID = deadLetter.TaskID
+		case errors.Is(err, ErrDeadLetterNotFound), errors.Is(err, ErrDeadLetterNotReplayable):
+			result.Error = err.Error()
+		default:
+			log.ErrorContext(ctx, "failed to replay dead letter", slog.String("dead_letter_id", id),
+				slog.String("error", err.Error()))
+			result.Error = "failed to replay dead letter"
+		}
+
+		replayList.Results = append(replayList.Results, result)
+	}
+	p.outbox.notify()
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return replayList, nil
+}
+
+func (p *ProxyService) replayDeadLetter(ctx context.Context, id string) (models.DeadLetter, error) {
+	deadLetter, err := p.taskProvider.ReplayDeadLetter(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrDeadLetterNotFound):
+			return models.DeadLetter{}, ErrDeadLetterNotFound
+		case errors.Is(err, storage.ErrNotReplayable):
+			return models.DeadLetter{}, ErrDeadLetterNotReplayable
+		default:
+			return models.DeadLetter{}, fmt.Errorf("failed to replay dead letter: %w", err)
+		}
+	}
+
+	p.log.Info("task replayed from dead letter", slog.String("dead_letter_id", id),
+		slog.String("task_id", deadLetter.TaskID))
+	prom.ProxyDeadLettersReplayed.Inc()
+
+	return deadLetter, nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/ASsssker/proxy/internal/storage"
+)
+
+type RequesterState string
+
+var (
+	RequesterStarting = RequesterState("starting")
+	RequesterRunning  = RequesterState("running")
+	RequesterDraining = RequesterState("draining")
+	RequesterStopped  = RequesterState("stopped")
+)
+
+func (r *RequesterService) State() RequesterState {
+	return r.state.Load().(RequesterState)
+}
+
+func (r *RequesterService) draining() bool {
+	return r.State() != RequesterRunning
+}
+
+// drain ждёт задачи пула не дольше drainTimeout, после чего отменяет их. Задачи, ещё не взятые воркером,
+// возвращаются в брокер сразу.
+func (r *RequesterService) drain(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.pool.StopAndWait()
+	}()
+
+	timer := time.NewTimer(r.drainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		r.log.Info("requester drained")
+		return
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	r.mu.Lock()
+	running := len(r.runningTasks)
+	r.mu.Unlock()
+
+	r.log.Warn("drain timeout exceeded, running tasks cancelled", slog.Int("running_tasks", running))
+	r.stopTasks()
+	<-done
+}
+
+func (r *RequesterService) returnTask(ctx context.Context, msg models.TaskMessage, lease models.TaskLease) {
+	lease.TTL = r.leaseTTL
+	if err := r.taskUpdater.ReleaseTaskLease(ctx, lease); err != nil {
+		if errors.Is(err, storage.ErrTaskLeased) {
+			r.log.Info("task return skipped because task lease is lost", slog.String("task_id", lease.TaskID))
+			r.ack(msg)
+			return
+		}
+
+		// Сообщение всё равно возвращается: после истечения аренды задачу подберёт reaper.
+		r.log.Error("failed to release task lease", slog.String("task_id", lease.TaskID),
+			slog.String("error", err.Error()))
+	}
+
+	r.log.Info("task returned to the broker on shutdown", slog.String("task_id", lease.TaskID))
+	r.nack(msg)
+}
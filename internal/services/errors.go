@@ -3,6 +3,16 @@ package services
 import "errors"
 
 var (
-	ErrTaskNotFound = errors.New("task not found")
-	ErrValidation   = errors.New("validation error")
+	ErrTaskNotFound       = errors.New("task not found")
+	ErrValidation         = errors.New("validation error")
+	ErrTaskNotCancellable = errors.New("task cannot be cancelled")
+	ErrTaskNotCompleted   = errors.New("task not completed")
+	ErrBodyTooLarge       = errors.New("response body too large")
+	ErrInvalidRequest     = errors.New("invalid request")
+
+	ErrDeadLetterNotFound      = errors.New("dead letter not found")
+	ErrDeadLetterNotReplayable = errors.New("dead letter cannot be replayed")
+
+	// ErrIdempotencyKeyMismatch означает, что ключ идемпотентности уже использован запросом с другой задачей.
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key is used with a different request")
 )
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+)
+
+const (
+	defaultIdempotencySweepInterval = 10 * time.Minute
+	idempotencySweepBatchSize       = 1000
+)
+
+type idempotencySweeper struct {
+	log          *slog.Logger
+	taskProvider TaskProvider
+	interval     time.Duration
+}
+
+func newIdempotencySweeper(log *slog.Logger, cfg config.Config, taskProvider TaskProvider) *idempotencySweeper {
+	s := &idempotencySweeper{
+		log:          log,
+		taskProvider: taskProvider,
+		interval:     cfg.ProxyIdempotencySweepInterval,
+	}
+	if s.interval <= 0 {
+		s.interval = defaultIdempotencySweepInterval
+	}
+
+	return s
+}
+
+func (s *idempotencySweeper) run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *idempotencySweeper) sweep(ctx context.Context) {
+	for ctx.Err() == nil {
+		deleted, err := s.taskProvider.DeleteExpiredIdempotencyKeys(ctx, idempotencySweepBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.log.Error("failed to delete expired idempotency keys", slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		if deleted < idempotencySweepBatchSize {
+			return
+		}
+	}
+}
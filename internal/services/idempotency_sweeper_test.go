@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/ASsssker/proxy/internal/config"
+	mock_services "github.com/ASsssker/proxy/internal/services/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+func TestIdempotencySweeper_Sweep(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockProvider := mock_services.NewMockTaskProvider(ctrl)
+	sweeper := newIdempotencySweeper(slog.New(slog.DiscardHandler), config.Config{}, mockProvider)
+
+	// Полная пачка запрашивает следующую, неполная или ошибка завершают очистку до следующего тика.
+	gomock.InOrder(
+		mockProvider.EXPECT().DeleteExpiredIdempotencyKeys(gomock.Any(), gomock.Eq(idempotencySweepBatchSize)).
+			Return(idempotencySweepBatchSize, nil),
+		mockProvider.EXPECT().DeleteExpiredIdempotencyKeys(gomock.Any(), gomock.Eq(idempotencySweepBatchSize)).
+			Return(1, nil),
+		mockProvider.EXPECT().DeleteExpiredIdempotencyKeys(gomock.Any(), gomock.Eq(idempotencySweepBatchSize)).
+			Return(0, errors.New("connection refused")),
+	)
+
+	sweeper.sweep(context.Background())
+	sweeper.sweep(context.Background())
+}
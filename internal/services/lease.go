@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/models"
+	prom "github.com/ASsssker/proxy/internal/monitoring/prometheus"
+	"github.com/ASsssker/proxy/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultLeaseTTL       = 30 * time.Second
+	defaultReaperInterval = 15 * time.Second
+	reaperBatchSize       = 100
+
+	reaperActionRequeued = "requeued"
+	reaperActionFailed   = "failed"
+)
+
+// keepLease отменяет выполнение, если аренда утрачена: задачу уже отменили или передали другому requester.
+func (r *RequesterService) keepLease(ctx context.Context, lease models.TaskLease) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(lease.TTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				err := r.taskUpdater.ExtendTaskLease(context.Background(), lease)
+				if err == nil {
+					continue
+				}
+
+				if errors.Is(err, storage.ErrTaskLeased) {
+					r.log.Warn("task lease lost, execution cancelled", slog.String("task_id", lease.TaskID))
+					prom.RequesterLeasesLost.Inc()
+					cancel()
+					return
+				}
+
+				r.log.Error("failed to extend task lease", slog.String("task_id", lease.TaskID),
+					slog.String("error", err.Error()))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ctx, func() {
+		cancel()
+		<-done
+	}
+}
+
+// runReaper работает в каждом экземпляре requester, одну задачу обработает только один из них.
+func (r *RequesterService) runReaper(ctx context.Context) {
+	ticker := time.NewTicker(r.reaperInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.reapExpiredTasks(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *RequesterService) reapExpiredTasks(ctx context.Context) {
+	expired, err := r.taskUpdater.ListExpiredTasks(ctx, reaperBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.log.Error("failed to list expired tasks", slog.String("error", err.Error()))
+		}
+		return
+	}
+
+	for _, expiredTask := range expired {
+		if ctx.Err() != nil {
+			return
+		}
+
+		r.reapTask(ctx, expiredTask)
+	}
+}
+
+func (r *RequesterService) reapTask(ctx context.Context, expired models.ExpiredTask) {
+	task := expired.Task
+	task.Attempt = expired.Attempt
+
+	if task.URL != "" && r.retry.hasAttempts(task) {
+		task.Attempt = task.AttemptNumber() + 1
+
+		if err := r.taskUpdater.RequeueExpiredTask(ctx, task); err != nil {
+			if !errors.Is(err, storage.ErrTaskLeased) {
+				r.log.Error("failed to requeue expired task", slog.String("task_id", task.ID),
+					slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		r.log.Warn("task lease expired, task requeued", slog.String("task_id", task.ID),
+			slog.String("lost_worker_id", expired.WorkerID), slog.Int("attempt", task.Attempt))
+		prom.RequesterReaperTasks.WithLabelValues(reaperActionRequeued).Inc()
+
+		return
+	}
+
+	errMessage := fmt.Sprintf("worker %s stopped renewing the task lease", expired.WorkerID)
+	if expired.WorkerID == "" {
+		errMessage = "next attempt was not started before the task lease expired"
+	}
+
+	failedResult := models.TaskResult{
+		ID:           task.ID,
+		Status:       models.StatusError,
+		ErrorCode:    models.ErrorWorkerLost,
+		ErrorMessage: errMessage,
+	}
+	if err := r.taskUpdater.FailExpiredTask(ctx, failedResult); err != nil {
+		if !errors.Is(err, storage.ErrTaskLeased) {
+			r.log.Error("failed to fail expired task", slog.String("task_id", task.ID),
+				slog.String("error", err.Error()))
+		}
+		return
+	}
+
+	r.log.Warn("task lease expired, task failed", slog.String("task_id", task.ID),
+		slog.String("lost_worker_id", expired.WorkerID), slog.Int("attempt", task.AttemptNumber()))
+	prom.RequesterReaperTasks.WithLabelValues(reaperActionFailed).Inc()
+
+	if task.URL != "" {
+		r.sendDeadLetter(ctx, task, models.DeadLetterWorkerLost, errMessage)
+	}
+	r.completeTask(ctx, task, failedResult)
+}
+
+func defaultWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "requester"
+	}
+
+	return hostname + "-" + uuid.NewString()[:8]
+}
@@ -41,18 +41,76 @@ func (m *MockTaskProvider) EXPECT() *MockTaskProviderMockRecorder {
 	return m.recorder
 }
 
+// AddDeadLetter mocks base method.
+func (m *MockTaskProvider) AddDeadLetter(ctx context.Context, deadLetter models.DeadLetter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDeadLetter", ctx, deadLetter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDeadLetter indicates an expected call of AddDeadLetter.
+func (mr *MockTaskProviderMockRecorder) AddDeadLetter(ctx, deadLetter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDeadLetter", reflect.TypeOf((*MockTaskProvider)(nil).AddDeadLetter), ctx, deadLetter)
+}
+
+// AddIdempotentTask mocks base method.
+func (m *MockTaskProvider) AddIdempotentTask(ctx context.Context, task models.Task, key models.IdempotencyKey) (models.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddIdempotentTask", ctx, task, key)
+	ret0, _ := ret[0].(models.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddIdempotentTask indicates an expected call of AddIdempotentTask.
+func (mr *MockTaskProviderMockRecorder) AddIdempotentTask(ctx, task, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddIdempotentTask", reflect.TypeOf((*MockTaskProvider)(nil).AddIdempotentTask), ctx, task, key)
+}
+
 // AddTask mocks base method.
-func (m *MockTaskProvider) AddTask(ctx context.Context, taskID string) error {
+func (m *MockTaskProvider) AddTask(ctx context.Context, task models.Task) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddTask", ctx, taskID)
+	ret := m.ctrl.Call(m, "AddTask", ctx, task)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddTask indicates an expected call of AddTask.
-func (mr *MockTaskProviderMockRecorder) AddTask(ctx, taskID any) *gomock.Call {
+func (mr *MockTaskProviderMockRecorder) AddTask(ctx, task any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTask", reflect.TypeOf((*MockTaskProvider)(nil).AddTask), ctx, task)
+}
+
+// AddTasks mocks base method.
+func (m *MockTaskProvider) AddTasks(ctx context.Context, tasks []models.Task) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTasks", ctx, tasks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddTasks indicates an expected call of AddTasks.
+func (mr *MockTaskProviderMockRecorder) AddTasks(ctx, tasks any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTask", reflect.TypeOf((*MockTaskProvider)(nil).AddTask), ctx, taskID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTasks", reflect.TypeOf((*MockTaskProvider)(nil).AddTasks), ctx, tasks)
+}
+
+// CancelTask mocks base method.
+func (m *MockTaskProvider) CancelTask(ctx context.Context, taskID string) (models.TaskStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelTask", ctx, taskID)
+	ret0, _ := ret[0].(models.TaskStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelTask indicates an expected call of CancelTask.
+func (mr *MockTaskProviderMockRecorder) CancelTask(ctx, taskID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelTask", reflect.TypeOf((*MockTaskProvider)(nil).CancelTask), ctx, taskID)
 }
 
 // Close mocks base method.
@@ -69,19 +127,154 @@ func (mr *MockTaskProviderMockRecorder) Close(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockTaskProvider)(nil).Close), ctx)
 }
 
+// DeleteExpiredIdempotencyKeys mocks base method.
+func (m *MockTaskProvider) DeleteExpiredIdempotencyKeys(ctx context.Context, limit int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyKeys", ctx, limit)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredIdempotencyKeys indicates an expected call of DeleteExpiredIdempotencyKeys.
+func (mr *MockTaskProviderMockRecorder) DeleteExpiredIdempotencyKeys(ctx, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyKeys", reflect.TypeOf((*MockTaskProvider)(nil).DeleteExpiredIdempotencyKeys), ctx, limit)
+}
+
+// GetDeadLetter mocks base method.
+func (m *MockTaskProvider) GetDeadLetter(ctx context.Context, id string) (models.DeadLetter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeadLetter", ctx, id)
+	ret0, _ := ret[0].(models.DeadLetter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeadLetter indicates an expected call of GetDeadLetter.
+func (mr *MockTaskProviderMockRecorder) GetDeadLetter(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeadLetter", reflect.TypeOf((*MockTaskProvider)(nil).GetDeadLetter), ctx, id)
+}
+
 // GetTask mocks base method.
-func (m *MockTaskProvider) GetTask(ctx context.Context, taskID string) (models.TaskResult, error) {
+func (m *MockTaskProvider) GetTask(ctx context.Context, taskID string, omitBody bool) (models.TaskResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTask", ctx, taskID)
+	ret := m.ctrl.Call(m, "GetTask", ctx, taskID, omitBody)
 	ret0, _ := ret[0].(models.TaskResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetTask indicates an expected call of GetTask.
-func (mr *MockTaskProviderMockRecorder) GetTask(ctx, taskID any) *gomock.Call {
+func (mr *MockTaskProviderMockRecorder) GetTask(ctx, taskID, omitBody any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTask", reflect.TypeOf((*MockTaskProvider)(nil).GetTask), ctx, taskID, omitBody)
+}
+
+// GetTaskAttempts mocks base method.
+func (m *MockTaskProvider) GetTaskAttempts(ctx context.Context, taskID string) ([]models.TaskAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTaskAttempts", ctx, taskID)
+	ret0, _ := ret[0].([]models.TaskAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTaskAttempts indicates an expected call of GetTaskAttempts.
+func (mr *MockTaskProviderMockRecorder) GetTaskAttempts(ctx, taskID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTask", reflect.TypeOf((*MockTaskProvider)(nil).GetTask), ctx, taskID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTaskAttempts", reflect.TypeOf((*MockTaskProvider)(nil).GetTaskAttempts), ctx, taskID)
+}
+
+// GetTaskBody mocks base method.
+func (m *MockTaskProvider) GetTaskBody(ctx context.Context, taskID string) (models.TaskBody, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTaskBody", ctx, taskID)
+	ret0, _ := ret[0].(models.TaskBody)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTaskBody indicates an expected call of GetTaskBody.
+func (mr *MockTaskProviderMockRecorder) GetTaskBody(ctx, taskID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTaskBody", reflect.TypeOf((*MockTaskProvider)(nil).GetTaskBody), ctx, taskID)
+}
+
+// ListDeadLetters mocks base method.
+func (m *MockTaskProvider) ListDeadLetters(ctx context.Context, filter models.DeadLetterFilter) (models.DeadLetterList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeadLetters", ctx, filter)
+	ret0, _ := ret[0].(models.DeadLetterList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeadLetters indicates an expected call of ListDeadLetters.
+func (mr *MockTaskProviderMockRecorder) ListDeadLetters(ctx, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeadLetters", reflect.TypeOf((*MockTaskProvider)(nil).ListDeadLetters), ctx, filter)
+}
+
+// ListTasks mocks base method.
+func (m *MockTaskProvider) ListTasks(ctx context.Context, filter models.TaskFilter) (models.TaskList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTasks", ctx, filter)
+	ret0, _ := ret[0].(models.TaskList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTasks indicates an expected call of ListTasks.
+func (mr *MockTaskProviderMockRecorder) ListTasks(ctx, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTasks", reflect.TypeOf((*MockTaskProvider)(nil).ListTasks), ctx, filter)
+}
+
+// PromoteScheduledTasks mocks base method.
+func (m *MockTaskProvider) PromoteScheduledTasks(ctx context.Context, limit int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PromoteScheduledTasks", ctx, limit)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PromoteScheduledTasks indicates an expected call of PromoteScheduledTasks.
+func (mr *MockTaskProviderMockRecorder) PromoteScheduledTasks(ctx, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PromoteScheduledTasks", reflect.TypeOf((*MockTaskProvider)(nil).PromoteScheduledTasks), ctx, limit)
+}
+
+// PublishOutbox mocks base method.
+func (m *MockTaskProvider) PublishOutbox(ctx context.Context, limit, maxAttempts int, publish func(context.Context, []models.OutboxMessage) []error) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishOutbox", ctx, limit, maxAttempts, publish)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PublishOutbox indicates an expected call of PublishOutbox.
+func (mr *MockTaskProviderMockRecorder) PublishOutbox(ctx, limit, maxAttempts, publish any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishOutbox", reflect.TypeOf((*MockTaskProvider)(nil).PublishOutbox), ctx, limit, maxAttempts, publish)
+}
+
+// ReplayDeadLetter mocks base method.
+func (m *MockTaskProvider) ReplayDeadLetter(ctx context.Context, id string) (models.DeadLetter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayDeadLetter", ctx, id)
+	ret0, _ := ret[0].(models.DeadLetter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplayDeadLetter indicates an expected call of ReplayDeadLetter.
+func (mr *MockTaskProviderMockRecorder) ReplayDeadLetter(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayDeadLetter", reflect.TypeOf((*MockTaskProvider)(nil).ReplayDeadLetter), ctx, id)
 }
 
 // MockMessageSender is a mock of MessageSender interface.
@@ -122,6 +315,20 @@ func (mr *MockMessageSenderMockRecorder) Close(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockMessageSender)(nil).Close), ctx)
 }
 
+// SendCancel mocks base method.
+func (m *MockMessageSender) SendCancel(ctx context.Context, taskID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendCancel", ctx, taskID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendCancel indicates an expected call of SendCancel.
+func (mr *MockMessageSenderMockRecorder) SendCancel(ctx, taskID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCancel", reflect.TypeOf((*MockMessageSender)(nil).SendCancel), ctx, taskID)
+}
+
 // SendTask mocks base method.
 func (m *MockMessageSender) SendTask(ctx context.Context, task models.Task) error {
 	m.ctrl.T.Helper()
@@ -135,3 +342,47 @@ func (mr *MockMessageSenderMockRecorder) SendTask(ctx, task any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendTask", reflect.TypeOf((*MockMessageSender)(nil).SendTask), ctx, task)
 }
+
+// SendTasks mocks base method.
+func (m *MockMessageSender) SendTasks(ctx context.Context, tasks []models.Task) []error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendTasks", ctx, tasks)
+	ret0, _ := ret[0].([]error)
+	return ret0
+}
+
+// SendTasks indicates an expected call of SendTasks.
+func (mr *MockMessageSenderMockRecorder) SendTasks(ctx, tasks any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendTasks", reflect.TypeOf((*MockMessageSender)(nil).SendTasks), ctx, tasks)
+}
+
+// SubscribeCompletions mocks base method.
+func (m *MockMessageSender) SubscribeCompletions(ctx context.Context, completionChan chan models.TaskCompletion) (context.CancelFunc, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeCompletions", ctx, completionChan)
+	ret0, _ := ret[0].(context.CancelFunc)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeCompletions indicates an expected call of SubscribeCompletions.
+func (mr *MockMessageSenderMockRecorder) SubscribeCompletions(ctx, completionChan any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeCompletions", reflect.TypeOf((*MockMessageSender)(nil).SubscribeCompletions), ctx, completionChan)
+}
+
+// SubscribeDeadLetters mocks base method.
+func (m *MockMessageSender) SubscribeDeadLetters(ctx context.Context, handle func(context.Context, models.DeadLetter) error) (context.CancelFunc, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeDeadLetters", ctx, handle)
+	ret0, _ := ret[0].(context.CancelFunc)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeDeadLetters indicates an expected call of SubscribeDeadLetters.
+func (mr *MockMessageSenderMockRecorder) SubscribeDeadLetters(ctx, handle any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeDeadLetters", reflect.TypeOf((*MockMessageSender)(nil).SubscribeDeadLetters), ctx, handle)
+}
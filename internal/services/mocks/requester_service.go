@@ -0,0 +1,387 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/services/requester_service.go
+//
+// Generated by this command:
+//
+//	mockgen --source internal/services/requester_service.go
+//
+
+// Package mock_services is a generated GoMock package.
+package mock_services
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	models "github.com/ASsssker/proxy/internal/models"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockTaskUpdater is a mock of TaskUpdater interface.
+type MockTaskUpdater struct {
+	ctrl     *gomock.Controller
+	recorder *MockTaskUpdaterMockRecorder
+	isgomock struct{}
+}
+
+// MockTaskUpdaterMockRecorder is the mock recorder for MockTaskUpdater.
+type MockTaskUpdaterMockRecorder struct {
+	mock *MockTaskUpdater
+}
+
+// NewMockTaskUpdater creates a new mock instance.
+func NewMockTaskUpdater(ctrl *gomock.Controller) *MockTaskUpdater {
+	mock := &MockTaskUpdater{ctrl: ctrl}
+	mock.recorder = &MockTaskUpdaterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTaskUpdater) EXPECT() *MockTaskUpdaterMockRecorder {
+	return m.recorder
+}
+
+// AcquireTaskLease mocks base method.
+func (m *MockTaskUpdater) AcquireTaskLease(ctx context.Context, lease models.TaskLease) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcquireTaskLease", ctx, lease)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AcquireTaskLease indicates an expected call of AcquireTaskLease.
+func (mr *MockTaskUpdaterMockRecorder) AcquireTaskLease(ctx, lease any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcquireTaskLease", reflect.TypeOf((*MockTaskUpdater)(nil).AcquireTaskLease), ctx, lease)
+}
+
+// AddTaskAttempt mocks base method.
+func (m *MockTaskUpdater) AddTaskAttempt(ctx context.Context, attempt models.TaskAttempt) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTaskAttempt", ctx, attempt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddTaskAttempt indicates an expected call of AddTaskAttempt.
+func (mr *MockTaskUpdaterMockRecorder) AddTaskAttempt(ctx, attempt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTaskAttempt", reflect.TypeOf((*MockTaskUpdater)(nil).AddTaskAttempt), ctx, attempt)
+}
+
+// Close mocks base method.
+func (m *MockTaskUpdater) Close(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockTaskUpdaterMockRecorder) Close(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockTaskUpdater)(nil).Close), ctx)
+}
+
+// ExtendTaskLease mocks base method.
+func (m *MockTaskUpdater) ExtendTaskLease(ctx context.Context, lease models.TaskLease) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExtendTaskLease", ctx, lease)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExtendTaskLease indicates an expected call of ExtendTaskLease.
+func (mr *MockTaskUpdaterMockRecorder) ExtendTaskLease(ctx, lease any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtendTaskLease", reflect.TypeOf((*MockTaskUpdater)(nil).ExtendTaskLease), ctx, lease)
+}
+
+// FailExpiredTask mocks base method.
+func (m *MockTaskUpdater) FailExpiredTask(ctx context.Context, taskResult models.TaskResult) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailExpiredTask", ctx, taskResult)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailExpiredTask indicates an expected call of FailExpiredTask.
+func (mr *MockTaskUpdaterMockRecorder) FailExpiredTask(ctx, taskResult any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailExpiredTask", reflect.TypeOf((*MockTaskUpdater)(nil).FailExpiredTask), ctx, taskResult)
+}
+
+// ListExpiredTasks mocks base method.
+func (m *MockTaskUpdater) ListExpiredTasks(ctx context.Context, limit int) ([]models.ExpiredTask, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListExpiredTasks", ctx, limit)
+	ret0, _ := ret[0].([]models.ExpiredTask)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListExpiredTasks indicates an expected call of ListExpiredTasks.
+func (mr *MockTaskUpdaterMockRecorder) ListExpiredTasks(ctx, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListExpiredTasks", reflect.TypeOf((*MockTaskUpdater)(nil).ListExpiredTasks), ctx, limit)
+}
+
+// ReleaseTaskLease mocks base method.
+func (m *MockTaskUpdater) ReleaseTaskLease(ctx context.Context, lease models.TaskLease) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseTaskLease", ctx, lease)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseTaskLease indicates an expected call of ReleaseTaskLease.
+func (mr *MockTaskUpdaterMockRecorder) ReleaseTaskLease(ctx, lease any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseTaskLease", reflect.TypeOf((*MockTaskUpdater)(nil).ReleaseTaskLease), ctx, lease)
+}
+
+// RequeueExpiredTask mocks base method.
+func (m *MockTaskUpdater) RequeueExpiredTask(ctx context.Context, task models.Task) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequeueExpiredTask", ctx, task)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequeueExpiredTask indicates an expected call of RequeueExpiredTask.
+func (mr *MockTaskUpdaterMockRecorder) RequeueExpiredTask(ctx, task any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequeueExpiredTask", reflect.TypeOf((*MockTaskUpdater)(nil).RequeueExpiredTask), ctx, task)
+}
+
+// UpdateCallbackStatus mocks base method.
+func (m *MockTaskUpdater) UpdateCallbackStatus(ctx context.Context, taskID string, status models.CallbackStatus, attempts int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCallbackStatus", ctx, taskID, status, attempts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateCallbackStatus indicates an expected call of UpdateCallbackStatus.
+func (mr *MockTaskUpdaterMockRecorder) UpdateCallbackStatus(ctx, taskID, status, attempts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCallbackStatus", reflect.TypeOf((*MockTaskUpdater)(nil).UpdateCallbackStatus), ctx, taskID, status, attempts)
+}
+
+// UpdateTaskError mocks base method.
+func (m *MockTaskUpdater) UpdateTaskError(ctx context.Context, lease models.TaskLease, taskResult models.TaskResult) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTaskError", ctx, lease, taskResult)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateTaskError indicates an expected call of UpdateTaskError.
+func (mr *MockTaskUpdaterMockRecorder) UpdateTaskError(ctx, lease, taskResult any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTaskError", reflect.TypeOf((*MockTaskUpdater)(nil).UpdateTaskError), ctx, lease, taskResult)
+}
+
+// UpdateTaskResult mocks base method.
+func (m *MockTaskUpdater) UpdateTaskResult(ctx context.Context, lease models.TaskLease, taskResult models.TaskResult) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTaskResult", ctx, lease, taskResult)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateTaskResult indicates an expected call of UpdateTaskResult.
+func (mr *MockTaskUpdaterMockRecorder) UpdateTaskResult(ctx, lease, taskResult any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTaskResult", reflect.TypeOf((*MockTaskUpdater)(nil).UpdateTaskResult), ctx, lease, taskResult)
+}
+
+// MockMessageReceiver is a mock of MessageReceiver interface.
+type MockMessageReceiver struct {
+	ctrl     *gomock.Controller
+	recorder *MockMessageReceiverMockRecorder
+	isgomock struct{}
+}
+
+// MockMessageReceiverMockRecorder is the mock recorder for MockMessageReceiver.
+type MockMessageReceiverMockRecorder struct {
+	mock *MockMessageReceiver
+}
+
+// NewMockMessageReceiver creates a new mock instance.
+func NewMockMessageReceiver(ctrl *gomock.Controller) *MockMessageReceiver {
+	mock := &MockMessageReceiver{ctrl: ctrl}
+	mock.recorder = &MockMessageReceiverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMessageReceiver) EXPECT() *MockMessageReceiverMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockMessageReceiver) Close(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockMessageReceiverMockRecorder) Close(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockMessageReceiver)(nil).Close), ctx)
+}
+
+// RequeueTask mocks base method.
+func (m *MockMessageReceiver) RequeueTask(ctx context.Context, task models.Task, delay time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequeueTask", ctx, task, delay)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequeueTask indicates an expected call of RequeueTask.
+func (mr *MockMessageReceiverMockRecorder) RequeueTask(ctx, task, delay any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequeueTask", reflect.TypeOf((*MockMessageReceiver)(nil).RequeueTask), ctx, task, delay)
+}
+
+// SendCompletion mocks base method.
+func (m *MockMessageReceiver) SendCompletion(ctx context.Context, completion models.TaskCompletion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendCompletion", ctx, completion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendCompletion indicates an expected call of SendCompletion.
+func (mr *MockMessageReceiverMockRecorder) SendCompletion(ctx, completion any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCompletion", reflect.TypeOf((*MockMessageReceiver)(nil).SendCompletion), ctx, completion)
+}
+
+// SendDeadLetter mocks base method.
+func (m *MockMessageReceiver) SendDeadLetter(ctx context.Context, deadLetter models.DeadLetter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendDeadLetter", ctx, deadLetter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendDeadLetter indicates an expected call of SendDeadLetter.
+func (mr *MockMessageReceiverMockRecorder) SendDeadLetter(ctx, deadLetter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendDeadLetter", reflect.TypeOf((*MockMessageReceiver)(nil).SendDeadLetter), ctx, deadLetter)
+}
+
+// Subscribe mocks base method.
+func (m *MockMessageReceiver) Subscribe(ctx context.Context, taskChan chan models.TaskMessage) (context.CancelFunc, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", ctx, taskChan)
+	ret0, _ := ret[0].(context.CancelFunc)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockMessageReceiverMockRecorder) Subscribe(ctx, taskChan any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockMessageReceiver)(nil).Subscribe), ctx, taskChan)
+}
+
+// SubscribeCancel mocks base method.
+func (m *MockMessageReceiver) SubscribeCancel(ctx context.Context, cancelChan chan string) (context.CancelFunc, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeCancel", ctx, cancelChan)
+	ret0, _ := ret[0].(context.CancelFunc)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeCancel indicates an expected call of SubscribeCancel.
+func (mr *MockMessageReceiverMockRecorder) SubscribeCancel(ctx, cancelChan any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeCancel", reflect.TypeOf((*MockMessageReceiver)(nil).SubscribeCancel), ctx, cancelChan)
+}
+
+// MockTaskExecutor is a mock of TaskExecutor interface.
+type MockTaskExecutor struct {
+	ctrl     *gomock.Controller
+	recorder *MockTaskExecutorMockRecorder
+	isgomock struct{}
+}
+
+// MockTaskExecutorMockRecorder is the mock recorder for MockTaskExecutor.
+type MockTaskExecutorMockRecorder struct {
+	mock *MockTaskExecutor
+}
+
+// NewMockTaskExecutor creates a new mock instance.
+func NewMockTaskExecutor(ctrl *gomock.Controller) *MockTaskExecutor {
+	mock := &MockTaskExecutor{ctrl: ctrl}
+	mock.recorder = &MockTaskExecutorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTaskExecutor) EXPECT() *MockTaskExecutorMockRecorder {
+	return m.recorder
+}
+
+// Execute mocks base method.
+func (m *MockTaskExecutor) Execute(ctx context.Context, task models.Task) (models.TaskResult, models.TaskAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Execute", ctx, task)
+	ret0, _ := ret[0].(models.TaskResult)
+	ret1, _ := ret[1].(models.TaskAttempt)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Execute indicates an expected call of Execute.
+func (mr *MockTaskExecutorMockRecorder) Execute(ctx, task any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Execute", reflect.TypeOf((*MockTaskExecutor)(nil).Execute), ctx, task)
+}
+
+// MockCallbackSender is a mock of CallbackSender interface.
+type MockCallbackSender struct {
+	ctrl     *gomock.Controller
+	recorder *MockCallbackSenderMockRecorder
+	isgomock struct{}
+}
+
+// MockCallbackSenderMockRecorder is the mock recorder for MockCallbackSender.
+type MockCallbackSenderMockRecorder struct {
+	mock *MockCallbackSender
+}
+
+// NewMockCallbackSender creates a new mock instance.
+func NewMockCallbackSender(ctrl *gomock.Controller) *MockCallbackSender {
+	mock := &MockCallbackSender{ctrl: ctrl}
+	mock.recorder = &MockCallbackSenderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCallbackSender) EXPECT() *MockCallbackSenderMockRecorder {
+	return m.recorder
+}
+
+// SendCallback mocks base method.
+func (m *MockCallbackSender) SendCallback(ctx context.Context, task models.Task, taskResult models.TaskResult) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendCallback", ctx, task, taskResult)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendCallback indicates an expected call of SendCallback.
+func (mr *MockCallbackSenderMockRecorder) SendCallback(ctx, task, taskResult any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCallback", reflect.TypeOf((*MockCallbackSender)(nil).SendCallback), ctx, task, taskResult)
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/models"
+)
+
+const (
+	defaultOutboxPollInterval = time.Second
+	defaultOutboxBatchSize    = 100
+	defaultOutboxMaxAttempts  = 10
+)
+
+type outboxRelay struct {
+	log          *slog.Logger
+	taskProvider TaskProvider
+	msgSender    MessageSender
+	interval     time.Duration
+	batchSize    int
+	maxAttempts  int
+	wakeup       chan struct{}
+}
+
+func newOutboxRelay(log *slog.Logger, cfg config.Config, taskProvider TaskProvider,
+	msgSender MessageSender) *outboxRelay {
+	r := &outboxRelay{
+		log:          log,
+		taskProvider: taskProvider,
+		msgSender:    msgSender,
+		interval:     cfg.ProxyOutboxPollInterval,
+		batchSize:    cfg.ProxyOutboxBatchSize,
+		maxAttempts:  cfg.ProxyOutboxMaxAttempts,
+		wakeup:       make(chan struct{}, 1),
+	}
+	if r.interval <= 0 {
+		r.interval = defaultOutboxPollInterval
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = defaultOutboxBatchSize
+	}
+	if r.maxAttempts <= 0 {
+		r.maxAttempts = defaultOutboxMaxAttempts
+	}
+
+	return r
+}
+
+func (r *outboxRelay) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.publishPending(ctx)
+
+		select {
+		case <-r.wakeup:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *outboxRelay) notify() {
+	select {
+	case r.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (r *outboxRelay) publishPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		published, err := r.taskProvider.PublishOutbox(ctx, r.batchSize, r.maxAttempts, r.publish)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.log.Error("failed to publish outbox", slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		if published < r.batchSize {
+			return
+		}
+	}
+}
+
+func (r *outboxRelay) publish(ctx context.Context, msgs []models.OutboxMessage) []error {
+	tasks := make([]models.Task, len(msgs))
+	for i, msg := range msgs {
+		tasks[i] = msg.Task
+	}
+
+	errs := r.msgSender.SendTasks(ctx, tasks)
+	for i, msg := range msgs {
+		if errs[i] == nil {
+			continue
+		}
+
+		r.log.Warn("failed to publish task from outbox", slog.String(RequestIDKey, msg.RequestID),
+			slog.String("task_id", msg.Task.ID), slog.String("error", errs[i].Error()))
+		errs[i] = fmt.Errorf("failed to send task %s: %w", msg.Task.ID, errs[i])
+	}
+
+	return errs
+}
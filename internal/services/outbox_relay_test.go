@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/models"
+	mock_services "github.com/ASsssker/proxy/internal/services/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestOutboxRelay_PublishPending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockProvider := mock_services.NewMockTaskProvider(ctrl)
+	mockSender := mock_services.NewMockMessageSender(ctrl)
+	relay := newOutboxRelay(slog.New(slog.DiscardHandler), config.Config{
+		ProxyServiceConfig: config.ProxyServiceConfig{ProxyOutboxBatchSize: 2},
+	}, mockProvider, mockSender)
+
+	messages := []models.OutboxMessage{
+		{ID: 1, RequestID: uuid.NewString(), Task: models.Task{ID: uuid.NewString()}},
+		{ID: 2, RequestID: uuid.NewString(), Task: models.Task{ID: uuid.NewString()}},
+		{ID: 3, RequestID: uuid.NewString(), Task: models.Task{ID: uuid.NewString()}},
+	}
+	errSend := errors.New("broker unavailable")
+
+	publishBatch := func(batch []models.OutboxMessage) func(context.Context, int, int,
+		func(context.Context, []models.OutboxMessage) []error) (int, error) {
+		return func(ctx context.Context, limit, maxAttempts int,
+			publish func(context.Context, []models.OutboxMessage) []error) (int, error) {
+			require.Equal(t, 2, limit)
+			require.Equal(t, defaultOutboxMaxAttempts, maxAttempts)
+
+			published := 0
+			for _, err := range publish(ctx, batch) {
+				if err == nil {
+					published++
+				}
+			}
+			return published, nil
+		}
+	}
+
+	// Полная пачка запрашивает следующую, неполная завершает публикацию до следующего сигнала.
+	gomock.InOrder(
+		mockProvider.EXPECT().PublishOutbox(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(publishBatch(messages[:2])),
+		mockProvider.EXPECT().PublishOutbox(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(publishBatch(messages[2:])),
+	)
+
+	// Пачка публикуется одним вызовом SendTasks.
+	gomock.InOrder(
+		mockSender.EXPECT().SendTasks(gomock.Any(), gomock.Eq([]models.Task{messages[0].Task, messages[1].Task})).
+			Return([]error{nil, nil}),
+		mockSender.EXPECT().SendTasks(gomock.Any(), gomock.Eq([]models.Task{messages[2].Task})).
+			Return([]error{errSend}),
+	)
+
+	relay.publishPending(context.Background())
+}
+
+func TestOutboxRelay_StorageError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockProvider := mock_services.NewMockTaskProvider(ctrl)
+	relay := newOutboxRelay(slog.New(slog.DiscardHandler), config.Config{}, mockProvider,
+		mock_services.NewMockMessageSender(ctrl))
+
+	mockProvider.EXPECT().PublishOutbox(gomock.Any(), gomock.Eq(defaultOutboxBatchSize), gomock.Any(), gomock.Any()).
+		Return(0, errors.New("connection refused")).Times(1)
+
+	relay.publishPending(context.Background())
+}
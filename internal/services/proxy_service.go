@@ -6,75 +6,295 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/ASsssker/proxy/internal/config"
 	"github.com/ASsssker/proxy/internal/models"
 	"github.com/ASsssker/proxy/internal/storage"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
+const (
+	defaultIdempotencyKeyTTL = 24 * time.Hour
+	defaultMaxWaitTimeout    = 60 * time.Second
+	maxIdempotencyKeyLength  = 255
+)
+
 type TaskProvider interface {
-	AddTask(ctx context.Context, taskID string) error
-	GetTask(ctx context.Context, taskID string) (models.TaskResult, error)
+	AddTask(ctx context.Context, task models.Task) error
+	AddIdempotentTask(ctx context.Context, task models.Task, key models.IdempotencyKey) (models.IdempotencyKey, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context, limit int) (int, error)
+	AddTasks(ctx context.Context, tasks []models.Task) error
+	GetTask(ctx context.Context, taskID string, omitBody bool) (models.TaskResult, error)
+	GetTaskBody(ctx context.Context, taskID string) (models.TaskBody, error)
+	GetTaskAttempts(ctx context.Context, taskID string) ([]models.TaskAttempt, error)
+	ListTasks(ctx context.Context, filter models.TaskFilter) (models.TaskList, error)
+	CancelTask(ctx context.Context, taskID string) (models.TaskStatus, error)
+	PublishOutbox(ctx context.Context, limit, maxAttempts int,
+		publish func(ctx context.Context, msgs []models.OutboxMessage) []error) (int, error)
+	PromoteScheduledTasks(ctx context.Context, limit int) (int, error)
+	AddDeadLetter(ctx context.Context, deadLetter models.DeadLetter) error
+	ListDeadLetters(ctx context.Context, filter models.DeadLetterFilter) (models.DeadLetterList, error)
+	GetDeadLetter(ctx context.Context, id string) (models.DeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, id string) (models.DeadLetter, error)
 	Close(ctx context.Context) error
 }
 
 type MessageSender interface {
 	SendTask(ctx context.Context, task models.Task) error
+	SendTasks(ctx context.Context, tasks []models.Task) (errs []error)
+	SendCancel(ctx context.Context, taskID string) error
+	SubscribeCompletions(ctx context.Context, completionChan chan models.TaskCompletion) (context.CancelFunc, error)
+	SubscribeDeadLetters(ctx context.Context,
+		handle func(ctx context.Context, deadLetter models.DeadLetter) error) (context.CancelFunc, error)
 	Close(ctx context.Context) error
 }
 
 type ProxyService struct {
-	log          *slog.Logger
-	taskProvider TaskProvider
-	msgSender    MessageSender
-	validator    *validator.Validate
+	log            *slog.Logger
+	taskProvider   TaskProvider
+	msgSender      MessageSender
+	validator      *validator.Validate
+	maxWaitTimeout time.Duration
+	idempotencyTTL time.Duration
+	batchLimit     int
+	waiters        *taskWaiters
+	outbox         *outboxRelay
+	scheduler      *taskScheduler
+	sweeper        *idempotencySweeper
+	completionChan chan models.TaskCompletion
+	stopCtx        context.Context
+	stop           context.CancelFunc
+	relayDone      sync.WaitGroup
 }
 
-func NewProxyService(log *slog.Logger, taskProvider TaskProvider, msgSender MessageSender,
+func NewProxyService(log *slog.Logger, cfg config.Config, taskProvider TaskProvider, msgSender MessageSender,
 	validator *validator.Validate) *ProxyService {
+	stopCtx, stop := context.WithCancel(context.Background())
+
+	idempotencyTTL := cfg.ProxyIdempotencyKeyTTL
+	if idempotencyTTL <= 0 {
+		idempotencyTTL = defaultIdempotencyKeyTTL
+	}
+
+	maxWaitTimeout := cfg.ProxyMaxWaitTimeout
+	if maxWaitTimeout <= 0 {
+		maxWaitTimeout = defaultMaxWaitTimeout
+	}
+
+	outbox := newOutboxRelay(log, cfg, taskProvider, msgSender)
+
 	return &ProxyService{
-		log:          log,
-		taskProvider: taskProvider,
-		msgSender:    msgSender,
-		validator:    validator,
+		log:            log,
+		taskProvider:   taskProvider,
+		msgSender:      msgSender,
+		validator:      validator,
+		maxWaitTimeout: maxWaitTimeout,
+		idempotencyTTL: idempotencyTTL,
+		batchLimit:     cfg.ProxyBatchLimit(),
+		waiters:        newTaskWaiters(),
+		outbox:         outbox,
+		scheduler:      newTaskScheduler(log, cfg, taskProvider, outbox.notify),
+		sweeper:        newIdempotencySweeper(log, cfg, taskProvider),
+		completionChan: make(chan models.TaskCompletion),
+		stopCtx:        stopCtx,
+		stop:           stop,
+	}
+}
+
+func (p *ProxyService) Run(ctx context.Context) error {
+	cancel, err := p.msgSender.SubscribeCompletions(ctx, p.completionChan)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe task completions: %w", err)
+	}
+	defer cancel()
+
+	cancelDeadLetters, err := p.msgSender.SubscribeDeadLetters(ctx, p.storeDeadLetter)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe dead letters: %w", err)
+	}
+	defer cancelDeadLetters()
+
+	p.relayDone.Add(3)
+	go func() {
+		defer p.relayDone.Done()
+		p.outbox.run(p.stopCtx)
+	}()
+	go func() {
+		defer p.relayDone.Done()
+		p.scheduler.run(p.stopCtx)
+	}()
+	go func() {
+		defer p.relayDone.Done()
+		p.sweeper.run(p.stopCtx)
+	}()
+
+	for {
+		select {
+		case completion := <-p.completionChan:
+			p.waiters.notify(completion)
+		case <-p.stopCtx.Done():
+			return nil
+		}
 	}
 }
 
-func (p ProxyService) AddTask(ctx context.Context, newTask models.NewTask) (string, error) {
+// AddTask возвращает идентификатор и true, если задача по idempotencyKey уже создана.
+func (p *ProxyService) AddTask(ctx context.Context, newTask models.NewTask, idempotencyKey string) (string, bool,
+	error) {
 	const op = "proxy_service.AddTask"
 	requestID := ctx.Value(RequestIDKey).(string)
 
 	log := p.log.With(slog.String("op", op), slog.String(RequestIDKey, requestID))
 	log.DebugContext(ctx, "start operation")
 
+	if err := p.validateNewTask(newTask); err != nil {
+		return "", false, fmt.Errorf("%s request_id=%s %w", op, requestID, err)
+	}
+
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		return "", false, fmt.Errorf("%s request_id=%s idempotency key is longer than %d: %w",
+			op, requestID, maxIdempotencyKeyLength, ErrValidation)
+	}
+
+	task := newTaskFromRequest(newTask)
+	taskID := task.ID
+
+	// Задача публикуется в брокер из outbox, куда хранилище записывает её вместе с самой задачей.
+	replayed := false
+	if idempotencyKey == "" {
+		if err := p.taskProvider.AddTask(ctx, task); err != nil {
+			return "", false, fmt.Errorf("%s request_id=%s failed to add task: %w", op, requestID, err)
+		}
+	} else {
+		var err error
+		taskID, replayed, err = p.addIdempotentTask(ctx, newTask, task, idempotencyKey)
+		if err != nil {
+			return "", false, fmt.Errorf("%s request_id=%s %w", op, requestID, err)
+		}
+	}
+
+	if replayed {
+		log.InfoContext(ctx, "task request replayed by idempotency key", slog.String("task_id", taskID))
+		return taskID, true, nil
+	}
+	p.outbox.notify()
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return taskID, false, nil
+}
+
+// AddTasks возвращает непрошедшие проверку задачи с ошибкой в их результате, остальные сохраняются
+// одной вставкой.
+func (p *ProxyService) AddTasks(ctx context.Context, batch models.NewTaskBatch) (models.TaskBatchList, error) {
+	const op = "proxy_service.AddTasks"
+	requestID := ctx.Value(RequestIDKey).(string)
+
+	if len(batch.Tasks) == 0 || len(batch.Tasks) > p.batchLimit {
+		return models.TaskBatchList{}, fmt.Errorf("%s request_id=%s batch must contain from 1 to %d tasks: %w",
+			op, requestID, p.batchLimit, ErrValidation)
+	}
+
+	log := p.log.With(slog.String("op", op), slog.String(RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation", slog.Int("tasks", len(batch.Tasks)))
+
+	batchList := models.TaskBatchList{Results: make([]models.TaskBatchResult, len(batch.Tasks))}
+	tasks := make([]models.Task, 0, len(batch.Tasks))
+	for i, newTask := range batch.Tasks {
+		batchList.Results[i].Index = i
+		if err := p.validateNewTask(newTask); err != nil {
+			batchList.Results[i].Error = err.Error()
+			continue
+		}
+
+		task := newTaskFromRequest(newTask)
+		batchList.Results[i].ID = task.ID
+		tasks = append(tasks, task)
+	}
+
+	if len(tasks) > 0 {
+		if err := p.taskProvider.AddTasks(ctx, tasks); err != nil {
+			return models.TaskBatchList{}, fmt.Errorf("%s request_id=%s failed to add tasks: %w", op, requestID, err)
+		}
+		p.outbox.notify()
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed", slog.Int("created", len(tasks)))
+
+	return batchList, nil
+}
+
+func (p *ProxyService) validateNewTask(newTask models.NewTask) error {
 	if err := p.validator.Struct(newTask); err != nil {
-		return "", fmt.Errorf("%s request_id=%s failed to validate task: %w: %w", op, requestID, ErrValidation, err)
+		return fmt.Errorf("failed to validate task: %w: %w", ErrValidation, err)
 	}
 
-	taskID := uuid.NewString()
+	if _, err := models.DecodeBody(newTask.Body, newTask.BodyEncoding); err != nil {
+		return fmt.Errorf("failed to decode task body: %w: %w", ErrValidation, err)
+	}
 
-	if err := p.taskProvider.AddTask(ctx, taskID); err != nil {
-		return "", fmt.Errorf("%s request_id=%s failed to add task: %w", op, requestID, err)
+	if _, err := newTask.StartAt(time.Now()); err != nil {
+		return fmt.Errorf("failed to validate task start time: %w: %w", ErrValidation, err)
 	}
 
-	task := models.Task{
-		ID:      taskID,
+	return nil
+}
+
+func newTaskFromRequest(newTask models.NewTask) models.Task {
+	runAt, _ := newTask.StartAt(time.Now())
+
+	return models.Task{
+		ID:      uuid.NewString(),
 		URL:     newTask.URL,
 		Method:  strings.ToUpper(newTask.Method),
 		Headers: newTask.Headers,
+		Query:   newTask.Query,
 		Body:    newTask.Body,
+
+		BodyEncoding:   newTask.BodyEncoding,
+		CallbackURL:    newTask.CallbackURL,
+		CallbackSecret: newTask.CallbackSecret,
+
+		MaxResponseBytes: newTask.MaxResponseBytes,
+		OversizePolicy:   newTask.OversizePolicy,
+		Retry:            newTask.Retry,
+		RunAt:            runAt,
+		Attempt:          1,
 	}
-	if err := p.msgSender.SendTask(ctx, task); err != nil {
-		return "", fmt.Errorf("%s request_id=%s failed to send task %s: %w", op, requestID, taskID, err)
+}
+
+func (p *ProxyService) addIdempotentTask(ctx context.Context, newTask models.NewTask, task models.Task,
+	idempotencyKey string) (string, bool, error) {
+	fingerprint, err := newTask.Fingerprint()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get task fingerprint: %w", err)
 	}
 
-	log.DebugContext(ctx, "the operation was successfully completed")
+	key := models.IdempotencyKey{
+		Key:         idempotencyKey,
+		Fingerprint: fingerprint,
+		ExpiresAt:   time.Now().Add(p.idempotencyTTL),
+	}
+	stored, err := p.taskProvider.AddIdempotentTask(ctx, task, key)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to add task: %w", err)
+	}
 
-	return taskID, nil
+	if stored.TaskID == task.ID {
+		return task.ID, false, nil
+	}
+
+	if stored.Fingerprint != fingerprint {
+		return "", false, fmt.Errorf("idempotency key %q: %w", idempotencyKey, ErrIdempotencyKeyMismatch)
+	}
+
+	return stored.TaskID, true, nil
 }
 
-func (p ProxyService) GetTaskInfo(ctx context.Context, taskID string) (models.TaskResult, error) {
+func (p *ProxyService) GetTaskInfo(ctx context.Context, taskID string, omitBody bool) (models.TaskResult, error) {
 	const op = "proxy_service.GetTaskInfo"
 	requestID := ctx.Value(RequestIDKey).(string)
 
@@ -86,7 +306,7 @@ func (p ProxyService) GetTaskInfo(ctx context.Context, taskID string) (models.Ta
 	log := p.log.With(slog.String("op", op), slog.String(RequestIDKey, requestID))
 	log.DebugContext(ctx, "start operation")
 
-	taskInfo, err := p.taskProvider.GetTask(ctx, taskID)
+	taskInfo, err := p.taskProvider.GetTask(ctx, taskID, omitBody)
 	if err != nil {
 		if errors.Is(err, storage.ErrTaskNotFound) {
 			return models.TaskResult{}, fmt.Errorf("%s request_id=%s task not found: %w",
@@ -101,7 +321,194 @@ func (p ProxyService) GetTaskInfo(ctx context.Context, taskID string) (models.Ta
 	return taskInfo, nil
 }
 
-func (p ProxyService) Close(ctx context.Context) error {
+func (p *ProxyService) GetTaskBody(ctx context.Context, taskID string) (models.TaskBody, error) {
+	const op = "proxy_service.GetTaskBody"
+	requestID := ctx.Value(RequestIDKey).(string)
+
+	if err := p.validator.Var(taskID, "uuid"); err != nil {
+		return models.TaskBody{}, fmt.Errorf("%s request_id=%s failed to validate task id: %w: %w",
+			op, requestID, ErrValidation, err)
+	}
+
+	log := p.log.With(slog.String("op", op), slog.String(RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	taskBody, err := p.taskProvider.GetTaskBody(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTaskNotFound) {
+			return models.TaskBody{}, fmt.Errorf("%s request_id=%s task not found: %w",
+				op, requestID, ErrTaskNotFound)
+		}
+
+		return models.TaskBody{}, fmt.Errorf("%s request_id=%s failed to get task body: %w", op, requestID, err)
+	}
+
+	if taskBody.Status != models.StatusDone {
+		_ = taskBody.Content.Close()
+		return models.TaskBody{}, fmt.Errorf("%s request_id=%s task status is %q: %w",
+			op, requestID, taskBody.Status, ErrTaskNotCompleted)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return taskBody, nil
+}
+
+func (p *ProxyService) GetTaskAttempts(ctx context.Context, taskID string) (models.TaskAttemptList, error) {
+	const op = "proxy_service.GetTaskAttempts"
+	requestID := ctx.Value(RequestIDKey).(string)
+
+	if err := p.validator.Var(taskID, "uuid"); err != nil {
+		return models.TaskAttemptList{}, fmt.Errorf("%s request_id=%s failed to validate task id: %w: %w",
+			op, requestID, ErrValidation, err)
+	}
+
+	log := p.log.With(slog.String("op", op), slog.String(RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	attempts, err := p.taskProvider.GetTaskAttempts(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTaskNotFound) {
+			return models.TaskAttemptList{}, fmt.Errorf("%s request_id=%s task not found: %w",
+				op, requestID, ErrTaskNotFound)
+		}
+
+		return models.TaskAttemptList{}, fmt.Errorf("%s request_id=%s failed to get task attempts: %w",
+			op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return models.TaskAttemptList{Attempts: attempts}, nil
+}
+
+func (p *ProxyService) WaitTask(ctx context.Context, taskID string, timeout time.Duration) (models.TaskResult, bool,
+	error) {
+	const op = "proxy_service.WaitTask"
+	requestID := ctx.Value(RequestIDKey).(string)
+
+	log := p.log.With(slog.String("op", op), slog.String(RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	timeout = min(timeout, p.maxWaitTimeout)
+
+	// Подписка оформляется до чтения из хранилища, чтобы не пропустить завершение между ними.
+	completed := p.waiters.register(taskID)
+	defer p.waiters.unregister(taskID, completed)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		taskInfo, err := p.taskProvider.GetTask(ctx, taskID, false)
+		if err != nil {
+			if errors.Is(err, storage.ErrTaskNotFound) {
+				return models.TaskResult{}, false, fmt.Errorf("%s request_id=%s task not found: %w",
+					op, requestID, ErrTaskNotFound)
+			}
+
+			return models.TaskResult{}, false, fmt.Errorf("%s request_id=%s failed to get task: %w",
+				op, requestID, err)
+		}
+
+		if taskInfo.Status.IsTerminal() {
+			log.DebugContext(ctx, "the operation was successfully completed")
+			return taskInfo, true, nil
+		}
+
+		select {
+		case <-completed:
+		case <-timer.C:
+			log.DebugContext(ctx, "wait timeout exceeded")
+			return taskInfo, false, nil
+		case <-ctx.Done():
+			return models.TaskResult{}, false, fmt.Errorf("%s request_id=%s wait interrupted: %w",
+				op, requestID, ctx.Err())
+		}
+	}
+}
+
+func (p *ProxyService) ListTasks(ctx context.Context, filter models.TaskFilter) (models.TaskList, error) {
+	const op = "proxy_service.ListTasks"
+	requestID := ctx.Value(RequestIDKey).(string)
+
+	if filter.Order == "" {
+		filter.Order = models.SortDesc
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultTasksListLimit
+	}
+	filter.Method = strings.ToUpper(filter.Method)
+
+	if err := p.validator.Struct(filter); err != nil {
+		return models.TaskList{}, fmt.Errorf("%s request_id=%s failed to validate filter: %w: %w",
+			op, requestID, ErrValidation, err)
+	}
+
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && filter.CreatedTo.Before(filter.CreatedFrom) {
+		return models.TaskList{}, fmt.Errorf("%s request_id=%s created_to is before created_from: %w",
+			op, requestID, ErrValidation)
+	}
+
+	log := p.log.With(slog.String("op", op), slog.String(RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	taskList, err := p.taskProvider.ListTasks(ctx, filter)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			return models.TaskList{}, fmt.Errorf("%s request_id=%s invalid cursor: %w: %w",
+				op, requestID, ErrValidation, err)
+		}
+
+		return models.TaskList{}, fmt.Errorf("%s request_id=%s failed to list tasks: %w", op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return taskList, nil
+}
+
+func (p *ProxyService) CancelTask(ctx context.Context, taskID string) error {
+	const op = "proxy_service.CancelTask"
+	requestID := ctx.Value(RequestIDKey).(string)
+
+	if err := p.validator.Var(taskID, "uuid"); err != nil {
+		return fmt.Errorf("%s request_id=%s failed to validate task id: %w: %w", op, requestID, ErrValidation, err)
+	}
+
+	log := p.log.With(slog.String("op", op), slog.String(RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	prevStatus, err := p.taskProvider.CancelTask(ctx, taskID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrTaskNotFound):
+			return fmt.Errorf("%s request_id=%s task not found: %w", op, requestID, ErrTaskNotFound)
+		case errors.Is(err, storage.ErrTaskFinalized):
+			return fmt.Errorf("%s request_id=%s task already finalized: %w", op, requestID, ErrTaskNotCancellable)
+		default:
+			return fmt.Errorf("%s request_id=%s failed to cancel task: %w", op, requestID, err)
+		}
+	}
+
+	// Задача в статусе new будет пропущена исполнителем, прерывать нужно только запущенные.
+	if prevStatus == models.StatusInProcess {
+		if err := p.msgSender.SendCancel(ctx, taskID); err != nil {
+			return fmt.Errorf("%s request_id=%s failed to send cancel signal: %w", op, requestID, err)
+		}
+	}
+
+	p.waiters.notify(models.TaskCompletion{TaskID: taskID, Status: models.StatusCancelled})
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (p *ProxyService) Close(ctx context.Context) error {
+	p.stop()
+	p.relayDone.Wait()
+
 	errCloseTaskProvider := p.taskProvider.Close(ctx)
 	if errCloseTaskProvider != nil {
 		errCloseTaskProvider = fmt.Errorf("failed to close task provider: %v", errCloseTaskProvider)
@@ -2,12 +2,17 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log/slog"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/ASsssker/proxy/internal/config"
 	"github.com/ASsssker/proxy/internal/models"
 	mock_services "github.com/ASsssker/proxy/internal/services/mocks"
+	"github.com/ASsssker/proxy/internal/storage"
 	"github.com/ASsssker/proxy/internal/validation"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
@@ -26,8 +31,19 @@ func TestAddTask_GoodPath(t *testing.T) {
 			task: models.NewTask{
 				URL:    "http://example.com",
 				Method: "get",
-				Headers: map[string]string{
-					"custom-header": "custom_value",
+				Headers: models.Headers{
+					"custom-header": {"custom_value"},
+				},
+				Body: "body"},
+		},
+		{
+			name: "put method with query",
+			ctx:  newContextWithRequestID(),
+			task: models.NewTask{
+				URL:    "http://example.com?page=1",
+				Method: "put",
+				Query: map[string][]string{
+					"tag": {"first", "second"},
 				},
 				Body: "body"},
 		},
@@ -37,8 +53,8 @@ func TestAddTask_GoodPath(t *testing.T) {
 			task: models.NewTask{
 				URL:    "http://example.com",
 				Method: "GET",
-				Headers: map[string]string{
-					"custom-header": "custom_value",
+				Headers: models.Headers{
+					"custom-header": {"custom_value"},
 				},
 				Body: "body"},
 		},
@@ -53,30 +69,25 @@ func TestAddTask_GoodPath(t *testing.T) {
 		mockProvider.EXPECT().
 			AddTask(gomock.Eq(tt.ctx), gomock.Any()).
 			Return(nil).AnyTimes()
-
-		mockSender.EXPECT().
-			SendTask(gomock.Eq(tt.ctx), gomock.Any()).
-			Return(nil).AnyTimes()
 	}
 
 	service := newProxyService(mockProvider, mockSender)
 	for _, tt := range tests {
-		id, err := service.AddTask(tt.ctx, tt.task)
+		id, replayed, err := service.AddTask(tt.ctx, tt.task, "")
 		require.NoError(t, err)
+		require.False(t, replayed)
 		require.NotPanics(t, func() { uuid.MustParse(id) })
 	}
 }
 
 func TestAddTask_BadPath(t *testing.T) {
 	errUndefTaskProvider := errors.New("undefined task provider error")
-	errUndefMsgSender := errors.New("undefined message sender error")
 
 	tests := []struct {
 		name            string
 		ctx             context.Context
 		task            models.NewTask
 		errTaskProvider error
-		errMsgSender    error
 		errExpected     error
 	}{
 		{
@@ -101,7 +112,7 @@ func TestAddTask_BadPath(t *testing.T) {
 			ctx:  newContextWithRequestID(),
 			task: models.NewTask{
 				URL:    "http://example.com",
-				Method: "PUT",
+				Method: "TRACE",
 			},
 			errExpected: ErrValidation,
 		},
@@ -123,24 +134,55 @@ func TestAddTask_BadPath(t *testing.T) {
 			errExpected: ErrValidation,
 		},
 		{
-			name: "task provider undefined error",
+			name: "negative response limit",
+			ctx:  newContextWithRequestID(),
+			task: models.NewTask{
+				URL:              "http://example.com",
+				Method:           "GET",
+				MaxResponseBytes: -1,
+			},
+			errExpected: ErrValidation,
+		},
+		{
+			name: "invalid oversize policy",
+			ctx:  newContextWithRequestID(),
+			task: models.NewTask{
+				URL:            "http://example.com",
+				Method:         "GET",
+				OversizePolicy: "drop",
+			},
+			errExpected: ErrValidation,
+		},
+		{
+			name: "invalid delay",
 			ctx:  newContextWithRequestID(),
 			task: models.NewTask{
 				URL:    "http://example.com",
-				Method: "get",
+				Method: "GET",
+				Delay:  "soon",
 			},
-			errTaskProvider: errUndefTaskProvider,
-			errExpected:     errUndefTaskProvider,
+			errExpected: ErrValidation,
 		},
 		{
-			name: "message sender undefined error",
+			name: "run_at with delay",
+			ctx:  newContextWithRequestID(),
+			task: models.NewTask{
+				URL:    "http://example.com",
+				Method: "GET",
+				RunAt:  &time.Time{},
+				Delay:  "1m",
+			},
+			errExpected: ErrValidation,
+		},
+		{
+			name: "task provider undefined error",
 			ctx:  newContextWithRequestID(),
 			task: models.NewTask{
 				URL:    "http://example.com",
 				Method: "get",
 			},
-			errMsgSender: errUndefMsgSender,
-			errExpected:  errUndefMsgSender,
+			errTaskProvider: errUndefTaskProvider,
+			errExpected:     errUndefTaskProvider,
 		},
 	}
 
@@ -153,34 +195,561 @@ func TestAddTask_BadPath(t *testing.T) {
 		mockProvider.EXPECT().
 			AddTask(gomock.Eq(tt.ctx), gomock.Any()).
 			Return(tt.errTaskProvider).AnyTimes()
-
-		mockSender.EXPECT().
-			SendTask(gomock.Eq(tt.ctx), gomock.Any()).
-			Return(tt.errMsgSender).AnyTimes()
 	}
 	service := newProxyService(mockProvider, mockSender)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			id, err := service.AddTask(tt.ctx, tt.task)
+			id, _, err := service.AddTask(tt.ctx, tt.task, "")
 			require.Empty(t, id)
 			require.ErrorIs(t, err, tt.errExpected)
 		})
 	}
 }
 
-func newProxyService(taskProvider TaskProvider, msgSender MessageSender) ProxyService {
+func TestAddTask_Scheduled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockProvider := mock_services.NewMockTaskProvider(ctrl)
+	service := newProxyService(mockProvider, mock_services.NewMockMessageSender(ctrl))
+
+	var added []models.Task
+	mockProvider.EXPECT().AddTask(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context,
+		task models.Task) error {
+		added = append(added, task)
+		return nil
+	}).Times(3)
+
+	ctx := newContextWithRequestID()
+	runAt := time.Now().Add(time.Hour)
+	for _, task := range []models.NewTask{
+		{URL: "http://example.com", Method: "GET", Delay: "90s"},
+		{URL: "http://example.com", Method: "GET", RunAt: &runAt},
+		{URL: "http://example.com", Method: "GET", Delay: "0"},
+	} {
+		_, _, err := service.AddTask(ctx, task, "")
+		require.NoError(t, err)
+	}
+
+	require.NotNil(t, added[0].RunAt)
+	require.WithinDuration(t, time.Now().Add(90*time.Second), *added[0].RunAt, time.Second)
+	require.Equal(t, models.StatusScheduled, added[0].InitialStatus())
+	require.True(t, runAt.Equal(*added[1].RunAt))
+
+	// Нулевая задержка выполняет задачу сразу.
+	require.Nil(t, added[2].RunAt)
+	require.Equal(t, models.StatusNew, added[2].InitialStatus())
+}
+
+func TestAddTask_IdempotencyKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockProvider := mock_services.NewMockTaskProvider(ctrl)
+	service := newProxyService(mockProvider, mock_services.NewMockMessageSender(ctrl))
+
+	ctx := newContextWithRequestID()
+	newTask := models.NewTask{URL: "http://example.com", Method: "POST", Body: "body"}
+	fingerprint, err := newTask.Fingerprint()
+	require.NoError(t, err)
+
+	var stored models.IdempotencyKey
+	mockProvider.EXPECT().AddIdempotentTask(gomock.Eq(ctx), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, task models.Task, key models.IdempotencyKey) (models.IdempotencyKey,
+			error) {
+			require.Equal(t, "key-1", key.Key)
+			if stored.Key == "" {
+				stored = key
+				stored.TaskID = task.ID
+			}
+			return stored, nil
+		}).Times(3)
+
+	id, replayed, err := service.AddTask(ctx, newTask, "key-1")
+	require.NoError(t, err)
+	require.False(t, replayed)
+	require.Equal(t, fingerprint, stored.Fingerprint)
+
+	replayedID, replayed, err := service.AddTask(ctx, newTask, "key-1")
+	require.NoError(t, err)
+	require.True(t, replayed)
+	require.Equal(t, id, replayedID)
+
+	newTask.Body = "other body"
+	_, _, err = service.AddTask(ctx, newTask, "key-1")
+	require.ErrorIs(t, err, ErrIdempotencyKeyMismatch)
+}
+
+func TestAddTasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockProvider := mock_services.NewMockTaskProvider(ctrl)
+	service := newProxyService(mockProvider, mock_services.NewMockMessageSender(ctrl))
+	ctx := newContextWithRequestID()
+
+	batch := models.NewTaskBatch{Tasks: []models.NewTask{
+		{URL: "http://example.com/1", Method: "get"},
+		{URL: "http:dsd//incorrect.", Method: "GET"},
+		{URL: "http://example.com/2", Method: "POST", Body: "body"},
+	}}
+
+	var added []models.Task
+	mockProvider.EXPECT().AddTasks(gomock.Eq(ctx), gomock.Any()).
+		DoAndReturn(func(_ context.Context, tasks []models.Task) error {
+			added = tasks
+			return nil
+		})
+
+	batchList, err := service.AddTasks(ctx, batch)
+	require.NoError(t, err)
+	require.Len(t, batchList.Results, 3)
+
+	// Задача с ошибкой проверки не мешает остальным и не сохраняется.
+	require.Len(t, added, 2)
+	require.Equal(t, models.TaskBatchResult{Index: 0, ID: added[0].ID}, batchList.Results[0])
+	require.Equal(t, "GET", added[0].Method)
+	require.Equal(t, 1, batchList.Results[1].Index)
+	require.Empty(t, batchList.Results[1].ID)
+	require.NotEmpty(t, batchList.Results[1].Error)
+	require.Equal(t, models.TaskBatchResult{Index: 2, ID: added[1].ID}, batchList.Results[2])
+
+	_, err = service.AddTasks(ctx, models.NewTaskBatch{})
+	require.ErrorIs(t, err, ErrValidation)
+
+	batch.Tasks = make([]models.NewTask, service.batchLimit+1)
+	_, err = service.AddTasks(ctx, batch)
+	require.ErrorIs(t, err, ErrValidation)
+}
+
+func TestListTasks(t *testing.T) {
+	tests := []struct {
+		name        string
+		filter      models.TaskFilter
+		errProvider error
+		errExpected error
+	}{
+		{
+			name:   "default filter",
+			filter: models.TaskFilter{},
+		},
+		{
+			name: "full filter",
+			filter: models.TaskFilter{
+				Status:      models.StatusDone,
+				Host:        "example.com",
+				Method:      "get",
+				StatusCode:  http.StatusOK,
+				CreatedFrom: time.Now().Add(-time.Hour),
+				CreatedTo:   time.Now(),
+				Order:       models.SortAsc,
+				Limit:       100,
+			},
+		},
+		{
+			name:        "unknown status",
+			filter:      models.TaskFilter{Status: "undefined"},
+			errExpected: ErrValidation,
+		},
+		{
+			name:        "unknown order",
+			filter:      models.TaskFilter{Order: "random"},
+			errExpected: ErrValidation,
+		},
+		{
+			name:        "too big limit",
+			filter:      models.TaskFilter{Limit: 101},
+			errExpected: ErrValidation,
+		},
+		{
+			name:        "invalid time range",
+			filter:      models.TaskFilter{CreatedFrom: time.Now(), CreatedTo: time.Now().Add(-time.Hour)},
+			errExpected: ErrValidation,
+		},
+		{
+			name:        "invalid cursor",
+			filter:      models.TaskFilter{Cursor: "invalid"},
+			errProvider: storage.ErrInvalidCursor,
+			errExpected: ErrValidation,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProvider := mock_services.NewMockTaskProvider(ctrl)
+			mockProvider.EXPECT().
+				ListTasks(gomock.Any(), gomock.Any()).
+				Return(models.TaskList{}, tt.errProvider).AnyTimes()
+
+			service := newProxyService(mockProvider, mock_services.NewMockMessageSender(ctrl))
+			_, err := service.ListTasks(newContextWithRequestID(), tt.filter)
+			if tt.errExpected == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.errExpected)
+		})
+	}
+}
+
+func TestCancelTask(t *testing.T) {
+	tests := []struct {
+		name        string
+		taskID      string
+		prevStatus  models.TaskStatus
+		errProvider error
+		sendCancel  bool
+		errExpected error
+	}{
+		{
+			name:       "new task",
+			taskID:     uuid.NewString(),
+			prevStatus: models.StatusNew,
+		},
+		{
+			name:       "running task",
+			taskID:     uuid.NewString(),
+			prevStatus: models.StatusInProcess,
+			sendCancel: true,
+		},
+		{
+			name:        "invalid task id",
+			taskID:      "invalid",
+			errExpected: ErrValidation,
+		},
+		{
+			name:        "task not found",
+			taskID:      uuid.NewString(),
+			errProvider: storage.ErrTaskNotFound,
+			errExpected: ErrTaskNotFound,
+		},
+		{
+			name:        "finalized task",
+			taskID:      uuid.NewString(),
+			errProvider: storage.ErrTaskFinalized,
+			errExpected: ErrTaskNotCancellable,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProvider := mock_services.NewMockTaskProvider(ctrl)
+			mockProvider.EXPECT().
+				CancelTask(gomock.Any(), gomock.Eq(tt.taskID)).
+				Return(tt.prevStatus, tt.errProvider).AnyTimes()
+
+			mockSender := mock_services.NewMockMessageSender(ctrl)
+			if tt.sendCancel {
+				mockSender.EXPECT().SendCancel(gomock.Any(), gomock.Eq(tt.taskID)).Return(nil).Times(1)
+			}
+
+			service := newProxyService(mockProvider, mockSender)
+			err := service.CancelTask(newContextWithRequestID(), tt.taskID)
+			if tt.errExpected == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.errExpected)
+		})
+	}
+}
+
+func TestDeadLetter_CallbackSecretRedacted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockProvider := mock_services.NewMockTaskProvider(ctrl)
+	service := newProxyService(mockProvider, mock_services.NewMockMessageSender(ctrl))
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET",
+		CallbackURL: "http://example.com/callback", CallbackSecret: "top-secret"}
+	payload, err := json.Marshal(task)
+	require.NoError(t, err)
+
+	var stored models.DeadLetter
+	mockProvider.EXPECT().AddDeadLetter(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, deadLetter models.DeadLetter) error {
+			stored = deadLetter
+			return nil
+		})
+	mockProvider.EXPECT().GetDeadLetter(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context,
+		_ string) (models.DeadLetter, error) {
+		return stored, nil
+	})
+
+	// JetStream передаёт исходное сообщение задачи целиком, вместе с секретом.
+	deadLetter := models.NewDeadLetter(task.ID, models.DeadLetterMaxDeliveries, "exhausted", payload)
+	require.NoError(t, service.storeDeadLetter(context.Background(), deadLetter))
+
+	fetched, err := service.GetDeadLetter(newContextWithRequestID(), deadLetter.ID)
+	require.NoError(t, err)
+	require.NotContains(t, string(fetched.Payload), "top-secret")
+
+	var fetchedTask models.Task
+	require.NoError(t, json.Unmarshal(fetched.Payload, &fetchedTask))
+	task.CallbackSecret = ""
+	require.Equal(t, task, fetchedTask)
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	replayedID, notReplayableID, failedID := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	taskID := uuid.NewString()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockProvider := mock_services.NewMockTaskProvider(ctrl)
+	mockProvider.EXPECT().ReplayDeadLetter(gomock.Any(), gomock.Eq(replayedID)).
+		Return(models.DeadLetter{ID: replayedID, TaskID: taskID}, nil)
+	mockProvider.EXPECT().ReplayDeadLetter(gomock.Any(), gomock.Eq(notReplayableID)).
+		Return(models.DeadLetter{}, storage.ErrNotReplayable)
+	mockProvider.EXPECT().ReplayDeadLetter(gomock.Any(), gomock.Eq(failedID)).
+		Return(models.DeadLetter{}, errors.New("connection refused"))
+
+	service := newProxyService(mockProvider, mock_services.NewMockMessageSender(ctrl))
+
+	replayList, err := service.ReplayDeadLetters(newContextWithRequestID(), models.DeadLetterReplay{
+		IDs: []string{replayedID, notReplayableID, failedID},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []models.DeadLetterReplayResult{
+		{ID: replayedID, TaskID: taskID},
+		{ID: notReplayableID, Error: ErrDeadLetterNotReplayable.Error()},
+		{ID: failedID, Error: "failed to replay dead letter"},
+	}, replayList.Results)
+
+	t.Run("not yet replayed by reason", func(t *testing.T) {
+		mockProvider.EXPECT().ListDeadLetters(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ any, filter models.DeadLetterFilter) (models.DeadLetterList, error) {
+				require.Equal(t, models.DeadLetterWorkerLost, filter.Reason)
+				require.NotNil(t, filter.Replayed)
+				require.False(t, *filter.Replayed)
+				require.Equal(t, 5, filter.Limit)
+				return models.DeadLetterList{DeadLetters: []models.DeadLetter{{ID: replayedID}}}, nil
+			})
+		mockProvider.EXPECT().ReplayDeadLetter(gomock.Any(), gomock.Eq(replayedID)).
+			Return(models.DeadLetter{ID: replayedID, TaskID: taskID}, nil)
+
+		replayList, err := service.ReplayDeadLetters(newContextWithRequestID(), models.DeadLetterReplay{
+			Reason: models.DeadLetterWorkerLost,
+			Limit:  5,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []models.DeadLetterReplayResult{{ID: replayedID, TaskID: taskID}}, replayList.Results)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		_, err := service.ReplayDeadLetters(newContextWithRequestID(), models.DeadLetterReplay{IDs: []string{"1"}})
+		require.ErrorIs(t, err, ErrValidation)
+	})
+}
+
+func TestGetTaskBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		taskID      string
+		status      models.TaskStatus
+		errProvider error
+		errExpected error
+	}{
+		{
+			name:   "done task",
+			taskID: uuid.NewString(),
+			status: models.StatusDone,
+		},
+		{
+			name:        "task in process",
+			taskID:      uuid.NewString(),
+			status:      models.StatusInProcess,
+			errExpected: ErrTaskNotCompleted,
+		},
+		{
+			name:        "failed task",
+			taskID:      uuid.NewString(),
+			status:      models.StatusError,
+			errExpected: ErrTaskNotCompleted,
+		},
+		{
+			name:        "invalid task id",
+			taskID:      "invalid",
+			errExpected: ErrValidation,
+		},
+		{
+			name:        "task not found",
+			taskID:      uuid.NewString(),
+			errProvider: storage.ErrTaskNotFound,
+			errExpected: ErrTaskNotFound,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProvider := mock_services.NewMockTaskProvider(ctrl)
+			mockProvider.EXPECT().
+				GetTaskBody(gomock.Any(), gomock.Eq(tt.taskID)).
+				Return(models.TaskBody{Status: tt.status, Content: storage.NewBytesReader([]byte("body"))},
+					tt.errProvider).AnyTimes()
+
+			service := newProxyService(mockProvider, mock_services.NewMockMessageSender(ctrl))
+			taskBody, err := service.GetTaskBody(newContextWithRequestID(), tt.taskID)
+			if tt.errExpected == nil {
+				require.NoError(t, err)
+				require.Equal(t, models.StatusDone, taskBody.Status)
+				return
+			}
+			require.ErrorIs(t, err, tt.errExpected)
+		})
+	}
+}
+
+func TestGetTaskAttempts(t *testing.T) {
+	tests := []struct {
+		name        string
+		taskID      string
+		attempts    []models.TaskAttempt
+		errProvider error
+		errExpected error
+	}{
+		{
+			name:   "task with attempts",
+			taskID: uuid.NewString(),
+			attempts: []models.TaskAttempt{
+				{Attempt: 1, ErrorCode: models.ErrorConnectTimeout},
+				{Attempt: 2, StatusCode: http.StatusOK},
+			},
+		},
+		{
+			name:     "task without attempts",
+			taskID:   uuid.NewString(),
+			attempts: []models.TaskAttempt{},
+		},
+		{
+			name:        "invalid task id",
+			taskID:      "invalid",
+			errExpected: ErrValidation,
+		},
+		{
+			name:        "task not found",
+			taskID:      uuid.NewString(),
+			errProvider: storage.ErrTaskNotFound,
+			errExpected: ErrTaskNotFound,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProvider := mock_services.NewMockTaskProvider(ctrl)
+			mockProvider.EXPECT().
+				GetTaskAttempts(gomock.Any(), gomock.Eq(tt.taskID)).
+				Return(tt.attempts, tt.errProvider).AnyTimes()
+
+			service := newProxyService(mockProvider, mock_services.NewMockMessageSender(ctrl))
+			attemptList, err := service.GetTaskAttempts(newContextWithRequestID(), tt.taskID)
+			if tt.errExpected == nil {
+				require.NoError(t, err)
+				require.Equal(t, tt.attempts, attemptList.Attempts)
+				return
+			}
+			require.ErrorIs(t, err, tt.errExpected)
+		})
+	}
+}
+
+func TestWaitTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("already finished", func(t *testing.T) {
+		taskID := uuid.NewString()
+		mockProvider := mock_services.NewMockTaskProvider(ctrl)
+		mockProvider.EXPECT().GetTask(gomock.Any(), gomock.Eq(taskID), gomock.Eq(false)).
+			Return(models.TaskResult{ID: taskID, Status: models.StatusDone}, nil).Times(1)
+
+		service := newProxyService(mockProvider, mock_services.NewMockMessageSender(ctrl))
+		taskInfo, completed, err := service.WaitTask(newContextWithRequestID(), taskID, time.Second)
+		require.NoError(t, err)
+		require.True(t, completed)
+		require.Equal(t, models.StatusDone, taskInfo.Status)
+	})
+
+	t.Run("completion notification", func(t *testing.T) {
+		taskID := uuid.NewString()
+		mockProvider := mock_services.NewMockTaskProvider(ctrl)
+		service := newProxyService(mockProvider, mock_services.NewMockMessageSender(ctrl))
+
+		gomock.InOrder(
+			mockProvider.EXPECT().GetTask(gomock.Any(), gomock.Eq(taskID), gomock.Eq(false)).
+				DoAndReturn(func(context.Context, string, bool) (models.TaskResult, error) {
+					service.waiters.notify(models.TaskCompletion{TaskID: taskID, Status: models.StatusDone})
+					return models.TaskResult{ID: taskID, Status: models.StatusInProcess}, nil
+				}),
+			mockProvider.EXPECT().GetTask(gomock.Any(), gomock.Eq(taskID), gomock.Eq(false)).
+				Return(models.TaskResult{ID: taskID, Status: models.StatusDone}, nil),
+		)
+
+		taskInfo, completed, err := service.WaitTask(newContextWithRequestID(), taskID, time.Minute)
+		require.NoError(t, err)
+		require.True(t, completed)
+		require.Equal(t, models.StatusDone, taskInfo.Status)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		taskID := uuid.NewString()
+		mockProvider := mock_services.NewMockTaskProvider(ctrl)
+		mockProvider.EXPECT().GetTask(gomock.Any(), gomock.Eq(taskID), gomock.Eq(false)).
+			Return(models.TaskResult{ID: taskID, Status: models.StatusInProcess}, nil).Times(1)
+
+		service := newProxyService(mockProvider, mock_services.NewMockMessageSender(ctrl))
+		_, completed, err := service.WaitTask(newContextWithRequestID(), taskID, 10*time.Millisecond)
+		require.NoError(t, err)
+		require.False(t, completed)
+	})
+
+	t.Run("timeout clamped to max wait timeout", func(t *testing.T) {
+		taskID := uuid.NewString()
+		mockProvider := mock_services.NewMockTaskProvider(ctrl)
+		mockProvider.EXPECT().GetTask(gomock.Any(), gomock.Eq(taskID), gomock.Eq(false)).
+			Return(models.TaskResult{ID: taskID, Status: models.StatusInProcess}, nil).Times(1)
+
+		validator, err := validation.NewValidator()
+		require.NoError(t, err)
+		cfg := config.Config{ProxyServiceConfig: config.ProxyServiceConfig{ProxyMaxWaitTimeout: 10 * time.Millisecond}}
+		service := NewProxyService(slog.New(slog.DiscardHandler), cfg, mockProvider,
+			mock_services.NewMockMessageSender(ctrl), validator)
+
+		start := time.Now()
+		_, completed, err := service.WaitTask(newContextWithRequestID(), taskID, time.Hour)
+		require.NoError(t, err)
+		require.False(t, completed)
+		require.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("default max wait timeout", func(t *testing.T) {
+		service := newProxyService(mock_services.NewMockTaskProvider(ctrl), mock_services.NewMockMessageSender(ctrl))
+		require.Equal(t, defaultMaxWaitTimeout, service.maxWaitTimeout)
+	})
+}
+
+func newProxyService(taskProvider TaskProvider, msgSender MessageSender) *ProxyService {
 	validator, err := validation.NewValidator()
 	if err != nil {
 		panic(err)
 	}
 
-	return ProxyService{
-		log:          slog.New(slog.DiscardHandler),
-		taskProvider: taskProvider,
-		msgSender:    msgSender,
-		validator:    validator,
-	}
+	return NewProxyService(slog.New(slog.DiscardHandler), config.Config{}, taskProvider, msgSender, validator)
 }
 
 func newContextWithRequestID() context.Context {
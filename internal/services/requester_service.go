@@ -5,82 +5,218 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ASsssker/proxy/internal/config"
 	"github.com/ASsssker/proxy/internal/models"
 	prom "github.com/ASsssker/proxy/internal/monitoring/prometheus"
+	"github.com/ASsssker/proxy/internal/storage"
 	"github.com/alitto/pond/v2"
 )
 
 type TaskUpdater interface {
-	UpdateTaskStatus(ctx context.Context, taskID string, newStatus models.TaskStatus) error
-	UpdateTaskResult(ctx context.Context, taskResult models.TaskResult) error
+	AcquireTaskLease(ctx context.Context, lease models.TaskLease) error
+	ExtendTaskLease(ctx context.Context, lease models.TaskLease) error
+	ReleaseTaskLease(ctx context.Context, lease models.TaskLease) error
+	ListExpiredTasks(ctx context.Context, limit int) ([]models.ExpiredTask, error)
+	RequeueExpiredTask(ctx context.Context, task models.Task) error
+	FailExpiredTask(ctx context.Context, taskResult models.TaskResult) error
+	UpdateTaskResult(ctx context.Context, lease models.TaskLease, taskResult models.TaskResult) error
+	UpdateTaskError(ctx context.Context, lease models.TaskLease, taskResult models.TaskResult) error
+	UpdateCallbackStatus(ctx context.Context, taskID string, status models.CallbackStatus, attempts int) error
+	AddTaskAttempt(ctx context.Context, attempt models.TaskAttempt) error
 	Close(ctx context.Context) error
 }
 
 type MessageReceiver interface {
-	Subscribe(ctx context.Context, taskChan chan models.Task) (context.CancelFunc, error)
+	// Subscribe доставляет задачи в taskChan. Каждое сообщение должно быть подтверждено Ack
+	// или возвращено в очередь через Nack.
+	Subscribe(ctx context.Context, taskChan chan models.TaskMessage) (context.CancelFunc, error)
+	SubscribeCancel(ctx context.Context, cancelChan chan string) (context.CancelFunc, error)
+	SendCompletion(ctx context.Context, completion models.TaskCompletion) error
+	// RequeueTask возвращает задачу в очередь так, чтобы она была доставлена не раньше чем через delay.
+	RequeueTask(ctx context.Context, task models.Task, delay time.Duration) error
+	SendDeadLetter(ctx context.Context, deadLetter models.DeadLetter) error
 	Close(ctx context.Context) error
 }
 
 type TaskExecutor interface {
-	Execute(ctx context.Context, task models.Task) (models.TaskResult, error)
+	Execute(ctx context.Context, task models.Task) (models.TaskResult, models.TaskAttempt, error)
+}
+
+type CallbackSender interface {
+	SendCallback(ctx context.Context, task models.Task, taskResult models.TaskResult) (int, error)
 }
 
 type RequesterService struct {
-	log          *slog.Logger
-	taskUpdater  TaskUpdater
-	msgReceiver  MessageReceiver
-	taskExecutor TaskExecutor
-	pool         pond.Pool
-	taskChan     chan models.Task
+	log            *slog.Logger
+	taskUpdater    TaskUpdater
+	msgReceiver    MessageReceiver
+	taskExecutor   TaskExecutor
+	callbackSender CallbackSender
+	retry          retryPlanner
+	workerID       string
+	leaseTTL       time.Duration
+	reaperInterval time.Duration
+	drainTimeout   time.Duration
+	pool           pond.Pool
+	credits        chan struct{}
+	taskChan       chan models.TaskMessage
+	cancelChan     chan string
+	state          atomic.Value
+
+	mu           sync.Mutex
 	cancel       context.CancelFunc
+	runningTasks map[string]context.CancelFunc
+	// tasksCtx отменяется, если выполняемые задачи не успели завершиться за время остановки.
+	tasksCtx  context.Context
+	stopTasks context.CancelFunc
+
+	callbacksCtx    context.Context
+	cancelCallbacks context.CancelFunc
+	callbacks       sync.WaitGroup
+
+	reaper sync.WaitGroup
 }
 
 func NewRequesterService(log *slog.Logger, cfg config.Config, taskUpdater TaskUpdater,
-	msgReceiver MessageReceiver, taskExecutor TaskExecutor) *RequesterService {
-	return &RequesterService{
-		log:          log,
-		taskUpdater:  taskUpdater,
-		msgReceiver:  msgReceiver,
-		taskExecutor: taskExecutor,
-		pool:         pond.NewPool(int(cfg.RequesterWorkersCount), pond.WithNonBlocking(true)),
-		taskChan:     make(chan models.Task),
+	msgReceiver MessageReceiver, taskExecutor TaskExecutor, callbackSender CallbackSender) *RequesterService {
+	callbacksCtx, cancelCallbacks := context.WithCancel(context.Background())
+	tasksCtx, stopTasks := context.WithCancel(context.Background())
+
+	workerID := cfg.RequesterWorkerID
+	if workerID == "" {
+		workerID = defaultWorkerID()
+	}
+
+	leaseTTL := cfg.RequesterLeaseTTL
+	if leaseTTL <= 0 {
+		leaseTTL = defaultLeaseTTL
 	}
+
+	reaperInterval := cfg.RequesterReaperInterval
+	if reaperInterval <= 0 {
+		reaperInterval = defaultReaperInterval
+	}
+
+	r := &RequesterService{
+		log:             log.With(slog.String("worker_id", workerID)),
+		taskUpdater:     taskUpdater,
+		msgReceiver:     msgReceiver,
+		taskExecutor:    taskExecutor,
+		callbackSender:  callbackSender,
+		retry:           newRetryPlanner(cfg),
+		workerID:        workerID,
+		leaseTTL:        leaseTTL,
+		reaperInterval:  reaperInterval,
+		drainTimeout:    cfg.RequesterDrainGracePeriod(),
+		pool:            pond.NewPool(int(cfg.RequesterWorkersCount), pond.WithQueueSize(cfg.RequesterQueueCapacity())),
+		credits:         newCredits(cfg.RequesterPrefetch()),
+		taskChan:        make(chan models.TaskMessage),
+		cancelChan:      make(chan string),
+		runningTasks:    make(map[string]context.CancelFunc),
+		tasksCtx:        tasksCtx,
+		stopTasks:       stopTasks,
+		callbacksCtx:    callbacksCtx,
+		cancelCallbacks: cancelCallbacks,
+	}
+	r.state.Store(RequesterStarting)
+
+	return r
 }
 
-func (r RequesterService) Run(ctx context.Context) error {
-	cancel, err := r.msgReceiver.Subscribe(ctx, r.taskChan)
+func (r *RequesterService) Run(ctx context.Context) error {
+	cancelTasks, err := r.msgReceiver.Subscribe(ctx, r.taskChan)
 	if err != nil {
 		return fmt.Errorf("failed to run requester service: %w", err)
 	}
 
+	cancelSignals, err := r.msgReceiver.SubscribeCancel(ctx, r.cancelChan)
+	if err != nil {
+		cancelTasks()
+		return fmt.Errorf("failed to subscribe cancel signals: %w", err)
+	}
+
+	signalsCtx, stopSignals := context.WithCancel(context.Background())
+	cancel := func() {
+		cancelTasks()
+		cancelSignals()
+		stopSignals()
+	}
+
 	defer cancel()
+
+	r.mu.Lock()
+	if r.State() != RequesterStarting {
+		// Сервис остановили до запуска.
+		r.mu.Unlock()
+		return nil
+	}
 	r.cancel = cancel
-	for task := range r.taskChan {
+	r.state.Store(RequesterRunning)
+	r.mu.Unlock()
+
+	go r.listenCancelSignals(signalsCtx)
+
+	r.reaper.Add(1)
+	go func() {
+		defer r.reaper.Done()
+		r.runReaper(signalsCtx)
+	}()
+
+	for {
+		msg, ok := r.nextTask(signalsCtx)
+		if !ok {
+			break
+		}
+
+		prom.RequesterQueueDepth.Inc()
 		err := r.pool.Go(func() {
-			r.processTask(task)
+			defer r.releaseCredit()
+
+			prom.RequesterQueueDepth.Dec()
+			if r.draining() {
+				// Задача не начата до остановки, её выполнит другой requester.
+				r.nack(msg)
+				return
+			}
+			r.processTask(msg)
 		})
 
 		if err != nil {
-			r.log.Error("failed to run task", slog.String("task_id", task.ID),
+			r.log.Error("failed to run task", slog.String("task_id", msg.Task.ID),
 				slog.String("error", err.Error()))
 
+			prom.RequesterQueueDepth.Dec()
 			prom.RequesterTasksRejected.Inc()
+			r.releaseCredit()
+			r.nack(msg)
 			continue
 		}
 
 		prom.RequesterTasksStarted.Inc()
 	}
 
-	r.pool.WaitingTasks()
-
 	return nil
 }
 
-func (r RequesterService) Close(ctx context.Context) error {
-	defer r.pool.StopAndWait()
-	r.cancel()
+func (r *RequesterService) Close(ctx context.Context) error {
+	r.mu.Lock()
+	r.state.Store(RequesterDraining)
+	cancel := r.cancel
+	r.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	r.drain(ctx)
+	r.state.Store(RequesterStopped)
+	r.reaper.Wait()
+
+	r.cancelCallbacks()
+	r.callbacks.Wait()
 
 	errCloseTaskUpdater := r.taskUpdater.Close(ctx)
 	if errCloseTaskUpdater != nil {
@@ -100,39 +236,306 @@ func (r RequesterService) Close(ctx context.Context) error {
 	return nil
 }
 
-func (r RequesterService) processTask(task models.Task) {
+// nextTask не забирает задачу из брокера, пока для неё нет места в пуле или его очереди: задачи ждут
+// в брокере, а не отклоняются.
+func (r *RequesterService) nextTask(ctx context.Context) (models.TaskMessage, bool) {
+	if r.credits != nil {
+		select {
+		case r.credits <- struct{}{}:
+		case <-ctx.Done():
+			return models.TaskMessage{}, false
+		}
+	}
+
+	select {
+	case msg, ok := <-r.taskChan:
+		if ok {
+			return msg, true
+		}
+	case <-ctx.Done():
+	}
+
+	r.releaseCredit()
+
+	return models.TaskMessage{}, false
+}
+
+func (r *RequesterService) releaseCredit() {
+	if r.credits != nil {
+		<-r.credits
+	}
+}
+
+func newCredits(capacity int) chan struct{} {
+	if capacity <= 0 {
+		return nil
+	}
+
+	return make(chan struct{}, capacity)
+}
+
+func (r *RequesterService) listenCancelSignals(ctx context.Context) {
+	for {
+		select {
+		case taskID := <-r.cancelChan:
+			r.mu.Lock()
+			cancel, ok := r.runningTasks[taskID]
+			r.mu.Unlock()
+
+			if ok {
+				r.log.Info("cancelling running task", slog.String("task_id", taskID))
+				cancel()
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *RequesterService) trackTask(taskID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(r.tasksCtx)
+
+	r.mu.Lock()
+	r.runningTasks[taskID] = cancel
+	r.mu.Unlock()
+
+	return ctx, func() {
+		r.mu.Lock()
+		delete(r.runningTasks, taskID)
+		r.mu.Unlock()
+
+		cancel()
+	}
+}
+
+// processTask выполняет задачу из сообщения. Сообщение подтверждается только после записи
+// окончательного результата или передачи следующей попытки в брокер. Если состояние задачи
+// не удалось сохранить, сообщение возвращается в очередь и будет обработано повторно.
+func (r *RequesterService) processTask(msg models.TaskMessage) {
 	ctx := context.TODO()
+	task := msg.Task
+
+	// Задача регистрируется до смены статуса, чтобы не пропустить сигнал отмены.
+	taskCtx, untrack := r.trackTask(task.ID)
+	defer untrack()
+
+	lease := models.TaskLease{TaskID: task.ID, WorkerID: r.workerID, Attempt: task.AttemptNumber(), TTL: r.leaseTTL}
+	if err := r.taskUpdater.AcquireTaskLease(ctx, lease); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrTaskFinalized):
+			r.log.Info("task skipped because it is already finalized", slog.String("task_id", task.ID))
+			r.ack(msg)
+			return
+		case errors.Is(err, storage.ErrTaskLeased):
+			// Задачу выполняет другой requester, если он упадёт, задачу подберёт reaper.
+			r.log.Info("task skipped because it is leased by another worker", slog.String("task_id", task.ID))
+			r.ack(msg)
+			return
+		}
 
-	if err := r.taskUpdater.UpdateTaskStatus(ctx, task.ID, models.StatusInProcess); err != nil {
-		r.log.Error("failed to update task status", slog.String("task_id", task.ID),
-			slog.String("status", string(models.StatusInProcess)),
+		r.log.Error("failed to acquire task lease", slog.String("task_id", task.ID),
 			slog.String("error", err.Error()),
 		)
+		r.nack(msg)
 
 		return
 	}
 
-	taskResult, err := r.taskExecutor.Execute(context.TODO(), task)
+	execCtx, releaseExec := r.keepLease(taskCtx, lease)
+	taskResult, attempt, err := r.taskExecutor.Execute(execCtx, task)
+	interrupted := execCtx.Err() != nil
+	releaseExec()
+	r.recordAttempt(ctx, attempt)
+
 	if err != nil {
-		r.log.Error("failed to execute task", slog.String("task_id", task.ID),
-			slog.String("error", err.Error()))
+		if interrupted && r.tasksCtx.Err() != nil {
+			r.returnTask(ctx, msg, lease)
+			return
+		}
+
+		if interrupted {
+			// Статус уже записан тем, кто отменил задачу или забрал её аренду.
+			r.log.Info("task execution was interrupted", slog.String("task_id", task.ID))
+			r.ack(msg)
+			return
+		}
 
-		if err := r.taskUpdater.UpdateTaskStatus(ctx, task.ID, models.StatusError); err != nil {
-			r.log.Error("failed to update task status", slog.String("task_id", task.ID),
-				slog.String("status", string(models.StatusError)),
+		errCode := attempt.ErrorCode
+		if delay, ok := r.retry.afterError(task, errCode); ok {
+			r.log.Warn("task attempt failed, retry scheduled", slog.String("task_id", task.ID),
+				slog.Int("attempt", task.AttemptNumber()), slog.String("error_code", string(errCode)),
+				slog.Duration("delay", delay), slog.String("error", err.Error()))
+
+			if r.requeueTask(ctx, task, delay) {
+				r.ack(msg)
+				return
+			}
+		}
+
+		status := models.StatusError
+		if errCode == models.ErrorBlockedDestination {
+			status = models.StatusBlocked
+			r.log.Warn("task destination blocked by network policy", slog.String("task_id", task.ID),
+				slog.String("error", err.Error()))
+		} else {
+			r.log.Error("failed to execute task", slog.String("task_id", task.ID),
+				slog.String("error_code", string(errCode)), slog.String("error", err.Error()))
+		}
+
+		failedResult := models.TaskResult{
+			ID:           task.ID,
+			Status:       status,
+			ErrorCode:    errCode,
+			ErrorMessage: attempt.ErrorMessage,
+		}
+		if err := r.taskUpdater.UpdateTaskError(ctx, lease, failedResult); err != nil {
+			if errors.Is(err, storage.ErrTaskFinalized) || errors.Is(err, storage.ErrTaskLeased) {
+				r.log.Info("task error discarded because task is finalized or leased by another worker",
+					slog.String("task_id", task.ID))
+				r.ack(msg)
+				return
+			}
+
+			r.log.Error("failed to update task error", slog.String("task_id", task.ID),
+				slog.String("status", string(status)),
 				slog.String("error", err.Error()),
 			)
+			r.nack(msg)
 
 			return
 		}
 
+		r.ack(msg)
+		if r.retry.exhaustedError(task, errCode) {
+			r.sendDeadLetter(ctx, task, models.DeadLetterRetriesExhausted, attempt.ErrorMessage)
+		}
+		r.completeTask(ctx, task, failedResult)
+
 		return
 	}
 
-	if err := r.taskUpdater.UpdateTaskResult(ctx, taskResult); err != nil {
+	if delay, ok := r.retry.afterResult(task, taskResult); ok {
+		r.log.Info("task got retryable response, retry scheduled", slog.String("task_id", task.ID),
+			slog.Int("attempt", task.AttemptNumber()), slog.Int("status_code", taskResult.StatusCode),
+			slog.Duration("delay", delay))
+
+		if r.requeueTask(ctx, task, delay) {
+			r.ack(msg)
+			return
+		}
+	}
+
+	if err := r.taskUpdater.UpdateTaskResult(ctx, lease, taskResult); err != nil {
+		if errors.Is(err, storage.ErrTaskFinalized) || errors.Is(err, storage.ErrTaskLeased) {
+			r.log.Info("task result discarded because task is finalized or leased by another worker",
+				slog.String("task_id", task.ID))
+			r.ack(msg)
+			return
+		}
+
 		r.log.Error("failed to update task result", slog.String("task_id", task.ID),
 			slog.String("error", err.Error()))
+		r.nack(msg)
+
+		return
+	}
+
+	r.ack(msg)
+	if r.retry.exhaustedResult(task, taskResult) {
+		r.sendDeadLetter(ctx, task, models.DeadLetterRetriesExhausted,
+			fmt.Sprintf("upstream responded with status code %d", taskResult.StatusCode))
+	}
+	r.completeTask(ctx, task, taskResult)
+}
+
+func (r *RequesterService) ack(msg models.TaskMessage) {
+	if err := msg.Ack(); err != nil {
+		r.log.Error("failed to ack task message", slog.String("task_id", msg.Task.ID),
+			slog.String("error", err.Error()))
+	}
+}
+
+// nack возвращает сообщение в очередь, чтобы задачу обработал этот или другой экземпляр requester.
+func (r *RequesterService) nack(msg models.TaskMessage) {
+	if err := msg.Nack(true); err != nil {
+		r.log.Error("failed to nack task message", slog.String("task_id", msg.Task.ID),
+			slog.String("error", err.Error()))
+	}
+}
 
+// recordAttempt сохраняет попытку в историю. История носит справочный характер,
+// поэтому ошибка записи не влияет на обработку задачи.
+func (r *RequesterService) recordAttempt(ctx context.Context, attempt models.TaskAttempt) {
+	if err := r.taskUpdater.AddTaskAttempt(ctx, attempt); err != nil {
+		r.log.Error("failed to add task attempt", slog.String("task_id", attempt.TaskID),
+			slog.Int("attempt", attempt.Attempt), slog.String("error", err.Error()))
+	}
+}
+
+// requeueTask возвращает false, если результат текущей попытки нужно записать как окончательный.
+func (r *RequesterService) requeueTask(ctx context.Context, task models.Task, delay time.Duration) bool {
+	task.Attempt = task.AttemptNumber() + 1
+
+	// Аренда освобождается до отправки, чтобы следующую попытку мог взять любой requester.
+	// Если попытка не начнётся за delay и срок аренды, задачу подберёт reaper.
+	lease := models.TaskLease{TaskID: task.ID, WorkerID: r.workerID, Attempt: task.Attempt, TTL: delay + r.leaseTTL}
+	if err := r.taskUpdater.ReleaseTaskLease(ctx, lease); err != nil {
+		if errors.Is(err, storage.ErrTaskLeased) {
+			r.log.Info("task retry skipped because task lease is lost", slog.String("task_id", task.ID))
+			return true
+		}
+
+		r.log.Error("failed to release task lease", slog.String("task_id", task.ID),
+			slog.String("error", err.Error()))
+
+		return false
+	}
+
+	if err := r.msgReceiver.RequeueTask(ctx, task, delay); err != nil {
+		r.log.Error("failed to requeue task", slog.String("task_id", task.ID),
+			slog.String("error", err.Error()))
+
+		return false
+	}
+
+	prom.RequesterTasksRetried.Inc()
+
+	return true
+}
+
+func (r *RequesterService) completeTask(ctx context.Context, task models.Task, taskResult models.TaskResult) {
+	completion := models.TaskCompletion{TaskID: task.ID, Status: taskResult.Status}
+	if err := r.msgReceiver.SendCompletion(ctx, completion); err != nil {
+		r.log.Error("failed to send task completion", slog.String("task_id", task.ID),
+			slog.String("error", err.Error()))
+	}
+
+	r.notifyCallback(task, taskResult)
+}
+
+func (r *RequesterService) notifyCallback(task models.Task, taskResult models.TaskResult) {
+	if task.CallbackURL == "" {
 		return
 	}
+
+	// Доставка с повторами выполняется вне пула, чтобы не занимать воркер.
+	r.callbacks.Add(1)
+	go func() {
+		defer r.callbacks.Done()
+
+		status := models.CallbackDelivered
+		attempts, err := r.callbackSender.SendCallback(r.callbacksCtx, task, taskResult)
+		if err != nil {
+			r.log.Error("failed to deliver callback", slog.String("task_id", task.ID),
+				slog.Int("attempts", attempts), slog.String("error", err.Error()))
+
+			status = models.CallbackFailed
+		}
+
+		if err := r.taskUpdater.UpdateCallbackStatus(context.Background(), task.ID, status, attempts); err != nil {
+			r.log.Error("failed to update callback status", slog.String("task_id", task.ID),
+				slog.String("status", string(status)), slog.String("error", err.Error()))
+		}
+	}()
 }
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/models"
+	mock_services "github.com/ASsssker/proxy/internal/services/mocks"
+	"github.com/ASsssker/proxy/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestProcessTask_Ack(t *testing.T) {
+	errDB := errors.New("connection refused")
+
+	tests := []struct {
+		name  string
+		setup func(updater *mock_services.MockTaskUpdater, receiver *mock_services.MockMessageReceiver,
+			executor *mock_services.MockTaskExecutor)
+		attempt  int
+		acked    bool
+		requeued bool
+	}{
+		{
+			name: "task done",
+			setup: func(updater *mock_services.MockTaskUpdater, receiver *mock_services.MockMessageReceiver,
+				executor *mock_services.MockTaskExecutor) {
+				updater.EXPECT().AcquireTaskLease(gomock.Any(), gomock.Any()).Return(nil)
+				executor.EXPECT().Execute(gomock.Any(), gomock.Any()).
+					Return(models.TaskResult{Status: models.StatusDone, StatusCode: http.StatusOK},
+						models.TaskAttempt{StatusCode: http.StatusOK}, nil)
+				updater.EXPECT().UpdateTaskResult(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				receiver.EXPECT().SendCompletion(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			acked: true,
+		},
+		{
+			name: "task already finalized",
+			setup: func(updater *mock_services.MockTaskUpdater, receiver *mock_services.MockMessageReceiver,
+				executor *mock_services.MockTaskExecutor) {
+				updater.EXPECT().AcquireTaskLease(gomock.Any(), gomock.Any()).
+					Return(storage.ErrTaskFinalized)
+			},
+			acked: true,
+		},
+		{
+			name: "task leased by another worker",
+			setup: func(updater *mock_services.MockTaskUpdater, receiver *mock_services.MockMessageReceiver,
+				executor *mock_services.MockTaskExecutor) {
+				updater.EXPECT().AcquireTaskLease(gomock.Any(), gomock.Any()).Return(storage.ErrTaskLeased)
+			},
+			acked: true,
+		},
+		{
+			name: "status update failed",
+			setup: func(updater *mock_services.MockTaskUpdater, receiver *mock_services.MockMessageReceiver,
+				executor *mock_services.MockTaskExecutor) {
+				updater.EXPECT().AcquireTaskLease(gomock.Any(), gomock.Any()).Return(errDB)
+			},
+			requeued: true,
+		},
+		{
+			name: "result update failed",
+			setup: func(updater *mock_services.MockTaskUpdater, receiver *mock_services.MockMessageReceiver,
+				executor *mock_services.MockTaskExecutor) {
+				updater.EXPECT().AcquireTaskLease(gomock.Any(), gomock.Any()).Return(nil)
+				executor.EXPECT().Execute(gomock.Any(), gomock.Any()).
+					Return(models.TaskResult{Status: models.StatusDone, StatusCode: http.StatusOK},
+						models.TaskAttempt{StatusCode: http.StatusOK}, nil)
+				updater.EXPECT().UpdateTaskResult(gomock.Any(), gomock.Any(), gomock.Any()).Return(errDB)
+			},
+			requeued: true,
+		},
+		{
+			name: "lease lost before result update",
+			setup: func(updater *mock_services.MockTaskUpdater, receiver *mock_services.MockMessageReceiver,
+				executor *mock_services.MockTaskExecutor) {
+				updater.EXPECT().AcquireTaskLease(gomock.Any(), gomock.Any()).Return(nil)
+				executor.EXPECT().Execute(gomock.Any(), gomock.Any()).
+					Return(models.TaskResult{Status: models.StatusDone, StatusCode: http.StatusOK},
+						models.TaskAttempt{StatusCode: http.StatusOK}, nil)
+				// Задачу уже подобрал reaper и отдал другому requester, его результат не перезаписывается.
+				updater.EXPECT().UpdateTaskResult(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(storage.ErrTaskLeased)
+			},
+			acked: true,
+		},
+		{
+			name: "task failed",
+			setup: func(updater *mock_services.MockTaskUpdater, receiver *mock_services.MockMessageReceiver,
+				executor *mock_services.MockTaskExecutor) {
+				updater.EXPECT().AcquireTaskLease(gomock.Any(), gomock.Any()).Return(nil)
+				executor.EXPECT().Execute(gomock.Any(), gomock.Any()).
+					Return(models.TaskResult{}, models.TaskAttempt{ErrorCode: models.ErrorDNSFailure},
+						errors.New("no such host"))
+				updater.EXPECT().UpdateTaskError(gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_, _ any, taskResult models.TaskResult) error {
+						require.Equal(t, models.StatusError, taskResult.Status)
+						require.Equal(t, models.ErrorDNSFailure, taskResult.ErrorCode)
+						return nil
+					})
+				receiver.EXPECT().SendCompletion(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			acked: true,
+		},
+		{
+			name: "retry scheduled",
+			setup: func(updater *mock_services.MockTaskUpdater, receiver *mock_services.MockMessageReceiver,
+				executor *mock_services.MockTaskExecutor) {
+				updater.EXPECT().AcquireTaskLease(gomock.Any(), gomock.Any()).Return(nil)
+				executor.EXPECT().Execute(gomock.Any(), gomock.Any()).
+					Return(models.TaskResult{Status: models.StatusDone, StatusCode: http.StatusServiceUnavailable},
+						models.TaskAttempt{StatusCode: http.StatusServiceUnavailable}, nil)
+				updater.EXPECT().ReleaseTaskLease(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ any, lease models.TaskLease) error {
+						require.Equal(t, 2, lease.Attempt)
+						return nil
+					})
+				receiver.EXPECT().RequeueTask(gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ any, task models.Task, _ any) error {
+						require.Equal(t, 2, task.Attempt)
+						return nil
+					})
+			},
+			acked: true,
+		},
+		{
+			name: "retry skipped after lease lost",
+			setup: func(updater *mock_services.MockTaskUpdater, receiver *mock_services.MockMessageReceiver,
+				executor *mock_services.MockTaskExecutor) {
+				updater.EXPECT().AcquireTaskLease(gomock.Any(), gomock.Any()).Return(nil)
+				executor.EXPECT().Execute(gomock.Any(), gomock.Any()).
+					Return(models.TaskResult{Status: models.StatusDone, StatusCode: http.StatusServiceUnavailable},
+						models.TaskAttempt{StatusCode: http.StatusServiceUnavailable}, nil)
+				updater.EXPECT().ReleaseTaskLease(gomock.Any(), gomock.Any()).Return(storage.ErrTaskLeased)
+			},
+			acked: true,
+		},
+		{
+			name: "retries exhausted",
+			setup: func(updater *mock_services.MockTaskUpdater, receiver *mock_services.MockMessageReceiver,
+				executor *mock_services.MockTaskExecutor) {
+				updater.EXPECT().AcquireTaskLease(gomock.Any(), gomock.Any()).Return(nil)
+				executor.EXPECT().Execute(gomock.Any(), gomock.Any()).
+					Return(models.TaskResult{}, models.TaskAttempt{ErrorCode: models.ErrorConnection,
+						ErrorMessage: "connection reset by peer"}, errors.New("connection reset by peer"))
+				updater.EXPECT().UpdateTaskError(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				receiver.EXPECT().SendDeadLetter(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ any, deadLetter models.DeadLetter) error {
+						require.Equal(t, models.DeadLetterRetriesExhausted, deadLetter.Reason)
+						require.Equal(t, "connection reset by peer", deadLetter.ErrorMessage)
+						require.NotEmpty(t, deadLetter.Payload)
+						return nil
+					})
+				receiver.EXPECT().SendCompletion(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			attempt: 3,
+			acked:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			updater := mock_services.NewMockTaskUpdater(ctrl)
+			receiver := mock_services.NewMockMessageReceiver(ctrl)
+			executor := mock_services.NewMockTaskExecutor(ctrl)
+			updater.EXPECT().AddTaskAttempt(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			tt.setup(updater, receiver, executor)
+
+			service := NewRequesterService(slog.New(slog.DiscardHandler), config.Config{
+				RequesterServiceConfig: config.RequesterServiceConfig{RequesterWorkersCount: 1, RequesterRetryCount: 3},
+			}, updater, receiver, executor, mock_services.NewMockCallbackSender(ctrl))
+
+			var acked, requeued bool
+			msg := models.NewTaskMessage(
+				models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: http.MethodGet,
+					Attempt: max(tt.attempt, 1)},
+				func() error {
+					acked = true
+					return nil
+				},
+				func(requeue bool) error {
+					requeued = requeue
+					return nil
+				},
+			)
+
+			service.processTask(msg)
+			require.Equal(t, tt.acked, acked)
+			require.Equal(t, tt.requeued, requeued)
+		})
+	}
+}
+
+func TestRequesterService_Backpressure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	updater := mock_services.NewMockTaskUpdater(ctrl)
+	receiver := mock_services.NewMockMessageReceiver(ctrl)
+	executor := mock_services.NewMockTaskExecutor(ctrl)
+
+	subscribed := make(chan chan models.TaskMessage, 1)
+	receiver.EXPECT().Subscribe(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, ch chan models.TaskMessage) (context.CancelFunc, error) {
+			subscribed <- ch
+			return func() {}, nil
+		})
+	receiver.EXPECT().SubscribeCancel(gomock.Any(), gomock.Any()).Return(func() {}, nil)
+	receiver.EXPECT().SendCompletion(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	receiver.EXPECT().Close(gomock.Any()).Return(nil)
+
+	updater.EXPECT().AcquireTaskLease(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	updater.EXPECT().UpdateTaskResult(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	updater.EXPECT().AddTaskAttempt(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	updater.EXPECT().Close(gomock.Any()).Return(nil)
+
+	release := make(chan struct{})
+	executor.EXPECT().Execute(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, task models.Task) (models.TaskResult, models.TaskAttempt, error) {
+			<-release
+			return models.TaskResult{ID: task.ID, Status: models.StatusDone, StatusCode: http.StatusOK},
+				models.TaskAttempt{TaskID: task.ID}, nil
+		}).Times(4)
+
+	service := NewRequesterService(slog.New(slog.DiscardHandler), config.Config{
+		RequesterServiceConfig: config.RequesterServiceConfig{RequesterWorkersCount: 1, RequesterQueueSize: 2},
+	}, updater, receiver, executor, mock_services.NewMockCallbackSender(ctrl))
+
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		require.NoError(t, service.Run(context.Background()))
+	}()
+	taskChan := <-subscribed
+
+	var acked atomic.Int32
+	send := func() bool {
+		msg := models.NewTaskMessage(models.Task{ID: uuid.NewString(), Attempt: 1},
+			func() error {
+				acked.Add(1)
+				return nil
+			}, nil)
+
+		select {
+		case taskChan <- msg:
+			return true
+		case <-time.After(200 * time.Millisecond):
+			return false
+		}
+	}
+
+	// Один воркер и очередь на две задачи: четвёртая задача остаётся в брокере.
+	for range 3 {
+		require.True(t, send())
+	}
+	require.False(t, send())
+
+	release <- struct{}{}
+	require.True(t, send())
+
+	close(release)
+	require.Eventually(t, func() bool { return acked.Load() == 4 }, time.Second, 10*time.Millisecond)
+
+	close(taskChan)
+	<-runDone
+	require.NoError(t, service.Close(context.Background()))
+}
+
+func TestRequesterService_Drain(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	updater := mock_services.NewMockTaskUpdater(ctrl)
+	receiver := mock_services.NewMockMessageReceiver(ctrl)
+	executor := mock_services.NewMockTaskExecutor(ctrl)
+
+	subscribed := make(chan chan models.TaskMessage, 1)
+	receiver.EXPECT().Subscribe(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, ch chan models.TaskMessage) (context.CancelFunc, error) {
+			subscribed <- ch
+			return func() {}, nil
+		})
+	receiver.EXPECT().SubscribeCancel(gomock.Any(), gomock.Any()).Return(func() {}, nil)
+	receiver.EXPECT().Close(gomock.Any()).Return(nil)
+
+	updater.EXPECT().AcquireTaskLease(gomock.Any(), gomock.Any()).Return(nil)
+	updater.EXPECT().ExtendTaskLease(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	updater.EXPECT().AddTaskAttempt(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	updater.EXPECT().ReleaseTaskLease(gomock.Any(), gomock.Any()).Return(nil)
+	updater.EXPECT().Close(gomock.Any()).Return(nil)
+
+	started := make(chan struct{})
+	executor.EXPECT().Execute(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, task models.Task) (models.TaskResult, models.TaskAttempt, error) {
+			close(started)
+			<-ctx.Done()
+			return models.TaskResult{}, models.TaskAttempt{TaskID: task.ID}, ctx.Err()
+		})
+
+	service := NewRequesterService(slog.New(slog.DiscardHandler), config.Config{
+		RequesterServiceConfig: config.RequesterServiceConfig{
+			RequesterWorkersCount: 1, RequesterQueueSize: 1, RequesterDrainTimeout: 50 * time.Millisecond,
+		},
+	}, updater, receiver, executor, mock_services.NewMockCallbackSender(ctrl))
+	require.Equal(t, RequesterStarting, service.State())
+
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		require.NoError(t, service.Run(context.Background()))
+	}()
+	taskChan := <-subscribed
+	require.Eventually(t, func() bool { return service.State() == RequesterRunning }, time.Second, time.Millisecond)
+
+	var acked, nacked atomic.Int32
+	newMsg := func() models.TaskMessage {
+		return models.NewTaskMessage(models.Task{ID: uuid.NewString(), Attempt: 1},
+			func() error {
+				acked.Add(1)
+				return nil
+			},
+			func(bool) error {
+				nacked.Add(1)
+				return nil
+			})
+	}
+
+	// Первая задача выполняется до отмены по таймауту остановки, вторая ждёт воркера в очереди пула.
+	taskChan <- newMsg()
+	<-started
+	taskChan <- newMsg()
+
+	require.NoError(t, service.Close(context.Background()))
+	<-runDone
+
+	require.Equal(t, RequesterStopped, service.State())
+	require.Equal(t, int32(2), nacked.Load())
+	require.Equal(t, int32(0), acked.Load())
+}
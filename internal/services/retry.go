@@ -0,0 +1,164 @@
+package services
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/models"
+)
+
+const (
+	defaultRetryBaseDelay = time.Second
+	defaultRetryMaxDelay  = time.Minute
+)
+
+var (
+	defaultRetryStatusCodes = []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+	defaultRetryErrors = []models.ErrorCode{
+		models.ErrorConnectTimeout,
+		models.ErrorReadTimeout,
+		models.ErrorConnection,
+	}
+)
+
+// retryPlanner решает, нужно ли повторить попытку, и вычисляет задержку до неё:
+// экспоненциальный рост от baseDelay с jitter, но не больше maxDelay.
+type retryPlanner struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	now         func() time.Time
+	jitter      func(n int64) int64
+}
+
+func newRetryPlanner(cfg config.Config) retryPlanner {
+	baseDelay := cfg.RequesterRetryBaseDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultRetryBaseDelay
+	}
+
+	maxDelay := cfg.RequesterRetryMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+
+	return retryPlanner{
+		maxAttempts: int(max(cfg.RequesterRetryCount, 1)),
+		baseDelay:   baseDelay,
+		maxDelay:    max(maxDelay, baseDelay),
+		now:         time.Now,
+		jitter:      rand.Int64N,
+	}
+}
+
+// afterError возвращает задержку перед повтором задачи, попытка которой завершилась ошибкой.
+func (p retryPlanner) afterError(task models.Task, errCode models.ErrorCode) (time.Duration, bool) {
+	retryErrors := defaultRetryErrors
+	if task.Retry != nil && task.Retry.RetryErrors != nil {
+		retryErrors = task.Retry.RetryErrors
+	}
+
+	if !p.hasAttempts(task) || !slices.Contains(retryErrors, errCode) {
+		return 0, false
+	}
+
+	return p.backoff(task.AttemptNumber()), true
+}
+
+// exhaustedError сообщает, что ошибку можно было бы повторить, но попытки задачи закончились.
+func (p retryPlanner) exhaustedError(task models.Task, errCode models.ErrorCode) bool {
+	retryErrors := defaultRetryErrors
+	if task.Retry != nil && task.Retry.RetryErrors != nil {
+		retryErrors = task.Retry.RetryErrors
+	}
+
+	return !p.hasAttempts(task) && slices.Contains(retryErrors, errCode)
+}
+
+// exhaustedResult сообщает, что ответ upstream можно было бы повторить, но попытки задачи закончились.
+func (p retryPlanner) exhaustedResult(task models.Task, taskResult models.TaskResult) bool {
+	retryStatusCodes := defaultRetryStatusCodes
+	if task.Retry != nil && task.Retry.RetryStatusCodes != nil {
+		retryStatusCodes = task.Retry.RetryStatusCodes
+	}
+
+	return !p.hasAttempts(task) && slices.Contains(retryStatusCodes, taskResult.StatusCode)
+}
+
+// afterResult возвращает задержку перед повтором задачи, upstream которой ответил повторяемым кодом.
+// Retry-After соблюдается: если сервер просит ждать дольше maxDelay, повтора не будет.
+func (p retryPlanner) afterResult(task models.Task, taskResult models.TaskResult) (time.Duration, bool) {
+	retryStatusCodes := defaultRetryStatusCodes
+	if task.Retry != nil && task.Retry.RetryStatusCodes != nil {
+		retryStatusCodes = task.Retry.RetryStatusCodes
+	}
+
+	if !p.hasAttempts(task) || !slices.Contains(retryStatusCodes, taskResult.StatusCode) {
+		return 0, false
+	}
+
+	delay := p.backoff(task.AttemptNumber())
+
+	retryAfter, ok := p.parseRetryAfter(taskResult.Headers)
+	if !ok {
+		return delay, true
+	}
+
+	if retryAfter > p.maxDelay {
+		return 0, false
+	}
+
+	return max(delay, retryAfter), true
+}
+
+func (p retryPlanner) hasAttempts(task models.Task) bool {
+	maxAttempts := p.maxAttempts
+	if task.Retry != nil && task.Retry.MaxAttempts > 0 {
+		maxAttempts = task.Retry.MaxAttempts
+	}
+
+	return task.AttemptNumber() < maxAttempts
+}
+
+// backoff возвращает задержку после попытки attempt: половина экспоненциального шага фиксирована,
+// вторая половина случайна, чтобы повторы разных задач не приходили к upstream одновременно.
+func (p retryPlanner) backoff(attempt int) time.Duration {
+	delay := p.baseDelay
+	for i := 1; i < attempt && delay < p.maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.maxDelay)
+
+	half := delay / 2
+
+	return half + time.Duration(p.jitter(int64(delay-half)+1))
+}
+
+func (p retryPlanner) parseRetryAfter(headers models.Headers) (time.Duration, bool) {
+	values := headers["Retry-After"]
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	value := strings.TrimSpace(values[0])
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	retryAt, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(retryAt.Sub(p.now()), 0), true
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPlanner_AfterError(t *testing.T) {
+	tests := []struct {
+		name          string
+		task          models.Task
+		errCode       models.ErrorCode
+		expectedDelay time.Duration
+		expectedRetry bool
+	}{
+		{
+			name:          "default retryable error",
+			task:          models.Task{Attempt: 1},
+			errCode:       models.ErrorConnection,
+			expectedDelay: time.Second,
+			expectedRetry: true,
+		},
+		{
+			name:          "exponential delay",
+			task:          models.Task{Attempt: 2},
+			errCode:       models.ErrorReadTimeout,
+			expectedDelay: 2 * time.Second,
+			expectedRetry: true,
+		},
+		{
+			name:    "attempts exhausted",
+			task:    models.Task{Attempt: 3},
+			errCode: models.ErrorConnection,
+		},
+		{
+			name:    "not retryable by default",
+			task:    models.Task{Attempt: 1},
+			errCode: models.ErrorDNSFailure,
+		},
+		{
+			name:    "never retry blocked destination",
+			task:    models.Task{Attempt: 1},
+			errCode: models.ErrorBlockedDestination,
+		},
+		{
+			name: "task policy error list",
+			task: models.Task{Attempt: 1, Retry: &models.RetryPolicy{
+				RetryErrors: []models.ErrorCode{models.ErrorDNSFailure},
+			}},
+			errCode:       models.ErrorDNSFailure,
+			expectedDelay: time.Second,
+			expectedRetry: true,
+		},
+		{
+			name: "task policy disables error retries",
+			task: models.Task{Attempt: 1, Retry: &models.RetryPolicy{
+				RetryErrors: []models.ErrorCode{},
+			}},
+			errCode: models.ErrorConnection,
+		},
+		{
+			name:          "task policy max attempts",
+			task:          models.Task{Attempt: 5, Retry: &models.RetryPolicy{MaxAttempts: 10}},
+			errCode:       models.ErrorConnection,
+			expectedDelay: 16 * time.Second,
+			expectedRetry: true,
+		},
+		{
+			name:          "delay capped by max delay",
+			task:          models.Task{Attempt: 9, Retry: &models.RetryPolicy{MaxAttempts: 20}},
+			errCode:       models.ErrorConnection,
+			expectedDelay: time.Minute,
+			expectedRetry: true,
+		},
+	}
+
+	planner := newTestRetryPlanner()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, ok := planner.afterError(tt.task, tt.errCode)
+			require.Equal(t, tt.expectedRetry, ok)
+			require.Equal(t, tt.expectedDelay, delay)
+		})
+	}
+}
+
+func TestRetryPlanner_AfterResult(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		task          models.Task
+		statusCode    int
+		retryAfter    string
+		expectedDelay time.Duration
+		expectedRetry bool
+	}{
+		{
+			name:       "success status",
+			task:       models.Task{Attempt: 1},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:          "service unavailable",
+			task:          models.Task{Attempt: 1},
+			statusCode:    http.StatusServiceUnavailable,
+			expectedDelay: time.Second,
+			expectedRetry: true,
+		},
+		{
+			name:          "retry after seconds",
+			task:          models.Task{Attempt: 1},
+			statusCode:    http.StatusTooManyRequests,
+			retryAfter:    "30",
+			expectedDelay: 30 * time.Second,
+			expectedRetry: true,
+		},
+		{
+			name:          "retry after date",
+			task:          models.Task{Attempt: 1},
+			statusCode:    http.StatusTooManyRequests,
+			retryAfter:    now.Add(10 * time.Second).Format(http.TimeFormat),
+			expectedDelay: 10 * time.Second,
+			expectedRetry: true,
+		},
+		{
+			name:          "retry after shorter than backoff",
+			task:          models.Task{Attempt: 3, Retry: &models.RetryPolicy{MaxAttempts: 5}},
+			statusCode:    http.StatusTooManyRequests,
+			retryAfter:    "1",
+			expectedDelay: 4 * time.Second,
+			expectedRetry: true,
+		},
+		{
+			name:       "retry after longer than max delay",
+			task:       models.Task{Attempt: 1},
+			statusCode: http.StatusTooManyRequests,
+			retryAfter: "3600",
+		},
+		{
+			name: "task policy status codes",
+			task: models.Task{Attempt: 1, Retry: &models.RetryPolicy{
+				RetryStatusCodes: []int{http.StatusInternalServerError},
+			}},
+			statusCode:    http.StatusInternalServerError,
+			expectedDelay: time.Second,
+			expectedRetry: true,
+		},
+		{
+			name: "status outside of task policy",
+			task: models.Task{Attempt: 1, Retry: &models.RetryPolicy{
+				RetryStatusCodes: []int{http.StatusInternalServerError},
+			}},
+			statusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	planner := newTestRetryPlanner()
+	planner.now = func() time.Time { return now }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskResult := models.TaskResult{StatusCode: tt.statusCode, Headers: models.Headers{}}
+			if tt.retryAfter != "" {
+				taskResult.Headers["Retry-After"] = []string{tt.retryAfter}
+			}
+
+			delay, ok := planner.afterResult(tt.task, taskResult)
+			require.Equal(t, tt.expectedRetry, ok)
+			require.Equal(t, tt.expectedDelay, delay)
+		})
+	}
+}
+
+func TestRetryPlanner_Jitter(t *testing.T) {
+	planner := newRetryPlanner(config.Config{RequesterServiceConfig: config.RequesterServiceConfig{
+		RequesterRetryCount:     10,
+		RequesterRetryBaseDelay: time.Second,
+	}})
+
+	for range 100 {
+		delay := planner.backoff(3)
+		require.GreaterOrEqual(t, delay, 2*time.Second)
+		require.LessOrEqual(t, delay, 4*time.Second)
+	}
+}
+
+// newTestRetryPlanner возвращает планировщик без случайной составляющей: задержка равна полному шагу.
+func newTestRetryPlanner() retryPlanner {
+	planner := newRetryPlanner(config.Config{RequesterServiceConfig: config.RequesterServiceConfig{
+		RequesterRetryCount:     3,
+		RequesterRetryBaseDelay: time.Second,
+		RequesterRetryMaxDelay:  time.Minute,
+	}})
+	planner.jitter = func(n int64) int64 { return n - 1 }
+
+	return planner
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+	prom "github.com/ASsssker/proxy/internal/monitoring/prometheus"
+)
+
+const (
+	defaultSchedulerInterval  = time.Second
+	defaultSchedulerBatchSize = 100
+)
+
+type taskScheduler struct {
+	log          *slog.Logger
+	taskProvider TaskProvider
+	notify       func()
+	interval     time.Duration
+	batchSize    int
+}
+
+func newTaskScheduler(log *slog.Logger, cfg config.Config, taskProvider TaskProvider,
+	notify func()) *taskScheduler {
+	s := &taskScheduler{
+		log:          log,
+		taskProvider: taskProvider,
+		notify:       notify,
+		interval:     cfg.ProxySchedulerInterval,
+		batchSize:    cfg.ProxySchedulerBatchSize,
+	}
+	if s.interval <= 0 {
+		s.interval = defaultSchedulerInterval
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = defaultSchedulerBatchSize
+	}
+
+	return s
+}
+
+func (s *taskScheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.promoteDue(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *taskScheduler) promoteDue(ctx context.Context) {
+	total := 0
+	for ctx.Err() == nil {
+		promoted, err := s.taskProvider.PromoteScheduledTasks(ctx, s.batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.log.Error("failed to promote scheduled tasks", slog.String("error", err.Error()))
+			}
+			break
+		}
+
+		total += promoted
+		if promoted < s.batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		prom.ProxyScheduledTasksPromoted.Add(float64(total))
+		s.log.Debug("scheduled tasks promoted", slog.Int("promoted", total))
+		s.notify()
+	}
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/ASsssker/proxy/internal/config"
+	mock_services "github.com/ASsssker/proxy/internal/services/mocks"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTaskScheduler_PromoteDue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockProvider := mock_services.NewMockTaskProvider(ctrl)
+	notified := 0
+	scheduler := newTaskScheduler(slog.New(slog.DiscardHandler), config.Config{
+		ProxyServiceConfig: config.ProxyServiceConfig{ProxySchedulerBatchSize: 2},
+	}, mockProvider, func() { notified++ })
+
+	// Полная пачка запрашивает следующую, неполная завершает перенос до следующего тика.
+	gomock.InOrder(
+		mockProvider.EXPECT().PromoteScheduledTasks(gomock.Any(), gomock.Eq(2)).Return(2, nil),
+		mockProvider.EXPECT().PromoteScheduledTasks(gomock.Any(), gomock.Eq(2)).Return(1, nil),
+	)
+	scheduler.promoteDue(context.Background())
+	require.Equal(t, 1, notified)
+
+	// Если переносить нечего, relay не будится.
+	mockProvider.EXPECT().PromoteScheduledTasks(gomock.Any(), gomock.Eq(2)).Return(0, nil)
+	scheduler.promoteDue(context.Background())
+	require.Equal(t, 1, notified)
+}
+
+func TestTaskScheduler_StorageError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockProvider := mock_services.NewMockTaskProvider(ctrl)
+	notified := 0
+	scheduler := newTaskScheduler(slog.New(slog.DiscardHandler), config.Config{}, mockProvider,
+		func() { notified++ })
+
+	mockProvider.EXPECT().PromoteScheduledTasks(gomock.Any(), gomock.Eq(defaultSchedulerBatchSize)).
+		Return(0, errors.New("connection refused")).Times(1)
+
+	scheduler.promoteDue(context.Background())
+	require.Zero(t, notified)
+}
@@ -1,99 +1,389 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ASsssker/proxy/internal/config"
 	"github.com/ASsssker/proxy/internal/models"
 	prom "github.com/ASsssker/proxy/internal/monitoring/prometheus"
+	"github.com/ASsssker/proxy/internal/netpolicy"
+	"github.com/ASsssker/proxy/internal/storage"
 )
 
 type RequestExecutor struct {
-	log        *slog.Logger
-	client     *http.Client
-	retryCount uint
+	log              *slog.Logger
+	client           *http.Client
+	blobStore        storage.BlobStore
+	blobThreshold    int64
+	maxResponseBytes int64
 }
 
-func NewRequestExecutor(cfg config.Config, log *slog.Logger) *RequestExecutor {
-	retryCount := max(cfg.RequesterRetryCount, 1)
-
+// NewRequestExecutor создаёт исполнителя запросов. blobStore может быть nil, тогда тела ответов
+// любого размера возвращаются в TaskResult целиком. Все соединения проверяются policy.
+func NewRequestExecutor(cfg config.Config, log *slog.Logger, blobStore storage.BlobStore,
+	policy *netpolicy.Policy) *RequestExecutor {
 	return &RequestExecutor{
 		client: &http.Client{
-			Timeout: cfg.RequesterHTTPClientTimeout,
+			Timeout:   cfg.RequesterHTTPClientTimeout,
+			Transport: policy.Transport(),
 		},
-		retryCount: retryCount,
-		log:        log,
+		log:              log,
+		blobStore:        blobStore,
+		blobThreshold:    cfg.RequesterBlobThreshold,
+		maxResponseBytes: cfg.RequesterResponseLimit(),
 	}
 }
 
-func (r RequestExecutor) Execute(ctx context.Context, task models.Task) (models.TaskResult, error) {
+// Execute выполняет одну попытку запроса и возвращает её описание для истории попыток.
+// Повторы планирует RequesterService через брокер, чтобы ожидание между попытками не занимало воркер.
+func (r RequestExecutor) Execute(ctx context.Context, task models.Task) (models.TaskResult, models.TaskAttempt,
+	error) {
 	const op = "task_executor_service.Execute"
-	log := r.log.With(slog.String("op", op), slog.String("task_id", task.ID))
+	log := r.log.With(slog.String("op", op), slog.String("task_id", task.ID),
+		slog.Int("attempt", task.AttemptNumber()))
 	log.DebugContext(ctx, "start operation")
 
-	start := time.Now()
+	attempt := models.TaskAttempt{TaskID: task.ID, Attempt: task.AttemptNumber(), StartedAt: time.Now()}
 
-	var err error
-	for range r.retryCount {
-		var request *http.Request
-		bodyReader := strings.NewReader(task.Body)
-		request, err = http.NewRequest(task.Method, task.URL, bodyReader)
-		if err != nil {
-			duration := time.Since(start).Seconds()
-			prom.RequesterTaskExecuteDuration.WithLabelValues(string(models.StatusError)).Observe(float64(duration))
+	taskResult, err := r.execute(ctx, task, &attempt)
+	attempt.FinishedAt = time.Now()
+	duration := attempt.FinishedAt.Sub(attempt.StartedAt).Seconds()
 
-			return models.TaskResult{}, fmt.Errorf("%s task_id=%s failed to create new request: %v", op, task.ID, err)
-		}
+	if err != nil {
+		prom.RequesterTaskExecuteDuration.WithLabelValues(string(models.StatusError)).Observe(duration)
+
+		err = fmt.Errorf("%s task_id=%s %w", op, task.ID, err)
+		attempt.ErrorCode = classifyError(err)
+		attempt.ErrorMessage = err.Error()
+
+		return models.TaskResult{}, attempt, err
+	}
+
+	prom.RequesterTaskExecuteDuration.WithLabelValues(string(models.StatusDone)).Observe(duration)
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return taskResult, attempt, nil
+}
+
+func (r RequestExecutor) execute(ctx context.Context, task models.Task, attempt *models.TaskAttempt) (
+	models.TaskResult, error) {
+	requestURL, err := task.RequestURL()
+	if err != nil {
+		return models.TaskResult{}, fmt.Errorf("failed to build request url: %w: %v", ErrInvalidRequest, err)
+	}
+
+	requestBody, err := task.RawBody()
+	if err != nil {
+		return models.TaskResult{}, fmt.Errorf("failed to decode request body: %w: %v", ErrInvalidRequest, err)
+	}
+
+	// Адрес запоминается при каждом получении соединения, при редиректах остаётся адрес последнего.
+	var remoteAddr atomic.Value
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			remoteAddr.Store(info.Conn.RemoteAddr().String())
+		},
+	})
+	defer func() {
+		attempt.RemoteAddr, _ = remoteAddr.Load().(string)
+	}()
+
+	request, err := http.NewRequestWithContext(ctx, task.Method, requestURL, bytes.NewReader(requestBody))
+	if err != nil {
+		return models.TaskResult{}, fmt.Errorf("failed to create new request: %w: %v", ErrInvalidRequest, err)
+	}
+
+	request.Header = task.TaskHeadersToHTTPHeaders().Clone()
+
+	resp, err := r.client.Do(request)
+	if err != nil {
+		return models.TaskResult{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	attempt.StatusCode = resp.StatusCode
+
+	taskResult := models.TaskResult{
+		ID:         task.ID,
+		Status:     models.StatusDone,
+		StatusCode: resp.StatusCode,
+		Headers:    models.Headers(resp.Header.Clone()),
+	}
+	if err := r.readBody(ctx, task, resp, &taskResult); err != nil {
+		return models.TaskResult{}, err
+	}
+
+	return taskResult, nil
+}
+
+// readBody читает тело ответа в taskResult. Тела больше blobThreshold не буферизуются,
+// а потоком сохраняются в blobStore, в taskResult при этом остаётся только ссылка на них.
+// Тело длиннее лимита размера обрезается или приводит к ErrBodyTooLarge в зависимости от политики задачи.
+func (r RequestExecutor) readBody(ctx context.Context, task models.Task, resp *http.Response,
+	taskResult *models.TaskResult) error {
+	limit := r.responseLimit(task)
+	failOversize := task.OversizePolicy == models.OversizeFail
+	if limit > 0 && failOversize && resp.ContentLength > limit {
+		return fmt.Errorf("content length %d exceeds limit %d: %w", resp.ContentLength, limit, ErrBodyTooLarge)
+	}
 
-		request.Header = task.TaskHeadersToHTTPHeaders().Clone()
+	source := &limitedReader{r: resp.Body, remaining: limit, failOversize: failOversize}
+	if limit <= 0 {
+		source.remaining = -1
+	}
 
-		var resp *http.Response
-		resp, err = r.client.Do(request)
-		if err != nil {
-			log.ErrorContext(ctx, "failed to send request: %v", slog.String("error", err.Error()))
-			continue
+	var reader io.Reader = source
+	if r.blobStore != nil && r.blobThreshold > 0 {
+		reader = io.LimitReader(source, r.blobThreshold+1)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if r.blobStore == nil || r.blobThreshold <= 0 || int64(len(body)) <= r.blobThreshold {
+		taskResult.BodyEncoding = detectBodyEncoding(resp.Header, body)
+		taskResult.Body = models.EncodeBody(body, taskResult.BodyEncoding)
+		taskResult.ContentLength = len(body)
+		setTruncated(taskResult, source, resp)
+		return nil
+	}
+
+	inspector := &bodyInspector{}
+	content := io.TeeReader(io.MultiReader(bytes.NewReader(body), source), inspector)
+	if err := r.blobStore.Put(ctx, task.ID, content, -1); err != nil {
+		if deleteErr := r.blobStore.Delete(context.WithoutCancel(ctx), task.ID); deleteErr != nil {
+			r.log.WarnContext(ctx, "failed to delete incomplete blob", slog.String("task_id", task.ID),
+				slog.String("error", deleteErr.Error()))
 		}
 
-		defer resp.Body.Close()
+		return fmt.Errorf("failed to store response body: %w", err)
+	}
+
+	taskResult.BodyRef = task.ID
+	taskResult.BodyEncoding = bodyEncoding(resp.Header, inspector.ValidUTF8())
+	taskResult.ContentLength = int(inspector.size)
+	setTruncated(taskResult, source, resp)
+
+	return nil
+}
+
+// responseLimit возвращает лимит размера тела для задачи: лимит задачи может только уменьшить
+// глобальный. 0 означает отсутствие лимита.
+func (r RequestExecutor) responseLimit(task models.Task) int64 {
+	limit := r.maxResponseBytes
+	if task.MaxResponseBytes > 0 && (limit <= 0 || task.MaxResponseBytes < limit) {
+		limit = task.MaxResponseBytes
+	}
+
+	return limit
+}
+
+func setTruncated(taskResult *models.TaskResult, source *limitedReader, resp *http.Response) {
+	if !source.exceeded {
+		return
+	}
+
+	taskResult.Truncated = true
+	if resp.ContentLength > 0 {
+		taskResult.OriginalContentLength = resp.ContentLength
+	}
+}
+
+// classifyError сводит ошибку выполнения запроса к стабильному коду класса ошибки.
+func classifyError(err error) models.ErrorCode {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return models.ErrorCancelled
+	case errors.Is(err, netpolicy.ErrBlocked):
+		return models.ErrorBlockedDestination
+	case errors.Is(err, ErrBodyTooLarge):
+		return models.ErrorBodyTooLarge
+	case errors.Is(err, ErrInvalidRequest):
+		return models.ErrorInvalidRequest
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return models.ErrorDNSFailure
+	}
+
+	if isTLSError(err) {
+		return models.ErrorTLS
+	}
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			duration := time.Since(start).Seconds()
-			prom.RequesterTaskExecuteDuration.WithLabelValues(string(models.StatusError)).Observe(float64(duration))
+	var opErr *net.OpError
+	dialFailed := errors.As(err, &opErr) && opErr.Op == "dial"
 
-			return models.TaskResult{}, fmt.Errorf("%s task_id=%s failed to read response body: %v", op, task.ID, err)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, context.DeadlineExceeded) {
+		if dialFailed {
+			return models.ErrorConnectTimeout
 		}
+		return models.ErrorReadTimeout
+	}
+
+	return models.ErrorConnection
+}
+
+func isTLSError(err error) bool {
+	var (
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+
+	return errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+// detectBodyEncoding выбирает text только для текстовых типов содержимого с корректным UTF-8,
+// чтобы тело ответа можно было восстановить без искажений.
+func detectBodyEncoding(headers http.Header, body []byte) models.BodyEncoding {
+	return bodyEncoding(headers, utf8.Valid(body))
+}
+
+func bodyEncoding(headers http.Header, validUTF8 bool) models.BodyEncoding {
+	if encoding := headers.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return models.BodyEncodingBase64
+	}
+
+	if !validUTF8 {
+		return models.BodyEncodingBase64
+	}
+
+	contentType := headers.Get("Content-Type")
+	if contentType == "" {
+		return models.BodyEncodingText
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return models.BodyEncodingBase64
+	}
+
+	if isTextMediaType(mediaType) {
+		return models.BodyEncodingText
+	}
+
+	return models.BodyEncodingBase64
+}
+
+func isTextMediaType(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+
+	if strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
 
-		headers := make(map[string]string, len(resp.Header))
-		for key, values := range resp.Header {
-			headers[key] = strings.Join(values, ";")
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/ecmascript",
+		"application/x-www-form-urlencoded", "application/yaml", "application/x-yaml", "application/graphql":
+		return true
+	default:
+		return false
+	}
+}
+
+// bodyInspector считает размер тела и проверяет корректность UTF-8 по мере его чтения,
+// не накапливая тело в памяти. Руна, разрезанная между вызовами Write, дожидается продолжения в pending.
+type bodyInspector struct {
+	size    int64
+	invalid bool
+	pending []byte
+}
+
+func (b *bodyInspector) Write(p []byte) (int, error) {
+	b.size += int64(len(p))
+	if b.invalid {
+		return len(p), nil
+	}
+
+	data := p
+	if len(b.pending) > 0 {
+		data = append(b.pending, p...)
+	}
+
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i > len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
 		}
+	}
 
-		taskResult := models.TaskResult{
-			ID:            task.ID,
-			Status:        models.StatusDone,
-			StatusCode:    resp.StatusCode,
-			Headers:       headers,
-			Body:          string(body),
-			ContentLength: len(body),
+	if !utf8.Valid(data[:cut]) {
+		b.invalid = true
+	}
+	b.pending = append(b.pending[:0], data[cut:]...)
+
+	return len(p), nil
+}
+
+func (b *bodyInspector) ValidUTF8() bool {
+	return !b.invalid && len(b.pending) == 0
+}
+
+// limitedReader отдаёт не больше remaining байт и запоминает, что источник оказался длиннее.
+// Отрицательный remaining снимает ограничение.
+type limitedReader struct {
+	r            io.Reader
+	remaining    int64
+	failOversize bool
+	exceeded     bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return l.r.Read(p)
+	}
+
+	if l.remaining == 0 {
+		// Лимит исчерпан: пробуем прочитать ещё байт, чтобы отличить тело ровно по лимиту от более длинного.
+		var probe [1]byte
+		n, err := io.ReadFull(l.r, probe[:])
+		if n == 0 {
+			return 0, err
 		}
 
-		duration := time.Since(start).Seconds()
-		prom.RequesterTaskExecuteDuration.WithLabelValues(string(models.StatusDone)).Observe(float64(duration))
+		l.exceeded = true
+		if l.failOversize {
+			return 0, ErrBodyTooLarge
+		}
 
-		log.DebugContext(ctx, "the operation was successfully completed")
+		return 0, io.EOF
+	}
 
-		return taskResult, nil
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
 	}
 
-	duration := time.Since(start).Seconds()
-	prom.RequesterTaskExecuteDuration.WithLabelValues(string(models.StatusError)).Observe(float64(duration))
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
 
-	return models.TaskResult{}, fmt.Errorf("%s task_id=%s failed to execute task: %v", op, task.ID, err)
+	return n, err
 }
@@ -0,0 +1,462 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/ASsssker/proxy/internal/netpolicy"
+	"github.com/ASsssker/proxy/internal/storage/blob"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecute_BodyEncoding(t *testing.T) {
+	binaryBody := []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0xff}
+
+	tests := []struct {
+		name             string
+		contentType      string
+		responseBody     []byte
+		expectedEncoding models.BodyEncoding
+	}{
+		{
+			name:             "text body",
+			contentType:      "text/html; charset=utf-8",
+			responseBody:     []byte("<html>привет</html>"),
+			expectedEncoding: models.BodyEncodingText,
+		},
+		{
+			name:             "json body",
+			contentType:      "application/problem+json",
+			responseBody:     []byte(`{"title":"error"}`),
+			expectedEncoding: models.BodyEncodingText,
+		},
+		{
+			name:             "binary body",
+			contentType:      "image/png",
+			responseBody:     binaryBody,
+			expectedEncoding: models.BodyEncodingBase64,
+		},
+		{
+			name:             "invalid utf-8 with text content type",
+			contentType:      "text/plain",
+			responseBody:     binaryBody,
+			expectedEncoding: models.BodyEncodingBase64,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, binaryBody, body)
+
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = w.Write(tt.responseBody)
+			}))
+			defer server.Close()
+
+			executor := NewRequestExecutor(config.Config{}, slog.New(slog.DiscardHandler), nil, newLoopbackPolicy(t))
+			taskResult, _, err := executor.Execute(context.Background(), models.Task{
+				ID:           uuid.NewString(),
+				URL:          server.URL,
+				Method:       http.MethodPost,
+				Body:         base64.StdEncoding.EncodeToString(binaryBody),
+				BodyEncoding: models.BodyEncodingBase64,
+			})
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedEncoding, taskResult.BodyEncoding)
+
+			body, err := taskResult.RawBody()
+			require.NoError(t, err)
+			require.Equal(t, tt.responseBody, body)
+			require.Equal(t, len(tt.responseBody), taskResult.ContentLength)
+		})
+	}
+}
+
+func TestExecute_BlobStore(t *testing.T) {
+	textBody := []byte(strings.Repeat("привет, мир! ", 10000))
+	binaryBody := append(bytes.Clone(textBody), 0xff)
+
+	tests := []struct {
+		name             string
+		responseBody     []byte
+		expectedEncoding models.BodyEncoding
+		expectedRef      bool
+	}{
+		{
+			name:             "small body",
+			responseBody:     []byte("привет"),
+			expectedEncoding: models.BodyEncodingText,
+		},
+		{
+			name:             "large text body",
+			responseBody:     textBody,
+			expectedEncoding: models.BodyEncodingText,
+			expectedRef:      true,
+		},
+		{
+			name:             "large binary body",
+			responseBody:     binaryBody,
+			expectedEncoding: models.BodyEncodingBase64,
+			expectedRef:      true,
+		},
+	}
+
+	blobStore, err := blob.NewFSStore(t.TempDir())
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				_, _ = w.Write(tt.responseBody)
+			}))
+			defer server.Close()
+
+			executor := NewRequestExecutor(config.Config{
+				RequesterServiceConfig: config.RequesterServiceConfig{RequesterBlobThreshold: 1000},
+			}, slog.New(slog.DiscardHandler), blobStore, newLoopbackPolicy(t))
+
+			taskID := uuid.NewString()
+			taskResult, _, err := executor.Execute(context.Background(), models.Task{
+				ID:     taskID,
+				URL:    server.URL,
+				Method: http.MethodGet,
+			})
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedEncoding, taskResult.BodyEncoding)
+			require.Equal(t, len(tt.responseBody), taskResult.ContentLength)
+
+			if !tt.expectedRef {
+				require.Empty(t, taskResult.BodyRef)
+				return
+			}
+
+			require.Equal(t, taskID, taskResult.BodyRef)
+			require.Empty(t, taskResult.Body)
+
+			content, err := blobStore.Get(context.Background(), taskResult.BodyRef)
+			require.NoError(t, err)
+			defer content.Close()
+
+			body, err := io.ReadAll(content)
+			require.NoError(t, err)
+			require.Equal(t, tt.responseBody, body)
+		})
+	}
+}
+
+func TestExecute_ResponseLimit(t *testing.T) {
+	responseBody := []byte(strings.Repeat("0123456789", 100))
+
+	tests := []struct {
+		name             string
+		globalLimit      int64
+		blobThreshold    int64
+		chunked          bool
+		task             models.Task
+		expectedBody     []byte
+		expectedOriginal int64
+		errExpected      error
+	}{
+		{
+			name:         "default limit",
+			expectedBody: responseBody,
+		},
+		{
+			name:         "no limit",
+			globalLimit:  -1,
+			expectedBody: responseBody,
+		},
+		{
+			name:         "body equal to limit",
+			globalLimit:  int64(len(responseBody)),
+			expectedBody: responseBody,
+		},
+		{
+			name:             "global limit truncates",
+			globalLimit:      100,
+			expectedBody:     responseBody[:100],
+			expectedOriginal: int64(len(responseBody)),
+		},
+		{
+			name:             "task limit lower than global",
+			globalLimit:      100,
+			task:             models.Task{MaxResponseBytes: 10},
+			expectedBody:     responseBody[:10],
+			expectedOriginal: int64(len(responseBody)),
+		},
+		{
+			name:             "task limit cannot raise global",
+			globalLimit:      100,
+			task:             models.Task{MaxResponseBytes: 500},
+			expectedBody:     responseBody[:100],
+			expectedOriginal: int64(len(responseBody)),
+		},
+		{
+			name:         "truncated chunked body without content length",
+			task:         models.Task{MaxResponseBytes: 10},
+			chunked:      true,
+			expectedBody: responseBody[:10],
+		},
+		{
+			name:             "truncated body in blob store",
+			blobThreshold:    50,
+			task:             models.Task{MaxResponseBytes: 200},
+			expectedBody:     responseBody[:200],
+			expectedOriginal: int64(len(responseBody)),
+		},
+		{
+			name:        "fail policy with content length",
+			task:        models.Task{MaxResponseBytes: 10, OversizePolicy: models.OversizeFail},
+			errExpected: ErrBodyTooLarge,
+		},
+		{
+			name:        "fail policy with chunked body",
+			task:        models.Task{MaxResponseBytes: 10, OversizePolicy: models.OversizeFail},
+			chunked:     true,
+			errExpected: ErrBodyTooLarge,
+		},
+	}
+
+	blobStore, err := blob.NewFSStore(t.TempDir())
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				if tt.chunked {
+					_, _ = w.Write(responseBody[:1])
+					w.(http.Flusher).Flush()
+					_, _ = w.Write(responseBody[1:])
+					return
+				}
+				_, _ = w.Write(responseBody)
+			}))
+			defer server.Close()
+
+			executor := NewRequestExecutor(config.Config{
+				RequesterServiceConfig: config.RequesterServiceConfig{
+					RequesterMaxResponseBytes: tt.globalLimit,
+					RequesterBlobThreshold:    tt.blobThreshold,
+				},
+			}, slog.New(slog.DiscardHandler), blobStore, newLoopbackPolicy(t))
+
+			task := tt.task
+			task.ID = uuid.NewString()
+			task.URL = server.URL
+			task.Method = http.MethodGet
+
+			taskResult, _, err := executor.Execute(context.Background(), task)
+			if tt.errExpected != nil {
+				require.ErrorIs(t, err, tt.errExpected)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, len(tt.expectedBody) < len(responseBody), taskResult.Truncated)
+			require.Equal(t, tt.expectedOriginal, taskResult.OriginalContentLength)
+			require.Equal(t, len(tt.expectedBody), taskResult.ContentLength)
+
+			body, err := taskResult.RawBody()
+			require.NoError(t, err)
+			if taskResult.BodyRef != "" {
+				content, err := blobStore.Get(context.Background(), taskResult.BodyRef)
+				require.NoError(t, err)
+				defer content.Close()
+
+				body, err = io.ReadAll(content)
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.expectedBody, body)
+		})
+	}
+}
+
+func TestExecute_BlockedDestination(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	policy, err := netpolicy.New(config.Config{})
+	require.NoError(t, err)
+
+	executor := NewRequestExecutor(config.Config{
+		RequesterServiceConfig: config.RequesterServiceConfig{RequesterRetryCount: 3},
+	}, slog.New(slog.DiscardHandler), nil, policy)
+
+	_, _, err = executor.Execute(context.Background(), models.Task{
+		ID:     uuid.NewString(),
+		URL:    server.URL,
+		Method: http.MethodGet,
+	})
+	require.ErrorIs(t, err, netpolicy.ErrBlocked)
+	require.Zero(t, calls.Load())
+}
+
+func TestExecute_Attempt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	executor := NewRequestExecutor(config.Config{}, slog.New(slog.DiscardHandler), nil, newLoopbackPolicy(t))
+
+	t.Run("upstream response", func(t *testing.T) {
+		task := models.Task{ID: uuid.NewString(), URL: server.URL, Method: http.MethodGet, Attempt: 2}
+
+		_, attempt, err := executor.Execute(context.Background(), task)
+		require.NoError(t, err)
+		require.Equal(t, task.ID, attempt.TaskID)
+		require.Equal(t, 2, attempt.Attempt)
+		require.Equal(t, http.StatusServiceUnavailable, attempt.StatusCode)
+		require.Equal(t, server.Listener.Addr().String(), attempt.RemoteAddr)
+		require.Empty(t, attempt.ErrorCode)
+		require.False(t, attempt.FinishedAt.Before(attempt.StartedAt))
+	})
+
+	t.Run("failed attempt", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := listener.Addr().String()
+		require.NoError(t, listener.Close())
+
+		_, attempt, err := executor.Execute(context.Background(), models.Task{
+			ID: uuid.NewString(), URL: "http://" + addr, Method: http.MethodGet,
+		})
+		require.Error(t, err)
+		require.Equal(t, 1, attempt.Attempt)
+		require.Zero(t, attempt.StatusCode)
+		require.Equal(t, models.ErrorConnection, attempt.ErrorCode)
+		require.Equal(t, err.Error(), attempt.ErrorMessage)
+	})
+}
+
+// newLoopbackPolicy разрешает подключения к loopback, на котором слушают тестовые серверы.
+func newLoopbackPolicy(t *testing.T) *netpolicy.Policy {
+	policy, err := netpolicy.New(config.Config{
+		RequesterServiceConfig: config.RequesterServiceConfig{
+			RequesterAllowCIDRs: []string{"127.0.0.0/8", "::1/128"},
+		},
+	})
+	require.NoError(t, err)
+
+	return policy
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected models.ErrorCode
+	}{
+		{
+			name:     "blocked destination",
+			err:      fmt.Errorf("dial: %w", netpolicy.ErrBlocked),
+			expected: models.ErrorBlockedDestination,
+		},
+		{
+			name:     "body too large",
+			err:      fmt.Errorf("read: %w", ErrBodyTooLarge),
+			expected: models.ErrorBodyTooLarge,
+		},
+		{
+			name:     "invalid request",
+			err:      fmt.Errorf("build: %w", ErrInvalidRequest),
+			expected: models.ErrorInvalidRequest,
+		},
+		{
+			name: "dns failure",
+			err: &url.Error{Op: "Get", URL: "http://example.invalid", Err: &net.OpError{
+				Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true},
+			}},
+			expected: models.ErrorDNSFailure,
+		},
+		{
+			name:     "connect timeout",
+			err:      &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: context.DeadlineExceeded}},
+			expected: models.ErrorConnectTimeout,
+		},
+		{
+			name:     "connection refused",
+			err:      &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}},
+			expected: models.ErrorConnection,
+		},
+		{
+			name:     "tls alert",
+			err:      &url.Error{Op: "Get", Err: &net.OpError{Op: "remote error", Err: tls.AlertError(40)}},
+			expected: models.ErrorTLS,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, classifyError(tt.err))
+		})
+	}
+}
+
+func TestExecute_ErrorClassification(t *testing.T) {
+	t.Run("read timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+
+		executor := NewRequestExecutor(config.Config{
+			RequesterServiceConfig: config.RequesterServiceConfig{RequesterHTTPClientTimeout: 50 * time.Millisecond},
+		}, slog.New(slog.DiscardHandler), nil, newLoopbackPolicy(t))
+
+		_, _, err := executor.Execute(context.Background(), models.Task{
+			ID: uuid.NewString(), URL: server.URL, Method: http.MethodGet,
+		})
+		require.Error(t, err)
+		require.Equal(t, models.ErrorReadTimeout, classifyError(err))
+	})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		executor := NewRequestExecutor(config.Config{}, slog.New(slog.DiscardHandler), nil, newLoopbackPolicy(t))
+
+		_, _, err := executor.Execute(context.Background(), models.Task{
+			ID: uuid.NewString(), URL: server.URL, Method: http.MethodGet,
+		})
+		require.Error(t, err)
+		require.Equal(t, models.ErrorTLS, classifyError(err))
+	})
+
+	t.Run("invalid method", func(t *testing.T) {
+		executor := NewRequestExecutor(config.Config{}, slog.New(slog.DiscardHandler), nil, newLoopbackPolicy(t))
+
+		_, _, err := executor.Execute(context.Background(), models.Task{
+			ID: uuid.NewString(), URL: "http://127.0.0.1", Method: "BAD METHOD",
+		})
+		require.Error(t, err)
+		require.Equal(t, models.ErrorInvalidRequest, classifyError(err))
+	})
+}
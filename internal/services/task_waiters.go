@@ -0,0 +1,57 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/ASsssker/proxy/internal/models"
+)
+
+type taskWaiters struct {
+	mu      sync.Mutex
+	waiters map[string][]chan models.TaskStatus
+}
+
+func newTaskWaiters() *taskWaiters {
+	return &taskWaiters{waiters: make(map[string][]chan models.TaskStatus)}
+}
+
+func (t *taskWaiters) register(taskID string) chan models.TaskStatus {
+	ch := make(chan models.TaskStatus, 1)
+
+	t.mu.Lock()
+	t.waiters[taskID] = append(t.waiters[taskID], ch)
+	t.mu.Unlock()
+
+	return ch
+}
+
+func (t *taskWaiters) unregister(taskID string, ch chan models.TaskStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	waiters := t.waiters[taskID]
+	for i, waiter := range waiters {
+		if waiter == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+
+	if len(waiters) == 0 {
+		delete(t.waiters, taskID)
+		return
+	}
+	t.waiters[taskID] = waiters
+}
+
+func (t *taskWaiters) notify(completion models.TaskCompletion) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, ch := range t.waiters[completion.TaskID] {
+		select {
+		case ch <- completion.Status:
+		default:
+		}
+	}
+}
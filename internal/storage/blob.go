@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// BlobStore хранит тела ответов, которые слишком велики для колонки tasks.body.
+type BlobStore interface {
+	// Put сохраняет содержимое r под ключом key. size равен -1, если длина заранее неизвестна.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, key string) error
+}
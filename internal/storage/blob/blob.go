@@ -0,0 +1,36 @@
+package blob
+
+import (
+	"fmt"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/storage"
+)
+
+const (
+	DriverFS = "fs"
+	DriverS3 = "s3"
+)
+
+// New создаёт хранилище по BLOB_STORE_DRIVER. Если драйвер не задан, возвращается nil
+// и тела ответов хранятся только в postgres.
+func New(cfg config.Config) (storage.BlobStore, error) {
+	switch cfg.BlobStoreDriver {
+	case "":
+		return nil, nil
+	case DriverFS:
+		store, err := NewFSStore(cfg.BlobStoreFSPath)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case DriverS3:
+		store, err := NewS3Store(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown blob store driver %q", cfg.BlobStoreDriver)
+	}
+}
@@ -0,0 +1,118 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ASsssker/proxy/internal/storage"
+)
+
+// FSStore хранит объекты в локальной файловой системе, раскладывая их по подкаталогам
+// по первым символам ключа, чтобы не держать все файлы в одном каталоге.
+type FSStore struct {
+	root string
+}
+
+func NewFSStore(root string) (*FSStore, error) {
+	if root == "" {
+		return nil, errors.New("blob store root directory is not set")
+	}
+
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create blob store root directory: %v", err)
+	}
+
+	return &FSStore{root: root}, nil
+}
+
+func (f FSStore) Put(ctx context.Context, key string, r io.Reader, _ int64) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create blob directory: %v", err)
+	}
+
+	// Запись идёт во временный файл, который переименовывается только после успешного копирования,
+	// поэтому читатели никогда не видят недописанный объект.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary blob file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, contextReader{ctx: ctx, r: r}); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write blob: %v", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close blob file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move blob file: %v", err)
+	}
+
+	return nil
+}
+
+func (f FSStore) Get(_ context.Context, key string) (io.ReadSeekCloser, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("blob %q: %w", key, storage.ErrBlobNotFound)
+		}
+
+		return nil, fmt.Errorf("failed to open blob: %v", err)
+	}
+
+	return file, nil
+}
+
+func (f FSStore) Delete(_ context.Context, key string) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete blob: %v", err)
+	}
+
+	return nil
+}
+
+func (f FSStore) path(key string) (string, error) {
+	if len(key) < 2 || !filepath.IsLocal(key) || filepath.Base(key) != key {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+
+	return filepath.Join(f.root, key[:2], key), nil
+}
+
+// contextReader прерывает копирование при отмене контекста.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.r.Read(p)
+}
@@ -0,0 +1,375 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/config"
+	"github.com/ASsssker/proxy/internal/storage"
+)
+
+const (
+	s3Service         = "s3"
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3DateFormat      = "20060102T150405Z"
+)
+
+// S3Store работает с любым S3-совместимым хранилищем (AWS S3, MinIO, Ceph) через REST API
+// с подписью запросов AWS Signature Version 4. Объекты адресуются в path-style: <url>/<bucket>/<key>.
+type S3Store struct {
+	client    *http.Client
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	now       func() time.Time
+}
+
+func NewS3Store(cfg config.Config) (*S3Store, error) {
+	endpoint, err := url.Parse(cfg.BlobStoreS3URL)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 url %q", cfg.BlobStoreS3URL)
+	}
+
+	if cfg.BlobStoreS3Bucket == "" {
+		return nil, errors.New("s3 bucket is not set")
+	}
+
+	region := cfg.BlobStoreS3Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3Store{
+		client:    &http.Client{Timeout: cfg.BlobStoreS3Timeout},
+		endpoint:  endpoint,
+		bucket:    cfg.BlobStoreS3Bucket,
+		region:    region,
+		accessKey: cfg.BlobStoreS3Access,
+		secretKey: cfg.BlobStoreS3Secret,
+		now:       time.Now,
+	}, nil
+}
+
+func (s S3Store) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if size < 0 {
+		// Для PUT нужна длина объекта. Если она неизвестна, тело сначала сбрасывается на диск,
+		// а не в память.
+		spool, err := os.CreateTemp("", "proxy-blob-*")
+		if err != nil {
+			return fmt.Errorf("failed to create spool file: %v", err)
+		}
+		defer func() {
+			_ = spool.Close()
+			_ = os.Remove(spool.Name())
+		}()
+
+		if size, err = io.Copy(spool, contextReader{ctx: ctx, r: r}); err != nil {
+			return fmt.Errorf("failed to spool blob: %v", err)
+		}
+
+		if _, err = spool.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind spool file: %v", err)
+		}
+		r = spool
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), io.NopCloser(r))
+	if err != nil {
+		return fmt.Errorf("failed to create s3 put request: %v", err)
+	}
+	request.ContentLength = size
+	request.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := s.do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+
+	return nil
+}
+
+func (s S3Store) Get(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, s.objectURL(key), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 head request: %v", err)
+	}
+
+	resp, err := s.do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("blob %q: %w", key, storage.ErrBlobNotFound)
+	default:
+		return nil, s3Error(resp)
+	}
+
+	return &s3Object{ctx: ctx, store: s, key: key, size: resp.ContentLength}, nil
+}
+
+func (s S3Store) Delete(ctx context.Context, key string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 delete request: %v", err)
+	}
+
+	resp, err := s.do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return s3Error(resp)
+	}
+}
+
+func (s S3Store) objectURL(key string) string {
+	return s.endpoint.JoinPath(s.bucket, key).String()
+}
+
+func (s S3Store) do(request *http.Request) (*http.Response, error) {
+	signS3Request(request, s.accessKey, s.secretKey, s.region, s3UnsignedPayload, s.now())
+
+	resp, err := s.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send s3 request: %v", err)
+	}
+
+	return resp, nil
+}
+
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected s3 response status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// s3Object читает объект ranged-запросами: каждый Seek закрывает текущий ответ,
+// и следующее чтение начинается с нового смещения.
+type s3Object struct {
+	ctx    context.Context
+	store  S3Store
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+
+	if o.body == nil {
+		if err := o.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = o.offset + offset
+	case io.SeekEnd:
+		next = o.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if next < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if next != o.offset {
+		_ = o.Close()
+		o.offset = next
+	}
+
+	return next, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+
+	err := o.body.Close()
+	o.body = nil
+
+	return err
+}
+
+func (o *s3Object) open() error {
+	request, err := http.NewRequestWithContext(o.ctx, http.MethodGet, o.store.objectURL(o.key), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 get request: %v", err)
+	}
+	if o.offset > 0 {
+		request.Header.Set("Range", "bytes="+strconv.FormatInt(o.offset, 10)+"-")
+	}
+
+	resp, err := o.store.do(request)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		defer resp.Body.Close()
+		return s3Error(resp)
+	}
+
+	o.body = resp.Body
+
+	return nil
+}
+
+// signS3Request подписывает запрос по схеме AWS Signature Version 4. В подпись входят host,
+// range и все заголовки x-amz-*.
+func signS3Request(request *http.Request, accessKey, secretKey, region, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format(s3DateFormat)
+	date := amzDate[:8]
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for name, values := range request.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		s3EscapePath(request.URL.Path),
+		s3CanonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + s3Service + "/aws4_request"
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, hashSHA256(canonicalRequest)}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, s3Service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, accessKey, scope, signedHeaders, signature))
+}
+
+func s3EscapePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(key)+"="+s3Escape(value))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape кодирует строку по правилам SigV4: без изменений остаются только A-Z, a-z, 0-9 и "-._~".
+func s3Escape(value string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			escaped.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&escaped, "%%%02X", c)
+	}
+
+	return escaped.String()
+}
+
+func hashSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -3,5 +3,15 @@ package storage
 import "errors"
 
 var (
-	ErrTaskNotFound = errors.New("task not found")
+	ErrTaskNotFound  = errors.New("task not found")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrTaskFinalized = errors.New("task already finalized")
+	ErrBlobNotFound  = errors.New("blob not found")
+	// ErrTaskLeased означает, что задачу выполняет другой экземпляр requester или аренда уже утрачена.
+	ErrTaskLeased = errors.New("task is leased by another worker")
+
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	// ErrNotReplayable означает, что по dead-letter записи нельзя запустить задачу: сообщение не разобрано
+	// как задача или задача ещё выполняется.
+	ErrNotReplayable = errors.New("dead letter cannot be replayed")
 )
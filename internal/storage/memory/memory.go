@@ -0,0 +1,1055 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/ASsssker/proxy/internal/services"
+	"github.com/ASsssker/proxy/internal/storage"
+)
+
+// MemoryDB хранит задачи в памяти процесса и повторяет поведение PostgresDB, включая проверки статуса
+// при обновлении. Данные не переживают перезапуск, поэтому хранилище подходит для локального запуска и тестов.
+type MemoryDB struct {
+	log       *slog.Logger
+	blobStore storage.BlobStore
+
+	mu             sync.RWMutex
+	tasks          map[string]*taskRecord
+	attempts       map[string][]models.TaskAttempt
+	outbox         []models.OutboxMessage
+	nextOutboxID   int64
+	outboxAttempts map[int64]int
+	scheduled      []models.OutboxMessage
+	deadLetters    map[string]*models.DeadLetter
+	idempotency    map[string]models.IdempotencyKey
+
+	// publishMu не даёт двум вызовам PublishOutbox опубликовать одну и ту же задачу.
+	publishMu sync.Mutex
+}
+
+type taskRecord struct {
+	result    models.TaskResult
+	task      models.Task
+	method    string
+	url       string
+	host      string
+	createdAt time.Time
+	updatedAt time.Time
+
+	workerID       string
+	attempt        int
+	leaseExpiresAt time.Time
+}
+
+// NewMemoryDB создаёт пустое хранилище. blobStore нужен для чтения тел ответов, вынесенных
+// из хранилища задач, и может быть nil, если внешнее хранилище не используется.
+func NewMemoryDB(log *slog.Logger, blobStore storage.BlobStore) *MemoryDB {
+	return &MemoryDB{
+		log:         log,
+		blobStore:   blobStore,
+		tasks:       make(map[string]*taskRecord),
+		attempts:    make(map[string][]models.TaskAttempt),
+		deadLetters: make(map[string]*models.DeadLetter),
+		idempotency: make(map[string]models.IdempotencyKey),
+
+		outboxAttempts: make(map[int64]int),
+	}
+}
+
+func (m *MemoryDB) GetTask(ctx context.Context, taskID string, omitBody bool) (models.TaskResult, error) {
+	const op = "memory.GetTask"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := m.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.RLock()
+	record, ok := m.tasks[taskID]
+	var taskResult models.TaskResult
+	if ok {
+		taskResult = record.result
+		taskResult.Headers = cloneHeaders(record.result.Headers)
+	}
+	m.mu.RUnlock()
+
+	if !ok {
+		return models.TaskResult{}, fmt.Errorf("%s request_id=%s task not found: %w",
+			op, requestID, storage.ErrTaskNotFound)
+	}
+
+	switch {
+	case omitBody:
+		taskResult.Body = ""
+	case taskResult.BodyRef != "":
+		body, err := m.readBlob(ctx, taskResult.BodyRef)
+		if err != nil {
+			return models.TaskResult{}, fmt.Errorf("%s request_id=%s failed to read task body: %v",
+				op, requestID, err)
+		}
+		taskResult.Body = models.EncodeBody(body, taskResult.BodyEncoding)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return taskResult, nil
+}
+
+func (m *MemoryDB) GetTaskBody(ctx context.Context, taskID string) (models.TaskBody, error) {
+	const op = "memory.GetTaskBody"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := m.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.RLock()
+	record, ok := m.tasks[taskID]
+	var (
+		taskResult models.TaskResult
+		updatedAt  time.Time
+	)
+	if ok {
+		taskResult = record.result
+		taskResult.Headers = cloneHeaders(record.result.Headers)
+		updatedAt = record.updatedAt
+	}
+	m.mu.RUnlock()
+
+	if !ok {
+		return models.TaskBody{}, fmt.Errorf("%s request_id=%s task not found: %w",
+			op, requestID, storage.ErrTaskNotFound)
+	}
+
+	taskBody := models.TaskBody{
+		Status:    taskResult.Status,
+		Headers:   taskResult.Headers,
+		UpdatedAt: updatedAt,
+	}
+
+	if taskResult.BodyRef != "" {
+		content, err := m.openBlob(ctx, taskResult.BodyRef)
+		if err != nil {
+			return models.TaskBody{}, fmt.Errorf("%s request_id=%s failed to open task body: %v",
+				op, requestID, err)
+		}
+		taskBody.Content = content
+	} else {
+		body, err := taskResult.RawBody()
+		if err != nil {
+			return models.TaskBody{}, fmt.Errorf("%s request_id=%s failed to decode task body: %v",
+				op, requestID, err)
+		}
+		taskBody.Content = storage.NewBytesReader(body)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return taskBody, nil
+}
+
+func (m *MemoryDB) openBlob(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if m.blobStore == nil {
+		return nil, errors.New("task body is stored externally, but blob store is not configured")
+	}
+
+	return m.blobStore.Get(ctx, key)
+}
+
+func (m *MemoryDB) readBlob(ctx context.Context, key string) ([]byte, error) {
+	content, err := m.openBlob(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	return io.ReadAll(content)
+}
+
+func (m *MemoryDB) ListTasks(ctx context.Context, filter models.TaskFilter) (models.TaskList, error) {
+	const op = "memory.ListTasks"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := m.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	var (
+		cursorCreatedAt time.Time
+		cursorID        string
+	)
+	if filter.Cursor != "" {
+		var err error
+		if cursorCreatedAt, cursorID, err = storage.DecodeCursor(filter.Cursor); err != nil {
+			return models.TaskList{}, fmt.Errorf("%s request_id=%s failed to decode cursor: %w: %v",
+				op, requestID, storage.ErrInvalidCursor, err)
+		}
+	}
+
+	desc := filter.Order == models.SortDesc
+	compare := func(createdAt time.Time, id string, otherCreatedAt time.Time, otherID string) int {
+		result := cmp.Or(createdAt.Compare(otherCreatedAt), strings.Compare(id, otherID))
+		if desc {
+			return -result
+		}
+		return result
+	}
+
+	m.mu.RLock()
+	tasks := make([]models.TaskSummary, 0)
+	for _, record := range m.tasks {
+		if !record.matches(filter) {
+			continue
+		}
+		if filter.Cursor != "" && compare(record.createdAt, record.result.ID, cursorCreatedAt, cursorID) <= 0 {
+			continue
+		}
+
+		tasks = append(tasks, record.summary())
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(tasks, func(a, b models.TaskSummary) int {
+		return compare(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+	})
+
+	taskList := models.TaskList{Tasks: tasks}
+	if len(taskList.Tasks) > filter.Limit {
+		taskList.Tasks = taskList.Tasks[:filter.Limit]
+		last := taskList.Tasks[len(taskList.Tasks)-1]
+		taskList.NextCursor = storage.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return taskList, nil
+}
+
+func (m *MemoryDB) AddTask(ctx context.Context, task models.Task) error {
+	const op = "memory.AddTask"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := m.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.addTask(task, requestID); err != nil {
+		return fmt.Errorf("%s request_id=%s %w", op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+// AddTasks не сохраняет ни одной задачи, если хотя бы одна из них уже есть.
+func (m *MemoryDB) AddTasks(ctx context.Context, tasks []models.Task) error {
+	const op = "memory.AddTasks"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := m.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, task := range tasks {
+		if _, ok := m.tasks[task.ID]; ok {
+			return fmt.Errorf("%s request_id=%s failed to add new tasks: task %s already exists",
+				op, requestID, task.ID)
+		}
+	}
+
+	for _, task := range tasks {
+		if err := m.addTask(task, requestID); err != nil {
+			return fmt.Errorf("%s request_id=%s %w", op, requestID, err)
+		}
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed", slog.Int("tasks", len(tasks)))
+
+	return nil
+}
+
+func (m *MemoryDB) AddIdempotentTask(ctx context.Context, task models.Task,
+	key models.IdempotencyKey) (models.IdempotencyKey, error) {
+	const op = "memory.AddIdempotentTask"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := m.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.idempotency[key.Key]; ok && time.Now().Before(stored.ExpiresAt) {
+		log.DebugContext(ctx, "the operation was successfully completed, idempotency key already exists")
+		return stored, nil
+	}
+
+	if err := m.addTask(task, requestID); err != nil {
+		return models.IdempotencyKey{}, fmt.Errorf("%s request_id=%s %w", op, requestID, err)
+	}
+	key.TaskID = task.ID
+	m.idempotency[key.Key] = key
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return key, nil
+}
+
+func (m *MemoryDB) DeleteExpiredIdempotencyKeys(ctx context.Context, limit int) (int, error) {
+	const op = "memory.DeleteExpiredIdempotencyKeys"
+
+	log := m.log.With(slog.String("op", op))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	deleted := 0
+	for key, stored := range m.idempotency {
+		if deleted == limit {
+			break
+		}
+		if now.Before(stored.ExpiresAt) {
+			continue
+		}
+		delete(m.idempotency, key)
+		deleted++
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed", slog.Int("deleted", deleted))
+
+	return deleted, nil
+}
+
+// addTask вызывается под m.mu.
+func (m *MemoryDB) addTask(task models.Task, requestID string) error {
+	callbackStatus := models.CallbackNone
+	if task.CallbackURL != "" {
+		callbackStatus = models.CallbackPending
+	}
+
+	now := time.Now().UTC()
+	record := &taskRecord{
+		result: models.TaskResult{
+			ID:             task.ID,
+			Status:         task.InitialStatus(),
+			Headers:        models.Headers{},
+			CallbackStatus: callbackStatus,
+			RunAt:          task.RunAt,
+		},
+		task:      task,
+		method:    task.Method,
+		url:       task.URL,
+		host:      task.Host(),
+		createdAt: now,
+		updatedAt: now,
+	}
+
+	if _, ok := m.tasks[task.ID]; ok {
+		return fmt.Errorf("failed to add new task: task %s already exists", task.ID)
+	}
+	m.tasks[task.ID] = record
+
+	if task.RunAt != nil {
+		m.scheduled = append(m.scheduled, models.OutboxMessage{RequestID: requestID, Task: task})
+		return nil
+	}
+
+	m.nextOutboxID++
+	m.outbox = append(m.outbox, models.OutboxMessage{ID: m.nextOutboxID, RequestID: requestID, Task: task})
+
+	return nil
+}
+
+func (m *MemoryDB) PublishOutbox(ctx context.Context, limit, maxAttempts int,
+	publish func(ctx context.Context, msgs []models.OutboxMessage) []error) (int, error) {
+	const op = "memory.PublishOutbox"
+
+	log := m.log.With(slog.String("op", op))
+	log.DebugContext(ctx, "start operation")
+
+	m.publishMu.Lock()
+	defer m.publishMu.Unlock()
+
+	m.mu.RLock()
+	messages := slices.Clone(m.outbox[:min(limit, len(m.outbox))])
+	m.mu.RUnlock()
+
+	var errs []error
+	if len(messages) > 0 {
+		errs = publish(ctx, messages)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	done := make(map[int64]struct{}, len(messages))
+	published := 0
+	for i, msg := range messages {
+		if errs[i] == nil {
+			done[msg.ID] = struct{}{}
+			delete(m.outboxAttempts, msg.ID)
+			published++
+			continue
+		}
+
+		m.outboxAttempts[msg.ID]++
+		if m.outboxAttempts[msg.ID] < maxAttempts {
+			continue
+		}
+
+		done[msg.ID] = struct{}{}
+		delete(m.outboxAttempts, msg.ID)
+		m.failOutboxMessage(msg, errs[i])
+	}
+
+	m.outbox = slices.DeleteFunc(m.outbox, func(msg models.OutboxMessage) bool {
+		_, ok := done[msg.ID]
+		return ok
+	})
+
+	log.DebugContext(ctx, "the operation was successfully completed", slog.Int("published", published))
+
+	return published, nil
+}
+
+// failOutboxMessage вызывается под m.mu.
+func (m *MemoryDB) failOutboxMessage(msg models.OutboxMessage, errPublish error) {
+	task := msg.Task
+	task.CallbackSecret = ""
+	payload, _ := json.Marshal(task)
+
+	deadLetter := models.NewDeadLetter(task.ID, models.DeadLetterPublishFailed, errPublish.Error(), payload)
+	m.deadLetters[deadLetter.ID] = &deadLetter
+
+	if record, ok := m.tasks[task.ID]; ok && record.result.Status == models.StatusNew {
+		record.result.Status = models.StatusError
+		record.result.ErrorMessage = "failed to publish task: " + errPublish.Error()
+		record.updatedAt = time.Now().UTC()
+	}
+}
+
+func (m *MemoryDB) PromoteScheduledTasks(ctx context.Context, limit int) (int, error) {
+	const op = "memory.PromoteScheduledTasks"
+
+	log := m.log.With(slog.String("op", op))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var due []models.OutboxMessage
+	for _, msg := range m.scheduled {
+		if !msg.Task.RunAt.After(now) {
+			due = append(due, msg)
+		}
+	}
+	slices.SortFunc(due, func(a, b models.OutboxMessage) int {
+		return cmp.Or(a.Task.RunAt.Compare(*b.Task.RunAt), strings.Compare(a.Task.ID, b.Task.ID))
+	})
+	due = due[:min(limit, len(due))]
+
+	promoted := 0
+	for _, msg := range due {
+		m.scheduled = slices.DeleteFunc(m.scheduled, func(other models.OutboxMessage) bool {
+			return other.Task.ID == msg.Task.ID
+		})
+
+		record, ok := m.tasks[msg.Task.ID]
+		if !ok || record.result.Status != models.StatusScheduled {
+			continue
+		}
+		record.result.Status = models.StatusNew
+		record.updatedAt = now.UTC()
+
+		m.nextOutboxID++
+		msg.ID = m.nextOutboxID
+		m.outbox = append(m.outbox, msg)
+		promoted++
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed", slog.Int("promoted", promoted))
+
+	return promoted, nil
+}
+
+func (m *MemoryDB) UpdateTaskStatus(ctx context.Context, taskID string, newStatus models.TaskStatus) error {
+	const op = "memory.UpdateTaskStatus"
+
+	log := m.log.With(slog.String("op", op), slog.String("task_id", taskID))
+	log.DebugContext(ctx, "start operation")
+
+	if err := m.update(taskID, func(record *taskRecord) {
+		record.result.Status = newStatus
+	}, models.StatusNew, models.StatusInProcess); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to update task status: %w", op, taskID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (m *MemoryDB) AcquireTaskLease(ctx context.Context, lease models.TaskLease) error {
+	const op = "memory.AcquireTaskLease"
+
+	log := m.log.With(slog.String("op", op), slog.String("task_id", lease.TaskID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.tasks[lease.TaskID]
+	if !ok || (record.result.Status != models.StatusNew && record.result.Status != models.StatusInProcess) {
+		return fmt.Errorf("%s task_id=%s failed to acquire task lease: %w", op, lease.TaskID,
+			storage.ErrTaskFinalized)
+	}
+
+	now := time.Now()
+	ownRetry := record.workerID == lease.WorkerID && record.attempt < lease.Attempt
+	if record.result.Status == models.StatusInProcess && record.workerID != "" && !ownRetry &&
+		record.leaseExpiresAt.After(now) {
+		return fmt.Errorf("%s task_id=%s failed to acquire task lease: %w", op, lease.TaskID, storage.ErrTaskLeased)
+	}
+
+	record.result.Status = models.StatusInProcess
+	record.workerID = lease.WorkerID
+	record.attempt = lease.Attempt
+	record.leaseExpiresAt = now.Add(lease.TTL)
+	record.updatedAt = now.UTC()
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (m *MemoryDB) ExtendTaskLease(ctx context.Context, lease models.TaskLease) error {
+	const op = "memory.ExtendTaskLease"
+
+	log := m.log.With(slog.String("op", op), slog.String("task_id", lease.TaskID))
+	log.DebugContext(ctx, "start operation")
+
+	if err := m.updateLease(lease.TaskID, lease.WorkerID, func(record *taskRecord) {
+		record.leaseExpiresAt = time.Now().Add(lease.TTL)
+	}); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to extend task lease: %w", op, lease.TaskID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (m *MemoryDB) ReleaseTaskLease(ctx context.Context, lease models.TaskLease) error {
+	const op = "memory.ReleaseTaskLease"
+
+	log := m.log.With(slog.String("op", op), slog.String("task_id", lease.TaskID))
+	log.DebugContext(ctx, "start operation")
+
+	if err := m.updateLease(lease.TaskID, lease.WorkerID, func(record *taskRecord) {
+		record.workerID = ""
+		record.leaseExpiresAt = time.Now().Add(lease.TTL)
+		record.updatedAt = time.Now().UTC()
+	}); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to release task lease: %w", op, lease.TaskID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (m *MemoryDB) ListExpiredTasks(ctx context.Context, limit int) ([]models.ExpiredTask, error) {
+	const op = "memory.ListExpiredTasks"
+
+	log := m.log.With(slog.String("op", op))
+	log.DebugContext(ctx, "start operation")
+
+	now := time.Now()
+
+	m.mu.RLock()
+	var (
+		expired   []models.ExpiredTask
+		expiresAt = make(map[string]time.Time)
+	)
+	for _, record := range m.tasks {
+		if !record.leaseExpired(now) {
+			continue
+		}
+
+		expired = append(expired, models.ExpiredTask{Task: record.task, Attempt: record.attempt,
+			WorkerID: record.workerID})
+		expiresAt[record.task.ID] = record.leaseExpiresAt
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(expired, func(a, b models.ExpiredTask) int {
+		return expiresAt[a.Task.ID].Compare(expiresAt[b.Task.ID])
+	})
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return expired[:min(limit, len(expired))], nil
+}
+
+func (m *MemoryDB) RequeueExpiredTask(ctx context.Context, task models.Task) error {
+	const op = "memory.RequeueExpiredTask"
+
+	log := m.log.With(slog.String("op", op), slog.String("task_id", task.ID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.tasks[task.ID]
+	if !ok || !record.leaseExpired(time.Now()) {
+		return fmt.Errorf("%s task_id=%s failed to requeue task: %w", op, task.ID, storage.ErrTaskLeased)
+	}
+
+	record.result.Status = models.StatusNew
+	record.workerID = ""
+	record.leaseExpiresAt = time.Time{}
+	record.updatedAt = time.Now().UTC()
+
+	m.nextOutboxID++
+	m.outbox = append(m.outbox, models.OutboxMessage{ID: m.nextOutboxID, Task: task})
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (m *MemoryDB) FailExpiredTask(ctx context.Context, taskResult models.TaskResult) error {
+	const op = "memory.FailExpiredTask"
+
+	log := m.log.With(slog.String("op", op), slog.String("task_id", taskResult.ID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.tasks[taskResult.ID]
+	if !ok || !record.leaseExpired(time.Now()) {
+		return fmt.Errorf("%s task_id=%s failed to fail expired task: %w", op, taskResult.ID,
+			storage.ErrTaskLeased)
+	}
+
+	record.result.Status = taskResult.Status
+	record.result.ErrorCode = taskResult.ErrorCode
+	record.result.ErrorMessage = taskResult.ErrorMessage
+	record.leaseExpiresAt = time.Time{}
+	record.updatedAt = time.Now().UTC()
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (m *MemoryDB) UpdateTaskResult(ctx context.Context, lease models.TaskLease, taskResult models.TaskResult) error {
+	const op = "memory.UpdateTaskResult"
+
+	log := m.log.With(slog.String("op", op), slog.String("task_id", taskResult.ID))
+	log.DebugContext(ctx, "start operation")
+
+	if _, err := taskResult.RawBody(); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to decode task body: %v", op, taskResult.ID, err)
+	}
+
+	if err := m.finishLeased(lease, func(record *taskRecord) {
+		record.result.Status = models.StatusDone
+		record.result.StatusCode = taskResult.StatusCode
+		record.result.Headers = cloneHeaders(taskResult.Headers)
+		record.result.Body = taskResult.Body
+		record.result.BodyEncoding = taskResult.BodyEncoding
+		record.result.BodyRef = taskResult.BodyRef
+		record.result.ContentLength = taskResult.ContentLength
+		record.result.Truncated = taskResult.Truncated
+		record.result.OriginalContentLength = taskResult.OriginalContentLength
+		record.leaseExpiresAt = time.Time{}
+	}); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to update task result: %w", op, taskResult.ID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+// UpdateTaskError завершает выполняемую задачу с ошибкой и сохраняет её причину.
+func (m *MemoryDB) UpdateTaskError(ctx context.Context, lease models.TaskLease, taskResult models.TaskResult) error {
+	const op = "memory.UpdateTaskError"
+
+	log := m.log.With(slog.String("op", op), slog.String("task_id", taskResult.ID))
+	log.DebugContext(ctx, "start operation")
+
+	if err := m.finishLeased(lease, func(record *taskRecord) {
+		record.result.Status = taskResult.Status
+		record.result.ErrorCode = taskResult.ErrorCode
+		record.result.ErrorMessage = taskResult.ErrorMessage
+		record.leaseExpiresAt = time.Time{}
+	}); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to update task error: %w", op, taskResult.ID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (m *MemoryDB) UpdateCallbackStatus(ctx context.Context, taskID string, status models.CallbackStatus,
+	attempts int) error {
+	const op = "memory.UpdateCallbackStatus"
+
+	log := m.log.With(slog.String("op", op), slog.String("task_id", taskID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	if record, ok := m.tasks[taskID]; ok {
+		record.result.CallbackStatus = status
+		record.result.CallbackAttempts += attempts
+		record.updatedAt = time.Now().UTC()
+	}
+	m.mu.Unlock()
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (m *MemoryDB) AddTaskAttempt(ctx context.Context, attempt models.TaskAttempt) error {
+	const op = "memory.AddTaskAttempt"
+
+	log := m.log.With(slog.String("op", op), slog.String("task_id", attempt.TaskID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tasks[attempt.TaskID]; !ok {
+		return fmt.Errorf("%s task_id=%s failed to add task attempt: %w", op, attempt.TaskID, storage.ErrTaskNotFound)
+	}
+	m.attempts[attempt.TaskID] = append(m.attempts[attempt.TaskID], attempt)
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (m *MemoryDB) GetTaskAttempts(ctx context.Context, taskID string) ([]models.TaskAttempt, error) {
+	const op = "memory.GetTaskAttempts"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := m.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.RLock()
+	_, ok := m.tasks[taskID]
+	attempts := slices.Clone(m.attempts[taskID])
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%s request_id=%s task not found: %w", op, requestID, storage.ErrTaskNotFound)
+	}
+
+	if attempts == nil {
+		attempts = make([]models.TaskAttempt, 0)
+	}
+	slices.SortStableFunc(attempts, func(a, b models.TaskAttempt) int {
+		return cmp.Compare(a.Attempt, b.Attempt)
+	})
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return attempts, nil
+}
+
+func (m *MemoryDB) CancelTask(ctx context.Context, taskID string) (models.TaskStatus, error) {
+	const op = "memory.CancelTask"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := m.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.tasks[taskID]
+	if !ok {
+		return "", fmt.Errorf("%s request_id=%s task not found: %w", op, requestID, storage.ErrTaskNotFound)
+	}
+
+	prevStatus := record.result.Status
+	if prevStatus != models.StatusNew && prevStatus != models.StatusInProcess && prevStatus != models.StatusScheduled {
+		return "", fmt.Errorf("%s request_id=%s task has status %s: %w",
+			op, requestID, prevStatus, storage.ErrTaskFinalized)
+	}
+
+	record.result.Status = models.StatusCancelled
+	record.updatedAt = time.Now().UTC()
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return prevStatus, nil
+}
+
+func (m *MemoryDB) AddDeadLetter(ctx context.Context, deadLetter models.DeadLetter) error {
+	const op = "memory.AddDeadLetter"
+
+	log := m.log.With(slog.String("op", op), slog.String("dead_letter_id", deadLetter.ID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.deadLetters[deadLetter.ID]; !ok {
+		deadLetter.Payload = slices.Clone(deadLetter.Payload)
+		deadLetter.ReplayedAt = nil
+		deadLetter.ReplayCount = 0
+		m.deadLetters[deadLetter.ID] = &deadLetter
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (m *MemoryDB) ListDeadLetters(ctx context.Context, filter models.DeadLetterFilter) (models.DeadLetterList,
+	error) {
+	const op = "memory.ListDeadLetters"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := m.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	var (
+		cursorCreatedAt time.Time
+		cursorID        string
+	)
+	if filter.Cursor != "" {
+		var err error
+		if cursorCreatedAt, cursorID, err = storage.DecodeCursor(filter.Cursor); err != nil {
+			return models.DeadLetterList{}, fmt.Errorf("%s request_id=%s failed to decode cursor: %w: %v",
+				op, requestID, storage.ErrInvalidCursor, err)
+		}
+	}
+
+	// Записи отдаются от новых к старым.
+	compare := func(a models.DeadLetter, createdAt time.Time, id string) int {
+		return -cmp.Or(a.CreatedAt.Compare(createdAt), strings.Compare(a.ID, id))
+	}
+
+	m.mu.RLock()
+	deadLetters := make([]models.DeadLetter, 0)
+	for _, deadLetter := range m.deadLetters {
+		switch {
+		case filter.Reason != "" && deadLetter.Reason != filter.Reason,
+			filter.Replayed != nil && *filter.Replayed != (deadLetter.ReplayedAt != nil),
+			filter.Cursor != "" && compare(*deadLetter, cursorCreatedAt, cursorID) <= 0:
+			continue
+		}
+
+		summary := *deadLetter
+		summary.Payload = nil
+		deadLetters = append(deadLetters, summary)
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(deadLetters, func(a, b models.DeadLetter) int {
+		return compare(a, b.CreatedAt, b.ID)
+	})
+
+	deadLetterList := models.DeadLetterList{DeadLetters: deadLetters}
+	if len(deadLetterList.DeadLetters) > filter.Limit {
+		deadLetterList.DeadLetters = deadLetterList.DeadLetters[:filter.Limit]
+		last := deadLetterList.DeadLetters[len(deadLetterList.DeadLetters)-1]
+		deadLetterList.NextCursor = storage.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return deadLetterList, nil
+}
+
+func (m *MemoryDB) GetDeadLetter(ctx context.Context, id string) (models.DeadLetter, error) {
+	const op = "memory.GetDeadLetter"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := m.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	deadLetter, ok := m.deadLetters[id]
+	if !ok {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s dead letter not found: %w",
+			op, requestID, storage.ErrDeadLetterNotFound)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return *deadLetter, nil
+}
+
+func (m *MemoryDB) ReplayDeadLetter(ctx context.Context, id string) (models.DeadLetter, error) {
+	const op = "memory.ReplayDeadLetter"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := m.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deadLetter, ok := m.deadLetters[id]
+	if !ok {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s dead letter not found: %w",
+			op, requestID, storage.ErrDeadLetterNotFound)
+	}
+
+	record, ok := m.tasks[deadLetter.TaskID]
+	if !ok || !slices.Contains([]models.TaskStatus{models.StatusNew, models.StatusDone, models.StatusError},
+		record.result.Status) {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s task is missing or still active: %w",
+			op, requestID, storage.ErrNotReplayable)
+	}
+
+	record.result.Status = models.StatusNew
+	record.result.ErrorCode = ""
+	record.result.ErrorMessage = ""
+	record.result.CallbackStatus = models.CallbackNone
+	if record.task.CallbackURL != "" {
+		record.result.CallbackStatus = models.CallbackPending
+	}
+	record.result.CallbackAttempts = 0
+	record.task.Attempt = 1
+	record.task.Replay++
+	record.workerID = ""
+	record.attempt = 0
+	record.leaseExpiresAt = time.Time{}
+	record.updatedAt = time.Now().UTC()
+
+	m.nextOutboxID++
+	m.outbox = append(m.outbox, models.OutboxMessage{ID: m.nextOutboxID, RequestID: requestID, Task: record.task})
+
+	replayedAt := time.Now().UTC()
+	deadLetter.ReplayedAt = &replayedAt
+	deadLetter.ReplayCount++
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return *deadLetter, nil
+}
+
+func (m *MemoryDB) Close(_ context.Context) error {
+	return nil
+}
+
+// update применяет apply к задаче, если она находится в одном из статусов allowed.
+// Для отсутствующей или уже завершённой задачи возвращается storage.ErrTaskFinalized, как и в postgres.
+func (m *MemoryDB) update(taskID string, apply func(record *taskRecord), allowed ...models.TaskStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.tasks[taskID]
+	if !ok || !slices.Contains(allowed, record.result.Status) {
+		return storage.ErrTaskFinalized
+	}
+
+	apply(record)
+	record.updatedAt = time.Now().UTC()
+
+	return nil
+}
+
+func (m *MemoryDB) finishLeased(lease models.TaskLease, apply func(record *taskRecord)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.tasks[lease.TaskID]
+	if !ok || record.result.Status != models.StatusInProcess {
+		return storage.ErrTaskFinalized
+	}
+	if record.workerID != lease.WorkerID || record.attempt != lease.Attempt {
+		return storage.ErrTaskLeased
+	}
+
+	apply(record)
+	record.updatedAt = time.Now().UTC()
+
+	return nil
+}
+
+func (m *MemoryDB) updateLease(taskID, workerID string, apply func(record *taskRecord)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.tasks[taskID]
+	if !ok || record.result.Status != models.StatusInProcess || record.workerID != workerID {
+		return storage.ErrTaskLeased
+	}
+
+	apply(record)
+
+	return nil
+}
+
+func (r *taskRecord) leaseExpired(now time.Time) bool {
+	return r.result.Status == models.StatusInProcess && !r.leaseExpiresAt.IsZero() && r.leaseExpiresAt.Before(now)
+}
+
+func (r *taskRecord) matches(filter models.TaskFilter) bool {
+	switch {
+	case filter.Status != "" && r.result.Status != filter.Status:
+		return false
+	case filter.Host != "" && r.host != strings.ToLower(filter.Host):
+		return false
+	case filter.Method != "" && r.method != filter.Method:
+		return false
+	case filter.StatusCode != 0 && r.result.StatusCode != filter.StatusCode:
+		return false
+	case !filter.CreatedFrom.IsZero() && r.createdAt.Before(filter.CreatedFrom):
+		return false
+	case !filter.CreatedTo.IsZero() && !r.createdAt.Before(filter.CreatedTo):
+		return false
+	}
+
+	return true
+}
+
+func (r *taskRecord) summary() models.TaskSummary {
+	return models.TaskSummary{
+		ID:            r.result.ID,
+		Status:        r.result.Status,
+		Method:        r.method,
+		URL:           r.url,
+		StatusCode:    r.result.StatusCode,
+		ContentLength: r.result.ContentLength,
+		CreatedAt:     r.createdAt,
+		UpdatedAt:     r.updatedAt,
+	}
+}
+
+func cloneHeaders(headers models.Headers) models.Headers {
+	cloned := make(models.Headers, len(headers))
+	for key, values := range headers {
+		cloned[key] = slices.Clone(values)
+	}
+
+	return cloned
+}
@@ -0,0 +1,606 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/ASsssker/proxy/internal/services"
+	"github.com/ASsssker/proxy/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryDB_TaskLifecycle(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://Example.com/path", Method: "GET",
+		CallbackURL: "http://example.com/callback"}
+	require.NoError(t, db.AddTask(ctx, task))
+
+	taskResult, err := db.GetTask(ctx, task.ID, false)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusNew, taskResult.Status)
+	require.Equal(t, models.CallbackPending, taskResult.CallbackStatus)
+
+	// Результат сохраняется только для выполняемой задачи.
+	lease := models.TaskLease{TaskID: task.ID, WorkerID: "worker-1", Attempt: 1, TTL: time.Minute}
+	err = db.UpdateTaskResult(ctx, lease, models.TaskResult{ID: task.ID, StatusCode: 200})
+	require.ErrorIs(t, err, storage.ErrTaskFinalized)
+
+	require.NoError(t, db.AcquireTaskLease(ctx, lease))
+	require.NoError(t, db.UpdateTaskResult(ctx, lease, models.TaskResult{
+		ID:            task.ID,
+		StatusCode:    200,
+		Headers:       models.Headers{"Content-Type": {"text/plain"}},
+		Body:          "hello",
+		ContentLength: 5,
+	}))
+
+	taskResult, err = db.GetTask(ctx, task.ID, false)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusDone, taskResult.Status)
+	require.Equal(t, "hello", taskResult.Body)
+	require.Equal(t, models.Headers{"Content-Type": {"text/plain"}}, taskResult.Headers)
+
+	taskResult, err = db.GetTask(ctx, task.ID, true)
+	require.NoError(t, err)
+	require.Empty(t, taskResult.Body)
+
+	taskBody, err := db.GetTaskBody(ctx, task.ID)
+	require.NoError(t, err)
+	body, err := io.ReadAll(taskBody.Content)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(body))
+
+	require.ErrorIs(t, db.UpdateTaskStatus(ctx, task.ID, models.StatusError), storage.ErrTaskFinalized)
+	_, err = db.CancelTask(ctx, task.ID)
+	require.ErrorIs(t, err, storage.ErrTaskFinalized)
+
+	_, err = db.GetTask(ctx, uuid.NewString(), false)
+	require.ErrorIs(t, err, storage.ErrTaskNotFound)
+}
+
+func TestMemoryDB_CancelTask(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET"}
+	require.NoError(t, db.AddTask(ctx, task))
+	lease := models.TaskLease{TaskID: task.ID, WorkerID: "worker-1", Attempt: 1, TTL: time.Minute}
+	require.NoError(t, db.AcquireTaskLease(ctx, lease))
+
+	prevStatus, err := db.CancelTask(ctx, task.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusInProcess, prevStatus)
+
+	// Ошибка выполнения не перезаписывает отмену.
+	err = db.UpdateTaskError(ctx, lease, models.TaskResult{ID: task.ID, Status: models.StatusError,
+		ErrorCode: models.ErrorReadTimeout})
+	require.ErrorIs(t, err, storage.ErrTaskFinalized)
+
+	taskResult, err := db.GetTask(ctx, task.ID, true)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusCancelled, taskResult.Status)
+	require.Empty(t, taskResult.ErrorCode)
+
+	_, err = db.CancelTask(ctx, uuid.NewString())
+	require.ErrorIs(t, err, storage.ErrTaskNotFound)
+}
+
+func TestMemoryDB_ListTasks(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	ids := make([]string, 0, 5)
+	for range 5 {
+		task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET"}
+		require.NoError(t, db.AddTask(ctx, task))
+		ids = append(ids, task.ID)
+	}
+	require.NoError(t, db.AddTask(ctx, models.Task{ID: uuid.NewString(), URL: "http://other.com", Method: "POST"}))
+
+	for _, order := range []models.SortOrder{models.SortAsc, models.SortDesc} {
+		filter := models.TaskFilter{Host: "EXAMPLE.com", Method: "GET", Order: order, Limit: 2}
+
+		var listed []models.TaskSummary
+		for {
+			taskList, err := db.ListTasks(ctx, filter)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(taskList.Tasks), filter.Limit)
+			listed = append(listed, taskList.Tasks...)
+
+			if taskList.NextCursor == "" {
+				break
+			}
+			filter.Cursor = taskList.NextCursor
+		}
+
+		require.Len(t, listed, len(ids))
+		for i := 1; i < len(listed); i++ {
+			if order == models.SortAsc {
+				require.False(t, listed[i].CreatedAt.Before(listed[i-1].CreatedAt))
+			} else {
+				require.False(t, listed[i].CreatedAt.After(listed[i-1].CreatedAt))
+			}
+		}
+	}
+
+	_, err := db.ListTasks(ctx, models.TaskFilter{Order: models.SortAsc, Limit: 10, Cursor: "invalid"})
+	require.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+func TestMemoryDB_GetTaskAttempts(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET"}
+	require.NoError(t, db.AddTask(ctx, task))
+
+	attempts, err := db.GetTaskAttempts(ctx, task.ID)
+	require.NoError(t, err)
+	require.Empty(t, attempts)
+
+	require.NoError(t, db.AddTaskAttempt(ctx, models.TaskAttempt{TaskID: task.ID, Attempt: 2, StatusCode: 200}))
+	require.NoError(t, db.AddTaskAttempt(ctx, models.TaskAttempt{TaskID: task.ID, Attempt: 1,
+		ErrorCode: models.ErrorReadTimeout}))
+
+	attempts, err = db.GetTaskAttempts(ctx, task.ID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+	require.Equal(t, 1, attempts[0].Attempt)
+	require.Equal(t, 2, attempts[1].Attempt)
+
+	_, err = db.GetTaskAttempts(ctx, uuid.NewString())
+	require.ErrorIs(t, err, storage.ErrTaskNotFound)
+}
+
+func TestMemoryDB_PublishOutbox(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	tasks := make([]models.Task, 0, 3)
+	for range 3 {
+		task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET"}
+		require.NoError(t, db.AddTask(ctx, task))
+		tasks = append(tasks, task)
+	}
+
+	var published []string
+	publish := func(_ context.Context, msgs []models.OutboxMessage) []error {
+		errs := make([]error, len(msgs))
+		for i, msg := range msgs {
+			require.Equal(t, ctx.Value(services.RequestIDKey), msg.RequestID)
+			if msg.Task.ID == tasks[0].ID && len(published) == 0 {
+				published = append(published, "")
+				errs[i] = errors.New("broker unavailable")
+				continue
+			}
+			published = append(published, msg.Task.ID)
+		}
+		return errs
+	}
+
+	count, err := db.PublishOutbox(ctx, 2, 10, publish)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// Неопубликованная задача остаётся в outbox и публикуется следующим вызовом.
+	count, err = db.PublishOutbox(ctx, 10, 10, publish)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Equal(t, []string{"", tasks[1].ID, tasks[0].ID, tasks[2].ID}, published)
+
+	count, err = db.PublishOutbox(ctx, 10, 10, publish)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestMemoryDB_PublishOutboxMaxAttempts(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET", CallbackSecret: "secret"}
+	require.NoError(t, db.AddTask(ctx, task))
+
+	publish := func(_ context.Context, msgs []models.OutboxMessage) []error {
+		return []error{errors.New("broker unavailable")}
+	}
+
+	for range 2 {
+		count, err := db.PublishOutbox(ctx, 10, 3, publish)
+		require.NoError(t, err)
+		require.Zero(t, count)
+	}
+
+	taskResult, err := db.GetTask(ctx, task.ID, true)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusNew, taskResult.Status)
+
+	// Последняя попытка переносит задачу в dead letters и завершает её ошибкой.
+	_, err = db.PublishOutbox(ctx, 10, 3, publish)
+	require.NoError(t, err)
+
+	taskResult, err = db.GetTask(ctx, task.ID, true)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusError, taskResult.Status)
+
+	deadLetters, err := db.ListDeadLetters(ctx, models.DeadLetterFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deadLetters.DeadLetters, 1)
+	require.Equal(t, models.DeadLetterPublishFailed, deadLetters.DeadLetters[0].Reason)
+
+	deadLetter, err := db.GetDeadLetter(ctx, deadLetters.DeadLetters[0].ID)
+	require.NoError(t, err)
+	require.NotContains(t, string(deadLetter.Payload), "secret")
+
+	_, err = db.PublishOutbox(ctx, 10, 3, func(_ context.Context, msgs []models.OutboxMessage) []error {
+		require.FailNow(t, "dead-lettered task published again")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestMemoryDB_TaskLease(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET", Attempt: 1}
+	require.NoError(t, db.AddTask(ctx, task))
+
+	lease := models.TaskLease{TaskID: task.ID, WorkerID: "worker-1", Attempt: 1, TTL: time.Minute}
+	require.NoError(t, db.AcquireTaskLease(ctx, lease))
+	require.NoError(t, db.ExtendTaskLease(ctx, lease))
+
+	// Пока аренда действует, другой requester не может взять задачу.
+	otherLease := models.TaskLease{TaskID: task.ID, WorkerID: "worker-2", Attempt: 1, TTL: time.Minute}
+	require.ErrorIs(t, db.AcquireTaskLease(ctx, otherLease), storage.ErrTaskLeased)
+	require.ErrorIs(t, db.ExtendTaskLease(ctx, otherLease), storage.ErrTaskLeased)
+
+	expired, err := db.ListExpiredTasks(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, expired)
+	require.ErrorIs(t, db.RequeueExpiredTask(ctx, task), storage.ErrTaskLeased)
+
+	// Упавший requester перестаёт продлевать аренду.
+	lease.TTL = -time.Second
+	require.NoError(t, db.ExtendTaskLease(ctx, lease))
+
+	expired, err = db.ListExpiredTasks(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, expired, 1)
+	require.Equal(t, task.ID, expired[0].Task.ID)
+	require.Equal(t, "worker-1", expired[0].WorkerID)
+	require.Equal(t, 1, expired[0].Attempt)
+
+	task.Attempt = 2
+	require.NoError(t, db.RequeueExpiredTask(ctx, task))
+	require.ErrorIs(t, db.ExtendTaskLease(ctx, lease), storage.ErrTaskLeased)
+
+	taskResult, err := db.GetTask(ctx, task.ID, true)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusNew, taskResult.Status)
+
+	var requeued []models.Task
+	_, err = db.PublishOutbox(ctx, 10, 10, func(_ context.Context, msgs []models.OutboxMessage) []error {
+		for _, msg := range msgs {
+			requeued = append(requeued, msg.Task)
+		}
+		return make([]error, len(msgs))
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, requeued[len(requeued)-1].Attempt)
+
+	// Повторы исчерпаны, reaper завершает задачу с ошибкой.
+	otherLease.Attempt, otherLease.TTL = 2, -time.Second
+	require.NoError(t, db.AcquireTaskLease(ctx, otherLease))
+	require.NoError(t, db.FailExpiredTask(ctx, models.TaskResult{ID: task.ID, Status: models.StatusError,
+		ErrorCode: models.ErrorWorkerLost}))
+
+	taskResult, err = db.GetTask(ctx, task.ID, true)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusError, taskResult.Status)
+	require.Equal(t, models.ErrorWorkerLost, taskResult.ErrorCode)
+
+	expired, err = db.ListExpiredTasks(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, expired)
+}
+
+func newContextWithRequestID() context.Context {
+	return context.WithValue(context.Background(), services.RequestIDKey, uuid.NewString())
+}
+
+func TestMemoryDB_TaskLeaseFencing(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET", Attempt: 1}
+	require.NoError(t, db.AddTask(ctx, task))
+
+	lease := models.TaskLease{TaskID: task.ID, WorkerID: "worker-1", Attempt: 1, TTL: time.Minute}
+	require.NoError(t, db.AcquireTaskLease(ctx, lease))
+	// Повторная доставка той же попытки не берётся, пока её выполняет тот же requester.
+	require.ErrorIs(t, db.AcquireTaskLease(ctx, lease), storage.ErrTaskLeased)
+
+	lease.TTL = -time.Second
+	require.NoError(t, db.ExtendTaskLease(ctx, lease))
+	task.Attempt = 2
+	require.NoError(t, db.RequeueExpiredTask(ctx, task))
+
+	otherLease := models.TaskLease{TaskID: task.ID, WorkerID: "worker-2", Attempt: 2, TTL: time.Minute}
+	require.NoError(t, db.AcquireTaskLease(ctx, otherLease))
+
+	// Requester с истёкшей арендой не перезаписывает результат того, кто держит аренду сейчас.
+	err := db.UpdateTaskResult(ctx, lease, models.TaskResult{ID: task.ID, StatusCode: 200})
+	require.ErrorIs(t, err, storage.ErrTaskLeased)
+	err = db.UpdateTaskError(ctx, lease, models.TaskResult{ID: task.ID, Status: models.StatusError,
+		ErrorCode: models.ErrorConnection})
+	require.ErrorIs(t, err, storage.ErrTaskLeased)
+
+	require.NoError(t, db.UpdateTaskResult(ctx, otherLease, models.TaskResult{ID: task.ID, StatusCode: 200}))
+
+	taskResult, err := db.GetTask(ctx, task.ID, true)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusDone, taskResult.Status)
+	require.Equal(t, 200, taskResult.StatusCode)
+}
+
+func TestMemoryDB_DeadLetters(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET", Attempt: 3}
+	require.NoError(t, db.AddTask(ctx, task))
+	_, err := db.PublishOutbox(ctx, 10, 10, func(_ context.Context, msgs []models.OutboxMessage) []error {
+		return make([]error, len(msgs))
+	})
+	require.NoError(t, err)
+	lease := models.TaskLease{TaskID: task.ID, WorkerID: "worker-1", Attempt: 3, TTL: time.Minute}
+	require.NoError(t, db.AcquireTaskLease(ctx, lease))
+	require.NoError(t, db.UpdateTaskError(ctx, lease, models.TaskResult{ID: task.ID, Status: models.StatusError,
+		ErrorCode: models.ErrorConnection}))
+
+	payload, err := json.Marshal(task)
+	require.NoError(t, err)
+	taskLetter := models.NewDeadLetter(task.ID, models.DeadLetterRetriesExhausted, "connection refused", payload)
+	invalidLetter := models.NewDeadLetter("", models.DeadLetterInvalidMessage, "bad json", []byte("{"))
+	require.NoError(t, db.AddDeadLetter(ctx, taskLetter))
+	require.NoError(t, db.AddDeadLetter(ctx, invalidLetter))
+	// Повторная доставка той же записи не создаёт дубликат.
+	require.NoError(t, db.AddDeadLetter(ctx, taskLetter))
+
+	deadLetterList, err := db.ListDeadLetters(ctx, models.DeadLetterFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deadLetterList.DeadLetters, 2)
+	for _, deadLetter := range deadLetterList.DeadLetters {
+		require.Nil(t, deadLetter.Payload)
+	}
+
+	deadLetter, err := db.GetDeadLetter(ctx, taskLetter.ID)
+	require.NoError(t, err)
+	require.Equal(t, payload, deadLetter.Payload)
+
+	_, err = db.ReplayDeadLetter(ctx, invalidLetter.ID)
+	require.ErrorIs(t, err, storage.ErrNotReplayable)
+
+	deadLetter, err = db.ReplayDeadLetter(ctx, taskLetter.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, deadLetter.ReplayCount)
+	require.NotNil(t, deadLetter.ReplayedAt)
+
+	taskResult, err := db.GetTask(ctx, task.ID, true)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusNew, taskResult.Status)
+	require.Empty(t, taskResult.ErrorCode)
+
+	// Задача снова попадает в outbox с новым бюджетом попыток.
+	var published []models.Task
+	_, err = db.PublishOutbox(ctx, 10, 10, func(_ context.Context, msgs []models.OutboxMessage) []error {
+		for _, msg := range msgs {
+			published = append(published, msg.Task)
+		}
+		return make([]error, len(msgs))
+	})
+	require.NoError(t, err)
+	require.Len(t, published, 1)
+	require.Equal(t, 1, published[0].Attempt)
+
+	replayed := false
+	deadLetterList, err = db.ListDeadLetters(ctx, models.DeadLetterFilter{Replayed: &replayed, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deadLetterList.DeadLetters, 1)
+	require.Equal(t, invalidLetter.ID, deadLetterList.DeadLetters[0].ID)
+
+	_, err = db.GetDeadLetter(ctx, uuid.NewString())
+	require.ErrorIs(t, err, storage.ErrDeadLetterNotFound)
+}
+
+func TestMemoryDB_ReplayDeadLetterTwice(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET",
+		CallbackURL: "http://example.com/callback", CallbackSecret: "task-secret"}
+	require.NoError(t, db.AddTask(ctx, task))
+
+	var published []models.Task
+	publish := func(_ context.Context, msgs []models.OutboxMessage) []error {
+		for _, msg := range msgs {
+			published = append(published, msg.Task)
+		}
+		return make([]error, len(msgs))
+	}
+	_, err := db.PublishOutbox(ctx, 10, 10, publish)
+	require.NoError(t, err)
+
+	// Payload записи приходит без секрета, повторный запуск должен сохранить секрет задачи.
+	redacted := task
+	redacted.CallbackSecret = ""
+	payload, err := json.Marshal(redacted)
+	require.NoError(t, err)
+	deadLetter := models.NewDeadLetter(task.ID, models.DeadLetterRetriesExhausted, "connection refused", payload)
+	require.NoError(t, db.AddDeadLetter(ctx, deadLetter))
+
+	for range 2 {
+		lease := models.TaskLease{TaskID: task.ID, WorkerID: "worker-1", Attempt: 1, TTL: time.Minute}
+		require.NoError(t, db.AcquireTaskLease(ctx, lease))
+		require.NoError(t, db.UpdateTaskError(ctx, lease, models.TaskResult{ID: task.ID, Status: models.StatusError,
+			ErrorCode: models.ErrorConnection}))
+
+		_, err := db.ReplayDeadLetter(ctx, deadLetter.ID)
+		require.NoError(t, err)
+		_, err = db.PublishOutbox(ctx, 10, 10, publish)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, published, 3)
+	for i, replayed := range published[1:] {
+		require.Equal(t, i+1, replayed.Replay)
+		require.Equal(t, 1, replayed.Attempt)
+		require.Equal(t, task.CallbackSecret, replayed.CallbackSecret)
+	}
+}
+
+func TestMemoryDB_AddIdempotentTask(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	key := models.IdempotencyKey{Key: "key-1", Fingerprint: "fingerprint", ExpiresAt: time.Now().Add(time.Hour)}
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "POST"}
+	stored, err := db.AddIdempotentTask(ctx, task, key)
+	require.NoError(t, err)
+	require.Equal(t, task.ID, stored.TaskID)
+
+	// Повтор с действующим ключом не создаёт задачу.
+	repeated := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "POST"}
+	stored, err = db.AddIdempotentTask(ctx, repeated, key)
+	require.NoError(t, err)
+	require.Equal(t, task.ID, stored.TaskID)
+	_, err = db.GetTask(ctx, repeated.ID, true)
+	require.ErrorIs(t, err, storage.ErrTaskNotFound)
+
+	// Истёкший ключ закрепляется за новой задачей.
+	db.idempotency[key.Key] = models.IdempotencyKey{Key: key.Key, TaskID: task.ID, ExpiresAt: time.Now()}
+	stored, err = db.AddIdempotentTask(ctx, repeated, key)
+	require.NoError(t, err)
+	require.Equal(t, repeated.ID, stored.TaskID)
+
+	// Очистка удаляет только истёкшие ключи.
+	expired := models.IdempotencyKey{Key: "key-2", Fingerprint: "fingerprint", ExpiresAt: time.Now()}
+	_, err = db.AddIdempotentTask(ctx, models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "POST"},
+		expired)
+	require.NoError(t, err)
+
+	deleted, err := db.DeleteExpiredIdempotencyKeys(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted)
+	require.Contains(t, db.idempotency, key.Key)
+	require.NotContains(t, db.idempotency, expired.Key)
+}
+
+func TestMemoryDB_AddTasks(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	existing := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET"}
+	require.NoError(t, db.AddTask(ctx, existing))
+
+	tasks := []models.Task{
+		{ID: uuid.NewString(), URL: "http://example.com/1", Method: "GET"},
+		{ID: uuid.NewString(), URL: "http://example.com/2", Method: "POST"},
+	}
+
+	// Пачка с уже существующей задачей не сохраняется целиком.
+	require.Error(t, db.AddTasks(ctx, append(slices.Clone(tasks), existing)))
+	_, err := db.GetTask(ctx, tasks[0].ID, true)
+	require.ErrorIs(t, err, storage.ErrTaskNotFound)
+
+	require.NoError(t, db.AddTasks(ctx, tasks))
+	for _, task := range tasks {
+		taskResult, err := db.GetTask(ctx, task.ID, true)
+		require.NoError(t, err)
+		require.Equal(t, models.StatusNew, taskResult.Status)
+	}
+
+	var published []string
+	count, err := db.PublishOutbox(ctx, 10, 10, func(_ context.Context, msgs []models.OutboxMessage) []error {
+		for _, msg := range msgs {
+			published = append(published, msg.Task.ID)
+		}
+		return make([]error, len(msgs))
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	require.Equal(t, []string{existing.ID, tasks[0].ID, tasks[1].ID}, published)
+}
+
+func TestMemoryDB_PromoteScheduledTasks(t *testing.T) {
+	ctx := newContextWithRequestID()
+	db := NewMemoryDB(slog.New(slog.DiscardHandler), nil)
+
+	past := time.Now().Add(-time.Minute).UTC()
+	earlier := past.Add(-time.Minute)
+	future := time.Now().Add(time.Hour).UTC()
+	tasks := []models.Task{
+		{ID: uuid.NewString(), URL: "http://example.com/1", Method: "GET", RunAt: &past},
+		{ID: uuid.NewString(), URL: "http://example.com/2", Method: "GET", RunAt: &earlier},
+		{ID: uuid.NewString(), URL: "http://example.com/3", Method: "GET", RunAt: &future},
+		{ID: uuid.NewString(), URL: "http://example.com/4", Method: "GET", RunAt: &past},
+	}
+	require.NoError(t, db.AddTasks(ctx, tasks))
+
+	taskResult, err := db.GetTask(ctx, tasks[0].ID, true)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusScheduled, taskResult.Status)
+	require.Equal(t, &past, taskResult.RunAt)
+
+	// Отложенные задачи не попадают в outbox до времени запуска.
+	publish := func(_ context.Context, msgs []models.OutboxMessage) []error { return make([]error, len(msgs)) }
+	count, err := db.PublishOutbox(ctx, 10, 10, publish)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// Отменённая до запуска задача удаляется из очереди отложенных, но не переносится в outbox.
+	_, err = db.CancelTask(ctx, tasks[3].ID)
+	require.NoError(t, err)
+
+	// Наступившие задачи переносятся в порядке времени запуска.
+	promoted, err := db.PromoteScheduledTasks(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, promoted)
+
+	taskResult, err = db.GetTask(ctx, tasks[1].ID, true)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusNew, taskResult.Status)
+
+	promoted, err = db.PromoteScheduledTasks(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, promoted)
+
+	promoted, err = db.PromoteScheduledTasks(ctx, 10)
+	require.NoError(t, err)
+	require.Zero(t, promoted)
+
+	var published []string
+	count, err = db.PublishOutbox(ctx, 10, 10, func(_ context.Context, msgs []models.OutboxMessage) []error {
+		for _, msg := range msgs {
+			published = append(published, msg.Task.ID)
+		}
+		return make([]error, len(msgs))
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Equal(t, []string{tasks[1].ID, tasks[0].ID}, published)
+
+	taskResult, err = db.GetTask(ctx, tasks[2].ID, true)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusScheduled, taskResult.Status)
+}
@@ -3,9 +3,13 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"strconv"
+	"strings"
 
 	"github.com/ASsssker/proxy/internal/models"
 	"github.com/ASsssker/proxy/internal/services"
@@ -14,11 +18,15 @@ import (
 )
 
 type PostgresDB struct {
-	db  *sql.DB
-	log *slog.Logger
+	db        *sql.DB
+	log       *slog.Logger
+	blobStore storage.BlobStore
 }
 
-func NewPostgresDB(ctx context.Context, log *slog.Logger, dns string) (*PostgresDB, error) {
+// NewPostgresDB подключается к postgres. blobStore нужен для чтения тел ответов, вынесенных
+// из таблицы задач, и может быть nil, если внешнее хранилище не используется.
+func NewPostgresDB(ctx context.Context, log *slog.Logger, dns string, blobStore storage.BlobStore) (*PostgresDB,
+	error) {
 	db, err := sql.Open("postgres", dns)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open postgres connection: %v", err)
@@ -28,28 +36,49 @@ func NewPostgresDB(ctx context.Context, log *slog.Logger, dns string) (*Postgres
 		return nil, fmt.Errorf("failed to ping postgres: %v", err)
 	}
 
-	return &PostgresDB{db: db, log: log}, nil
+	return &PostgresDB{db: db, log: log, blobStore: blobStore}, nil
 }
 
-func (p PostgresDB) GetTask(ctx context.Context, taskID string) (models.TaskResult, error) {
+func (p PostgresDB) GetTask(ctx context.Context, taskID string, omitBody bool) (models.TaskResult, error) {
 	const op = "postgres.GetTask"
 	requestID := ctx.Value(services.RequestIDKey).(string)
 
 	log := p.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
 	log.DebugContext(ctx, "start operation")
 
-	stmt := `SELECT id, status, status_code, headers, body, content_length FROM tasks
+	bodyColumn := "body"
+	if omitBody {
+		bodyColumn = "NULL::BYTEA"
+	}
+
+	stmt := `SELECT id, status, status_code, headers, ` + bodyColumn + `, body_encoding, body_ref, content_length,
+				truncated, original_content_length, error_code, error_message, callback_status, callback_attempts,
+				run_at
+			FROM tasks
 			WHERE id = $1`
 
-	taskResult := models.TaskResult{Headers: map[string]string{}}
-	var status string
+	taskResult := models.TaskResult{Headers: models.Headers{}}
+	var (
+		status, bodyEncoding, errorCode, callbackStatus string
+		body                                            []byte
+		runAt                                           sql.NullTime
+	)
 	if err := p.db.QueryRowContext(ctx, stmt, taskID).Scan(
 		&taskResult.ID,
 		&status,
 		&taskResult.StatusCode,
 		&taskResult.Headers,
-		&taskResult.Body,
+		&body,
+		&bodyEncoding,
+		&taskResult.BodyRef,
 		&taskResult.ContentLength,
+		&taskResult.Truncated,
+		&taskResult.OriginalContentLength,
+		&errorCode,
+		&taskResult.ErrorMessage,
+		&callbackStatus,
+		&taskResult.CallbackAttempts,
+		&runAt,
 	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.TaskResult{}, fmt.Errorf("%s request_id=%s task not found: %w: %v",
@@ -60,45 +89,218 @@ func (p PostgresDB) GetTask(ctx context.Context, taskID string) (models.TaskResu
 			op, requestID, err)
 	}
 
+	if taskResult.BodyRef != "" && !omitBody {
+		var err error
+		if body, err = p.readBlob(ctx, taskResult.BodyRef); err != nil {
+			return models.TaskResult{}, fmt.Errorf("%s request_id=%s failed to read task body: %v",
+				op, requestID, err)
+		}
+	}
+
 	taskResult.Status = models.TaskStatus(status)
+	taskResult.BodyEncoding = models.BodyEncoding(bodyEncoding)
+	taskResult.Body = models.EncodeBody(body, taskResult.BodyEncoding)
+	taskResult.ErrorCode = models.ErrorCode(errorCode)
+	taskResult.CallbackStatus = models.CallbackStatus(callbackStatus)
+	if runAt.Valid {
+		taskResult.RunAt = &runAt.Time
+	}
 
 	log.DebugContext(ctx, "the operation was successfully completed")
 
 	return taskResult, nil
 }
 
-func (p PostgresDB) AddTask(ctx context.Context, taskID string) error {
-	const op = "postgres.AddTask"
+func (p PostgresDB) GetTaskBody(ctx context.Context, taskID string) (models.TaskBody, error) {
+	const op = "postgres.GetTaskBody"
 	requestID := ctx.Value(services.RequestIDKey).(string)
 
 	log := p.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
 	log.DebugContext(ctx, "start operation")
 
-	stmt := `INSERT INTO tasks (id, status)
-			VALUES($1, $2)`
+	stmt := `SELECT status, headers, body, body_ref, updated_at
+			FROM tasks
+			WHERE id = $1`
+
+	taskBody := models.TaskBody{Headers: models.Headers{}}
+	var (
+		status, bodyRef string
+		body            []byte
+	)
+	if err := p.db.QueryRowContext(ctx, stmt, taskID).Scan(
+		&status,
+		&taskBody.Headers,
+		&body,
+		&bodyRef,
+		&taskBody.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.TaskBody{}, fmt.Errorf("%s request_id=%s task not found: %w: %v",
+				op, requestID, storage.ErrTaskNotFound, err)
+		}
 
-	if _, err := p.db.ExecContext(ctx, stmt, taskID, models.StatusNew); err != nil {
-		return fmt.Errorf("%s request_id=%s failed to add new task: %v",
+		return models.TaskBody{}, fmt.Errorf("%s request_id=%s failed to get task body: %v",
 			op, requestID, err)
 	}
 
+	taskBody.Status = models.TaskStatus(status)
+	taskBody.Content = storage.NewBytesReader(body)
+
+	if bodyRef != "" {
+		content, err := p.openBlob(ctx, bodyRef)
+		if err != nil {
+			return models.TaskBody{}, fmt.Errorf("%s request_id=%s failed to open task body: %v",
+				op, requestID, err)
+		}
+		taskBody.Content = content
+	}
+
 	log.DebugContext(ctx, "the operation was successfully completed")
 
-	return nil
+	return taskBody, nil
 }
 
-func (p PostgresDB) UpdateTaskStatus(ctx context.Context, taskID string, newStatus models.TaskStatus) error {
-	const op = "postgres.UpdateTaskStatus"
+func (p PostgresDB) openBlob(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if p.blobStore == nil {
+		return nil, errors.New("task body is stored externally, but blob store is not configured")
+	}
 
-	log := p.log.With(slog.String("op", op), slog.String("task_id", taskID))
+	return p.blobStore.Get(ctx, key)
+}
+
+func (p PostgresDB) readBlob(ctx context.Context, key string) ([]byte, error) {
+	content, err := p.openBlob(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	return io.ReadAll(content)
+}
+
+func (p PostgresDB) ListTasks(ctx context.Context, filter models.TaskFilter) (models.TaskList, error) {
+	const op = "postgres.ListTasks"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := p.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
 	log.DebugContext(ctx, "start operation")
 
-	stmt := `UPDATE tasks
-			SET status = $1
-			WHERE id = $2`
+	var (
+		conditions []string
+		args       []any
+	)
+	addCondition := func(condition string, values ...any) {
+		placeholders := make([]any, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
 
-	if _, err := p.db.ExecContext(ctx, stmt, string(newStatus), taskID); err != nil {
-		return fmt.Errorf("%s task_id=%s failed to update task status: %v", op, taskID, err)
+	if filter.Status != "" {
+		addCondition("status = $%d", string(filter.Status))
+	}
+	if filter.Host != "" {
+		addCondition("host = $%d", strings.ToLower(filter.Host))
+	}
+	if filter.Method != "" {
+		addCondition("method = $%d", filter.Method)
+	}
+	if filter.StatusCode != 0 {
+		addCondition("status_code = $%d", filter.StatusCode)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		addCondition("created_at >= $%d", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		addCondition("created_at < $%d", filter.CreatedTo)
+	}
+
+	comparison, direction := ">", "ASC"
+	if filter.Order == models.SortDesc {
+		comparison, direction = "<", "DESC"
+	}
+
+	if filter.Cursor != "" {
+		createdAt, id, err := storage.DecodeCursor(filter.Cursor)
+		if err != nil {
+			return models.TaskList{}, fmt.Errorf("%s request_id=%s failed to decode cursor: %w: %v",
+				op, requestID, storage.ErrInvalidCursor, err)
+		}
+		addCondition("(created_at, id) "+comparison+" ($%d, $%d)", createdAt, id)
+	}
+
+	stmt := `SELECT id, status, method, url, status_code, content_length, created_at, updated_at FROM tasks`
+	if len(conditions) > 0 {
+		stmt += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit+1)
+	stmt += fmt.Sprintf(" ORDER BY created_at %[1]s, id %[1]s LIMIT $%[2]d", direction, len(args))
+
+	rows, err := p.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return models.TaskList{}, fmt.Errorf("%s request_id=%s failed to list tasks: %v", op, requestID, err)
+	}
+	defer rows.Close()
+
+	taskList := models.TaskList{Tasks: make([]models.TaskSummary, 0, filter.Limit)}
+	for rows.Next() {
+		var (
+			task   models.TaskSummary
+			status string
+		)
+		if err := rows.Scan(
+			&task.ID,
+			&status,
+			&task.Method,
+			&task.URL,
+			&task.StatusCode,
+			&task.ContentLength,
+			&task.CreatedAt,
+			&task.UpdatedAt,
+		); err != nil {
+			return models.TaskList{}, fmt.Errorf("%s request_id=%s failed to scan task: %v", op, requestID, err)
+		}
+
+		task.Status = models.TaskStatus(status)
+		taskList.Tasks = append(taskList.Tasks, task)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.TaskList{}, fmt.Errorf("%s request_id=%s failed to list tasks: %v", op, requestID, err)
+	}
+
+	if len(taskList.Tasks) > filter.Limit {
+		taskList.Tasks = taskList.Tasks[:filter.Limit]
+		last := taskList.Tasks[len(taskList.Tasks)-1]
+		taskList.NextCursor = storage.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return taskList, nil
+}
+
+func (p PostgresDB) AddTask(ctx context.Context, task models.Task) error {
+	const op = "postgres.AddTask"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := p.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s request_id=%s failed to begin transaction: %v", op, requestID, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := insertTask(ctx, tx, task, requestID); err != nil {
+		return fmt.Errorf("%s request_id=%s %v", op, requestID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s request_id=%s failed to commit transaction: %v", op, requestID, err)
 	}
 
 	log.DebugContext(ctx, "the operation was successfully completed")
@@ -106,36 +308,1181 @@ func (p PostgresDB) UpdateTaskStatus(ctx context.Context, taskID string, newStat
 	return nil
 }
 
-func (p PostgresDB) UpdateTaskResult(ctx context.Context, taskResult models.TaskResult) error {
-	const op = "postgres.UpdateTaskResult"
+// AddIdempotentTask возвращает сохранённый ключ, если действующий ключ уже есть. Одновременные запросы
+// с одним ключом ждут друг друга на вставке ключа.
+func (p PostgresDB) AddIdempotentTask(ctx context.Context, task models.Task,
+	key models.IdempotencyKey) (models.IdempotencyKey, error) {
+	const op = "postgres.AddIdempotentTask"
+	requestID := ctx.Value(services.RequestIDKey).(string)
 
-	log := p.log.With(slog.String("op", op), slog.String("task_id", taskResult.ID))
+	log := p.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
 	log.DebugContext(ctx, "start operation")
 
-	stmt := `UPDATE tasks
-			SET status = $1,
-				status_code = $2,
-				headers = $3,
-				body = $4,
-				content_length = $5
-			WHERE id = $6`
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.IdempotencyKey{}, fmt.Errorf("%s request_id=%s failed to begin transaction: %v",
+			op, requestID, err)
+	}
+	defer func() { _ = tx.Rollback() }()
 
-	if _, err := p.db.ExecContext(ctx, stmt,
-		models.StatusDone,
-		taskResult.StatusCode,
-		taskResult.Headers,
-		taskResult.Body,
-		taskResult.ContentLength,
-		taskResult.ID,
-	); err != nil {
-		return fmt.Errorf("%s task_id=%s failed to update task status: %v", op, taskResult.ID, err)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = $1 AND expires_at <= now()`,
+		key.Key); err != nil {
+		return models.IdempotencyKey{}, fmt.Errorf("%s request_id=%s failed to delete expired idempotency key: %v",
+			op, requestID, err)
+	}
+
+	// Задача вставляется раньше ключа из-за внешнего ключа, при повторе транзакция откатывается вместе с ней.
+	if err := insertTask(ctx, tx, task, requestID); err != nil {
+		return models.IdempotencyKey{}, fmt.Errorf("%s request_id=%s %v", op, requestID, err)
+	}
+
+	stmt := `INSERT INTO idempotency_keys (key, fingerprint, task_id, expires_at)
+			VALUES($1, $2, $3, $4)
+			ON CONFLICT (key) DO NOTHING`
+
+	res, err := tx.ExecContext(ctx, stmt, key.Key, key.Fingerprint, task.ID, key.ExpiresAt)
+	if err != nil {
+		return models.IdempotencyKey{}, fmt.Errorf("%s request_id=%s failed to add idempotency key: %v",
+			op, requestID, err)
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return models.IdempotencyKey{}, fmt.Errorf("%s request_id=%s failed to add idempotency key: %v",
+			op, requestID, err)
+	}
+
+	if inserted == 0 {
+		stored := models.IdempotencyKey{Key: key.Key}
+		stmt := `SELECT fingerprint, task_id, expires_at FROM idempotency_keys WHERE key = $1`
+		if err := tx.QueryRowContext(ctx, stmt, key.Key).Scan(&stored.Fingerprint, &stored.TaskID,
+			&stored.ExpiresAt); err != nil {
+			return models.IdempotencyKey{}, fmt.Errorf("%s request_id=%s failed to get idempotency key: %v",
+				op, requestID, err)
+		}
+
+		log.DebugContext(ctx, "the operation was successfully completed, idempotency key already exists")
+
+		return stored, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.IdempotencyKey{}, fmt.Errorf("%s request_id=%s failed to commit transaction: %v",
+			op, requestID, err)
 	}
 
 	log.DebugContext(ctx, "the operation was successfully completed")
 
+	key.TaskID = task.ID
+
+	return key, nil
+}
+
+func (p PostgresDB) DeleteExpiredIdempotencyKeys(ctx context.Context, limit int) (int, error) {
+	const op = "postgres.DeleteExpiredIdempotencyKeys"
+
+	log := p.log.With(slog.String("op", op))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `DELETE FROM idempotency_keys
+			WHERE key IN (SELECT key FROM idempotency_keys WHERE expires_at <= now() LIMIT $1)`
+
+	res, err := p.db.ExecContext(ctx, stmt, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s failed to delete expired idempotency keys: %v", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s failed to get deleted idempotency keys count: %v", op, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed", slog.Int64("deleted", deleted))
+
+	return int(deleted), nil
+}
+
+func (p PostgresDB) AddTasks(ctx context.Context, tasks []models.Task) error {
+	const op = "postgres.AddTasks"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := p.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	inserts, err := taskBatchInserts(tasks, requestID)
+	if err != nil {
+		return fmt.Errorf("%s request_id=%s %v", op, requestID, err)
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s request_id=%s failed to begin transaction: %v", op, requestID, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, insert := range inserts {
+		if _, err := tx.ExecContext(ctx, insert.statement(), insert.args...); err != nil {
+			return fmt.Errorf("%s request_id=%s failed to add tasks to %s: %v", op, requestID, insert.table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s request_id=%s failed to commit transaction: %v", op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed", slog.Int("tasks", len(tasks)))
+
 	return nil
 }
 
-func (p PostgresDB) Close(_ context.Context) error {
-	return p.db.Close()
+type multiInsert struct {
+	table   string
+	columns []string
+	rows    []string
+	args    []any
+}
+
+func (m *multiInsert) add(values ...any) {
+	placeholders := make([]string, len(values))
+	for i := range placeholders {
+		placeholders[i] = "$" + strconv.Itoa(len(m.args)+i+1)
+	}
+
+	m.rows = append(m.rows, "("+strings.Join(placeholders, ", ")+")")
+	m.args = append(m.args, values...)
+}
+
+func (m *multiInsert) statement() string {
+	return "INSERT INTO " + m.table + " (" + strings.Join(m.columns, ", ") + ") VALUES " + strings.Join(m.rows, ", ")
+}
+
+func taskBatchInserts(tasks []models.Task, requestID string) ([]*multiInsert, error) {
+	taskRows := &multiInsert{table: "tasks",
+		columns: []string{"id", "status", "method", "url", "host", "callback_url", "callback_status", "run_at",
+			"request"}}
+	outboxRows := &multiInsert{table: "task_outbox", columns: []string{"task_id", "request_id", "payload"}}
+	scheduledRows := &multiInsert{table: "scheduled_tasks",
+		columns: []string{"task_id", "request_id", "payload", "run_at"}}
+
+	for _, task := range tasks {
+		payload, err := json.Marshal(task)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal task %s: %v", task.ID, err)
+		}
+
+		callbackStatus := models.CallbackNone
+		if task.CallbackURL != "" {
+			callbackStatus = models.CallbackPending
+		}
+
+		taskRows.add(task.ID, task.InitialStatus(), task.Method, task.URL, task.Host(), task.CallbackURL,
+			callbackStatus, task.RunAt, payload)
+		if task.RunAt != nil {
+			scheduledRows.add(task.ID, requestID, payload, task.RunAt)
+			continue
+		}
+		outboxRows.add(task.ID, requestID, payload)
+	}
+
+	inserts := make([]*multiInsert, 0, 3)
+	for _, insert := range []*multiInsert{taskRows, outboxRows, scheduledRows} {
+		if len(insert.rows) > 0 {
+			inserts = append(inserts, insert)
+		}
+	}
+
+	return inserts, nil
+}
+
+func insertTask(ctx context.Context, tx *sql.Tx, task models.Task, requestID string) error {
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %v", err)
+	}
+
+	stmt := `INSERT INTO tasks (id, status, method, url, host, callback_url, callback_status, run_at, request)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	callbackStatus := models.CallbackNone
+	if task.CallbackURL != "" {
+		callbackStatus = models.CallbackPending
+	}
+
+	if _, err := tx.ExecContext(ctx, stmt, task.ID, task.InitialStatus(), task.Method, task.URL,
+		task.Host(), task.CallbackURL, callbackStatus, task.RunAt, payload); err != nil {
+		return fmt.Errorf("failed to add new task: %v", err)
+	}
+
+	if task.RunAt != nil {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO scheduled_tasks (task_id, request_id, payload, run_at)
+				VALUES($1, $2, $3, $4)`, task.ID, requestID, payload, task.RunAt); err != nil {
+			return fmt.Errorf("failed to add scheduled task: %v", err)
+		}
+
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO task_outbox (task_id, request_id, payload) VALUES($1, $2, $3)`,
+		task.ID, requestID, payload); err != nil {
+		return fmt.Errorf("failed to add task to outbox: %v", err)
+	}
+
+	return nil
+}
+
+// PublishOutbox блокирует записи с SKIP LOCKED, поэтому relay можно запускать в нескольких экземплярах proxy.
+// Если транзакция не зафиксируется после публикации, задача будет опубликована повторно.
+func (p PostgresDB) PublishOutbox(ctx context.Context, limit, maxAttempts int,
+	publish func(ctx context.Context, msgs []models.OutboxMessage) []error) (int, error) {
+	const op = "postgres.PublishOutbox"
+
+	log := p.log.With(slog.String("op", op))
+	log.DebugContext(ctx, "start operation")
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s failed to begin transaction: %v", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt := `SELECT id, task_id, request_id, payload, attempts
+			FROM task_outbox
+			WHERE sent_at IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s failed to get outbox messages: %v", op, err)
+	}
+
+	var (
+		messages []outboxRow
+		invalid  = make(map[int64]error)
+	)
+	for rows.Next() {
+		var row outboxRow
+		if err := rows.Scan(&row.msg.ID, &row.taskID, &row.msg.RequestID, &row.payload, &row.attempts); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%s failed to scan outbox message: %v", op, err)
+		}
+
+		if err := json.Unmarshal(row.payload, &row.msg.Task); err != nil {
+			invalid[row.msg.ID] = fmt.Errorf("failed to unmarshal task: %v", err)
+		}
+		messages = append(messages, row)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s failed to get outbox messages: %v", op, err)
+	}
+
+	toPublish := make([]models.OutboxMessage, 0, len(messages))
+	for _, row := range messages {
+		if _, ok := invalid[row.msg.ID]; !ok {
+			toPublish = append(toPublish, row.msg)
+		}
+	}
+
+	failed := make(map[int64]error)
+	if len(toPublish) > 0 {
+		for i, errPublish := range publish(ctx, toPublish) {
+			if errPublish != nil {
+				failed[toPublish[i].ID] = errPublish
+			}
+		}
+	}
+
+	published, deadLettered := 0, 0
+	for _, row := range messages {
+		if errInvalid, ok := invalid[row.msg.ID]; ok {
+			if err := failOutboxMessage(ctx, tx, row, models.DeadLetterInvalidMessage, errInvalid); err != nil {
+				return 0, fmt.Errorf("%s %v", op, err)
+			}
+			deadLettered++
+			continue
+		}
+
+		if errPublish, ok := failed[row.msg.ID]; ok {
+			if row.attempts+1 >= maxAttempts {
+				if err := failOutboxMessage(ctx, tx, row, models.DeadLetterPublishFailed, errPublish); err != nil {
+					return 0, fmt.Errorf("%s %v", op, err)
+				}
+				deadLettered++
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, `UPDATE task_outbox SET attempts = attempts + 1, last_error = $1
+					WHERE id = $2`, errPublish.Error(), row.msg.ID); err != nil {
+				return 0, fmt.Errorf("%s failed to save outbox error: %v", op, err)
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM task_outbox WHERE id = $1`, row.msg.ID); err != nil {
+			return 0, fmt.Errorf("%s failed to delete sent outbox message: %v", op, err)
+		}
+		published++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s failed to commit transaction: %v", op, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed", slog.Int("published", published),
+		slog.Int("failed", len(failed)+len(invalid)), slog.Int("dead_lettered", deadLettered))
+
+	return published, nil
+}
+
+type outboxRow struct {
+	msg      models.OutboxMessage
+	taskID   string
+	payload  []byte
+	attempts int
+}
+
+func failOutboxMessage(ctx context.Context, tx *sql.Tx, row outboxRow, reason models.DeadLetterReason,
+	errPublish error) error {
+	payload := row.payload
+	if row.msg.Task.ID != "" {
+		task := row.msg.Task
+		task.CallbackSecret = ""
+		var err error
+		if payload, err = json.Marshal(task); err != nil {
+			return fmt.Errorf("failed to marshal task: %v", err)
+		}
+	}
+
+	deadLetter := models.NewDeadLetter(row.taskID, reason, errPublish.Error(), payload)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO dead_letters (id, task_id, reason, error_message, payload, created_at)
+			VALUES($1, $2, $3, $4, $5, $6)`, deadLetter.ID, deadLetter.TaskID, deadLetter.Reason,
+		deadLetter.ErrorMessage, deadLetter.Payload, deadLetter.CreatedAt); err != nil {
+		return fmt.Errorf("failed to add outbox message to dead letters: %v", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE tasks SET status = $1, error_message = $2, updated_at = now()
+			WHERE id = $3 AND status = $4`, models.StatusError, "failed to publish task: "+errPublish.Error(),
+		row.taskID, models.StatusNew); err != nil {
+		return fmt.Errorf("failed to fail task: %v", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_outbox WHERE id = $1`, row.msg.ID); err != nil {
+		return fmt.Errorf("failed to delete outbox message: %v", err)
+	}
+
+	return nil
+}
+
+// PromoteScheduledTasks удаляет строки scheduled_tasks в том же запросе, а SKIP LOCKED не даёт нескольким
+// экземплярам proxy забрать одну задачу, поэтому каждая задача попадает в outbox ровно один раз.
+func (p PostgresDB) PromoteScheduledTasks(ctx context.Context, limit int) (int, error) {
+	const op = "postgres.PromoteScheduledTasks"
+
+	log := p.log.With(slog.String("op", op))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `WITH due AS (
+				DELETE FROM scheduled_tasks
+				WHERE task_id IN (
+					SELECT task_id
+					FROM scheduled_tasks
+					WHERE run_at <= now()
+					ORDER BY run_at, task_id
+					LIMIT $1
+					FOR UPDATE SKIP LOCKED
+				)
+				RETURNING task_id, request_id, payload
+			), promoted AS (
+				UPDATE tasks
+				SET status = $2,
+					updated_at = now()
+				FROM due
+				WHERE tasks.id = due.task_id AND tasks.status = $3
+				RETURNING tasks.id
+			)
+			INSERT INTO task_outbox (task_id, request_id, payload)
+			SELECT due.task_id, due.request_id, due.payload
+			FROM due
+			JOIN promoted ON promoted.id = due.task_id`
+
+	res, err := p.db.ExecContext(ctx, stmt, limit, models.StatusNew, models.StatusScheduled)
+	if err != nil {
+		return 0, fmt.Errorf("%s failed to promote scheduled tasks: %v", op, err)
+	}
+
+	promoted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s failed to get promoted tasks count: %v", op, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed", slog.Int64("promoted", promoted))
+
+	return int(promoted), nil
+}
+
+func (p PostgresDB) UpdateTaskStatus(ctx context.Context, taskID string, newStatus models.TaskStatus) error {
+	const op = "postgres.UpdateTaskStatus"
+
+	log := p.log.With(slog.String("op", op), slog.String("task_id", taskID))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `UPDATE tasks
+			SET status = $1,
+				updated_at = now()
+			WHERE id = $2 AND status IN ($3, $4)`
+
+	res, err := p.db.ExecContext(ctx, stmt, string(newStatus), taskID, models.StatusNew, models.StatusInProcess)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to update task status: %v", op, taskID, err)
+	}
+
+	if err := checkTaskUpdated(res); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to update task status: %w", op, taskID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (p PostgresDB) AcquireTaskLease(ctx context.Context, lease models.TaskLease) error {
+	const op = "postgres.AcquireTaskLease"
+
+	log := p.log.With(slog.String("op", op), slog.String("task_id", lease.TaskID))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `UPDATE tasks
+			SET status = $1,
+				worker_id = $2,
+				attempt = $3,
+				lease_expires_at = now() + $4 * INTERVAL '1 millisecond',
+				updated_at = now()
+			WHERE id = $5 AND status IN ($6, $1)
+				AND (worker_id = '' OR lease_expires_at IS NULL OR lease_expires_at < now()
+					OR worker_id = $2 AND attempt < $3)`
+
+	res, err := p.db.ExecContext(ctx, stmt, models.StatusInProcess, lease.WorkerID, lease.Attempt,
+		lease.TTL.Milliseconds(), lease.TaskID, models.StatusNew)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to acquire task lease: %v", op, lease.TaskID, err)
+	}
+
+	if err := checkTaskUpdated(res); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to acquire task lease: %w", op, lease.TaskID,
+			p.notUpdatedError(ctx, lease.TaskID))
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (p PostgresDB) ExtendTaskLease(ctx context.Context, lease models.TaskLease) error {
+	const op = "postgres.ExtendTaskLease"
+
+	log := p.log.With(slog.String("op", op), slog.String("task_id", lease.TaskID))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `UPDATE tasks
+			SET lease_expires_at = now() + $1 * INTERVAL '1 millisecond'
+			WHERE id = $2 AND status = $3 AND worker_id = $4`
+
+	res, err := p.db.ExecContext(ctx, stmt, lease.TTL.Milliseconds(), lease.TaskID, models.StatusInProcess,
+		lease.WorkerID)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to extend task lease: %v", op, lease.TaskID, err)
+	}
+
+	if err := checkTaskUpdated(res); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to extend task lease: %w", op, lease.TaskID, storage.ErrTaskLeased)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (p PostgresDB) ReleaseTaskLease(ctx context.Context, lease models.TaskLease) error {
+	const op = "postgres.ReleaseTaskLease"
+
+	log := p.log.With(slog.String("op", op), slog.String("task_id", lease.TaskID))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `UPDATE tasks
+			SET worker_id = '',
+				lease_expires_at = now() + $1 * INTERVAL '1 millisecond',
+				updated_at = now()
+			WHERE id = $2 AND status = $3 AND worker_id = $4`
+
+	res, err := p.db.ExecContext(ctx, stmt, lease.TTL.Milliseconds(), lease.TaskID, models.StatusInProcess,
+		lease.WorkerID)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to release task lease: %v", op, lease.TaskID, err)
+	}
+
+	if err := checkTaskUpdated(res); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to release task lease: %w", op, lease.TaskID, storage.ErrTaskLeased)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+// ListExpiredTasks заполняет у задачи без сохранённого запроса только ID.
+func (p PostgresDB) ListExpiredTasks(ctx context.Context, limit int) ([]models.ExpiredTask, error) {
+	const op = "postgres.ListExpiredTasks"
+
+	log := p.log.With(slog.String("op", op))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `SELECT id, attempt, worker_id, request
+			FROM tasks
+			WHERE status = $1 AND lease_expires_at < now()
+			ORDER BY lease_expires_at
+			LIMIT $2`
+
+	rows, err := p.db.QueryContext(ctx, stmt, models.StatusInProcess, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to list expired tasks: %v", op, err)
+	}
+	defer rows.Close()
+
+	var expired []models.ExpiredTask
+	for rows.Next() {
+		var (
+			task    models.ExpiredTask
+			taskID  string
+			payload []byte
+		)
+		if err := rows.Scan(&taskID, &task.Attempt, &task.WorkerID, &payload); err != nil {
+			return nil, fmt.Errorf("%s failed to scan expired task: %v", op, err)
+		}
+
+		if payload != nil {
+			if err := json.Unmarshal(payload, &task.Task); err != nil {
+				log.WarnContext(ctx, "failed to unmarshal task request", slog.String("task_id", taskID),
+					slog.String("error", err.Error()))
+				task.Task = models.Task{}
+			}
+		}
+		task.Task.ID = taskID
+
+		expired = append(expired, task)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s failed to list expired tasks: %v", op, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return expired, nil
+}
+
+func (p PostgresDB) RequeueExpiredTask(ctx context.Context, task models.Task) error {
+	const op = "postgres.RequeueExpiredTask"
+
+	log := p.log.With(slog.String("op", op), slog.String("task_id", task.ID))
+	log.DebugContext(ctx, "start operation")
+
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to marshal task: %v", op, task.ID, err)
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to begin transaction: %v", op, task.ID, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt := `UPDATE tasks
+			SET status = $1,
+				worker_id = '',
+				lease_expires_at = NULL,
+				updated_at = now()
+			WHERE id = $2 AND status = $3 AND lease_expires_at < now()`
+
+	res, err := tx.ExecContext(ctx, stmt, models.StatusNew, task.ID, models.StatusInProcess)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to requeue task: %v", op, task.ID, err)
+	}
+
+	if err := checkTaskUpdated(res); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to requeue task: %w", op, task.ID, storage.ErrTaskLeased)
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO task_outbox (task_id, payload) VALUES($1, $2)`,
+		task.ID, payload); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to add task to outbox: %v", op, task.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to commit transaction: %v", op, task.ID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (p PostgresDB) FailExpiredTask(ctx context.Context, taskResult models.TaskResult) error {
+	const op = "postgres.FailExpiredTask"
+
+	log := p.log.With(slog.String("op", op), slog.String("task_id", taskResult.ID))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `UPDATE tasks
+			SET status = $1,
+				error_code = $2,
+				error_message = $3,
+				lease_expires_at = NULL,
+				updated_at = now()
+			WHERE id = $4 AND status = $5 AND lease_expires_at < now()`
+
+	res, err := p.db.ExecContext(ctx, stmt, taskResult.Status, taskResult.ErrorCode, taskResult.ErrorMessage,
+		taskResult.ID, models.StatusInProcess)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to fail expired task: %v", op, taskResult.ID, err)
+	}
+
+	if err := checkTaskUpdated(res); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to fail expired task: %w", op, taskResult.ID,
+			storage.ErrTaskLeased)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (p PostgresDB) UpdateTaskResult(ctx context.Context, lease models.TaskLease, taskResult models.TaskResult) error {
+	const op = "postgres.UpdateTaskResult"
+
+	log := p.log.With(slog.String("op", op), slog.String("task_id", taskResult.ID))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `UPDATE tasks
+			SET status = $1,
+				status_code = $2,
+				headers = $3,
+				body = $4,
+				body_encoding = $5,
+				body_ref = $6,
+				content_length = $7,
+				truncated = $8,
+				original_content_length = $9,
+				lease_expires_at = NULL,
+				updated_at = now()
+			WHERE id = $10 AND status = $11 AND worker_id = $12 AND attempt = $13`
+
+	body, err := taskResult.RawBody()
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to decode task body: %v", op, taskResult.ID, err)
+	}
+
+	res, err := p.db.ExecContext(ctx, stmt,
+		models.StatusDone,
+		taskResult.StatusCode,
+		taskResult.Headers,
+		body,
+		taskResult.BodyEncoding,
+		taskResult.BodyRef,
+		taskResult.ContentLength,
+		taskResult.Truncated,
+		taskResult.OriginalContentLength,
+		taskResult.ID,
+		models.StatusInProcess,
+		lease.WorkerID,
+		lease.Attempt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to update task status: %v", op, taskResult.ID, err)
+	}
+
+	if err := checkTaskUpdated(res); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to update task result: %w", op, taskResult.ID,
+			p.notUpdatedError(ctx, taskResult.ID))
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+// UpdateTaskError завершает выполняемую задачу с ошибкой и сохраняет её причину.
+func (p PostgresDB) UpdateTaskError(ctx context.Context, lease models.TaskLease, taskResult models.TaskResult) error {
+	const op = "postgres.UpdateTaskError"
+
+	log := p.log.With(slog.String("op", op), slog.String("task_id", taskResult.ID))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `UPDATE tasks
+			SET status = $1,
+				error_code = $2,
+				error_message = $3,
+				lease_expires_at = NULL,
+				updated_at = now()
+			WHERE id = $4 AND status = $5 AND worker_id = $6 AND attempt = $7`
+
+	res, err := p.db.ExecContext(ctx, stmt, taskResult.Status, taskResult.ErrorCode, taskResult.ErrorMessage,
+		taskResult.ID, models.StatusInProcess, lease.WorkerID, lease.Attempt)
+	if err != nil {
+		return fmt.Errorf("%s task_id=%s failed to update task error: %v", op, taskResult.ID, err)
+	}
+
+	if err := checkTaskUpdated(res); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to update task error: %w", op, taskResult.ID,
+			p.notUpdatedError(ctx, taskResult.ID))
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (p PostgresDB) UpdateCallbackStatus(ctx context.Context, taskID string, status models.CallbackStatus,
+	attempts int) error {
+	const op = "postgres.UpdateCallbackStatus"
+
+	log := p.log.With(slog.String("op", op), slog.String("task_id", taskID))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `UPDATE tasks
+			SET callback_status = $1,
+				callback_attempts = callback_attempts + $2,
+				updated_at = now()
+			WHERE id = $3`
+
+	if _, err := p.db.ExecContext(ctx, stmt, status, attempts, taskID); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to update callback status: %v", op, taskID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (p PostgresDB) AddTaskAttempt(ctx context.Context, attempt models.TaskAttempt) error {
+	const op = "postgres.AddTaskAttempt"
+
+	log := p.log.With(slog.String("op", op), slog.String("task_id", attempt.TaskID))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `INSERT INTO task_attempts (task_id, attempt, started_at, finished_at, status_code, error_code,
+				error_message, remote_addr)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	if _, err := p.db.ExecContext(ctx, stmt, attempt.TaskID, attempt.Attempt, attempt.StartedAt,
+		attempt.FinishedAt, attempt.StatusCode, attempt.ErrorCode, attempt.ErrorMessage,
+		attempt.RemoteAddr); err != nil {
+		return fmt.Errorf("%s task_id=%s failed to add task attempt: %v", op, attempt.TaskID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (p PostgresDB) GetTaskAttempts(ctx context.Context, taskID string) ([]models.TaskAttempt, error) {
+	const op = "postgres.GetTaskAttempts"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := p.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	var exists bool
+	if err := p.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1)`, taskID).
+		Scan(&exists); err != nil {
+		return nil, fmt.Errorf("%s request_id=%s failed to check task: %v", op, requestID, err)
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%s request_id=%s task not found: %w", op, requestID, storage.ErrTaskNotFound)
+	}
+
+	stmt := `SELECT task_id, attempt, started_at, finished_at, status_code, error_code, error_message, remote_addr
+			FROM task_attempts
+			WHERE task_id = $1
+			ORDER BY attempt, id`
+
+	rows, err := p.db.QueryContext(ctx, stmt, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("%s request_id=%s failed to get task attempts: %v", op, requestID, err)
+	}
+	defer rows.Close()
+
+	attempts := make([]models.TaskAttempt, 0)
+	for rows.Next() {
+		var (
+			attempt   models.TaskAttempt
+			errorCode string
+		)
+		if err := rows.Scan(
+			&attempt.TaskID,
+			&attempt.Attempt,
+			&attempt.StartedAt,
+			&attempt.FinishedAt,
+			&attempt.StatusCode,
+			&errorCode,
+			&attempt.ErrorMessage,
+			&attempt.RemoteAddr,
+		); err != nil {
+			return nil, fmt.Errorf("%s request_id=%s failed to scan task attempt: %v", op, requestID, err)
+		}
+
+		attempt.ErrorCode = models.ErrorCode(errorCode)
+		attempts = append(attempts, attempt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s request_id=%s failed to get task attempts: %v", op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return attempts, nil
+}
+
+func (p PostgresDB) CancelTask(ctx context.Context, taskID string) (models.TaskStatus, error) {
+	const op = "postgres.CancelTask"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := p.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `UPDATE tasks t
+			SET status = $1,
+				updated_at = now()
+			FROM (SELECT id, status FROM tasks WHERE id = $2 FOR UPDATE) prev
+			WHERE t.id = prev.id AND prev.status IN ($3, $4, $5)
+			RETURNING prev.status`
+
+	var prevStatus string
+	err := p.db.QueryRowContext(ctx, stmt, models.StatusCancelled, taskID, models.StatusNew, models.StatusInProcess,
+		models.StatusScheduled).Scan(&prevStatus)
+	if err == nil {
+		log.DebugContext(ctx, "the operation was successfully completed")
+		return models.TaskStatus(prevStatus), nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%s request_id=%s failed to cancel task: %v", op, requestID, err)
+	}
+
+	if err := p.db.QueryRowContext(ctx, `SELECT status FROM tasks WHERE id = $1`, taskID).
+		Scan(&prevStatus); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%s request_id=%s task not found: %w: %v",
+				op, requestID, storage.ErrTaskNotFound, err)
+		}
+
+		return "", fmt.Errorf("%s request_id=%s failed to get task status: %v", op, requestID, err)
+	}
+
+	return "", fmt.Errorf("%s request_id=%s task has status %s: %w",
+		op, requestID, prevStatus, storage.ErrTaskFinalized)
+}
+
+// AddDeadLetter пропускает уже сохранённую запись с тем же ID: брокер может доставить её повторно.
+func (p PostgresDB) AddDeadLetter(ctx context.Context, deadLetter models.DeadLetter) error {
+	const op = "postgres.AddDeadLetter"
+
+	log := p.log.With(slog.String("op", op), slog.String("dead_letter_id", deadLetter.ID))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `INSERT INTO dead_letters (id, task_id, reason, error_message, payload, created_at)
+			VALUES($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (id) DO NOTHING`
+
+	if _, err := p.db.ExecContext(ctx, stmt, deadLetter.ID, deadLetter.TaskID, deadLetter.Reason,
+		deadLetter.ErrorMessage, deadLetter.Payload, deadLetter.CreatedAt); err != nil {
+		return fmt.Errorf("%s dead_letter_id=%s failed to add dead letter: %v", op, deadLetter.ID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return nil
+}
+
+func (p PostgresDB) ListDeadLetters(ctx context.Context, filter models.DeadLetterFilter) (models.DeadLetterList,
+	error) {
+	const op = "postgres.ListDeadLetters"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := p.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	var (
+		conditions []string
+		args       []any
+	)
+	addCondition := func(condition string, values ...any) {
+		placeholders := make([]any, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	if filter.Reason != "" {
+		addCondition("reason = $%d", string(filter.Reason))
+	}
+	if filter.Replayed != nil {
+		if *filter.Replayed {
+			addCondition("replayed_at IS NOT NULL")
+		} else {
+			addCondition("replayed_at IS NULL")
+		}
+	}
+	if filter.Cursor != "" {
+		createdAt, id, err := storage.DecodeCursor(filter.Cursor)
+		if err != nil {
+			return models.DeadLetterList{}, fmt.Errorf("%s request_id=%s failed to decode cursor: %w: %v",
+				op, requestID, storage.ErrInvalidCursor, err)
+		}
+		addCondition("(created_at, id) < ($%d, $%d)", createdAt, id)
+	}
+
+	stmt := `SELECT id, task_id, reason, error_message, created_at, replayed_at, replay_count FROM dead_letters`
+	if len(conditions) > 0 {
+		stmt += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit+1)
+	stmt += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := p.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return models.DeadLetterList{}, fmt.Errorf("%s request_id=%s failed to list dead letters: %v",
+			op, requestID, err)
+	}
+	defer rows.Close()
+
+	deadLetterList := models.DeadLetterList{DeadLetters: make([]models.DeadLetter, 0, filter.Limit)}
+	for rows.Next() {
+		deadLetter, err := scanDeadLetter(rows, false)
+		if err != nil {
+			return models.DeadLetterList{}, fmt.Errorf("%s request_id=%s failed to scan dead letter: %v",
+				op, requestID, err)
+		}
+
+		deadLetterList.DeadLetters = append(deadLetterList.DeadLetters, deadLetter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.DeadLetterList{}, fmt.Errorf("%s request_id=%s failed to list dead letters: %v",
+			op, requestID, err)
+	}
+
+	if len(deadLetterList.DeadLetters) > filter.Limit {
+		deadLetterList.DeadLetters = deadLetterList.DeadLetters[:filter.Limit]
+		last := deadLetterList.DeadLetters[len(deadLetterList.DeadLetters)-1]
+		deadLetterList.NextCursor = storage.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return deadLetterList, nil
+}
+
+func (p PostgresDB) GetDeadLetter(ctx context.Context, id string) (models.DeadLetter, error) {
+	const op = "postgres.GetDeadLetter"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := p.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	stmt := `SELECT id, task_id, reason, error_message, created_at, replayed_at, replay_count, payload
+			FROM dead_letters
+			WHERE id = $1`
+
+	deadLetter, err := scanDeadLetter(p.db.QueryRowContext(ctx, stmt, id), true)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DeadLetter{}, fmt.Errorf("%s request_id=%s dead letter not found: %w",
+				op, requestID, storage.ErrDeadLetterNotFound)
+		}
+
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to get dead letter: %v", op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return deadLetter, nil
+}
+
+func (p PostgresDB) ReplayDeadLetter(ctx context.Context, id string) (models.DeadLetter, error) {
+	const op = "postgres.ReplayDeadLetter"
+	requestID := ctx.Value(services.RequestIDKey).(string)
+
+	log := p.log.With(slog.String("op", op), slog.String(services.RequestIDKey, requestID))
+	log.DebugContext(ctx, "start operation")
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to begin transaction: %v",
+			op, requestID, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt := `SELECT id, task_id, reason, error_message, created_at, replayed_at, replay_count, payload
+			FROM dead_letters
+			WHERE id = $1
+			FOR UPDATE`
+
+	deadLetter, err := scanDeadLetter(tx.QueryRowContext(ctx, stmt, id), true)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DeadLetter{}, fmt.Errorf("%s request_id=%s dead letter not found: %w",
+				op, requestID, storage.ErrDeadLetterNotFound)
+		}
+
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to get dead letter: %v", op, requestID, err)
+	}
+
+	if deadLetter.TaskID == "" {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s dead letter has no task: %w",
+			op, requestID, storage.ErrNotReplayable)
+	}
+
+	// Payload записи - копия для разбора без callback_secret, задача собирается из сохранённого запроса.
+	var request []byte
+	err = tx.QueryRowContext(ctx, `SELECT request FROM tasks WHERE id = $1 FOR UPDATE`, deadLetter.TaskID).
+		Scan(&request)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && request == nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s task or its request is missing: %w",
+			op, requestID, storage.ErrNotReplayable)
+	}
+	if err != nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to get task request: %v", op, requestID, err)
+	}
+
+	var task models.Task
+	if err := json.Unmarshal(request, &task); err != nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to unmarshal task request: %v",
+			op, requestID, err)
+	}
+	task.Attempt = 1
+	task.Replay++
+
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to marshal task: %v", op, requestID, err)
+	}
+
+	stmt = `UPDATE tasks
+			SET status = $1,
+				error_code = '',
+				error_message = '',
+				worker_id = '',
+				attempt = 0,
+				lease_expires_at = NULL,
+				callback_status = CASE WHEN callback_url = '' THEN $2 ELSE $3 END,
+				callback_attempts = 0,
+				request = $7,
+				updated_at = now()
+			WHERE id = $4 AND status IN ($1, $5, $6)`
+
+	res, err := tx.ExecContext(ctx, stmt, models.StatusNew, models.CallbackNone, models.CallbackPending, task.ID,
+		models.StatusDone, models.StatusError, payload)
+	if err != nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to reset task: %v", op, requestID, err)
+	}
+
+	if err := checkTaskUpdated(res); err != nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s task is missing or still active: %w",
+			op, requestID, storage.ErrNotReplayable)
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO task_outbox (task_id, request_id, payload) VALUES($1, $2, $3)`,
+		task.ID, requestID, payload); err != nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to add task to outbox: %v",
+			op, requestID, err)
+	}
+
+	stmt = `UPDATE dead_letters
+			SET replayed_at = now(), replay_count = replay_count + 1
+			WHERE id = $1
+			RETURNING replayed_at, replay_count`
+
+	if err := tx.QueryRowContext(ctx, stmt, id).Scan(&deadLetter.ReplayedAt, &deadLetter.ReplayCount); err != nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to mark dead letter as replayed: %v",
+			op, requestID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.DeadLetter{}, fmt.Errorf("%s request_id=%s failed to commit transaction: %v",
+			op, requestID, err)
+	}
+
+	log.DebugContext(ctx, "the operation was successfully completed")
+
+	return deadLetter, nil
+}
+
+func (p PostgresDB) Close(_ context.Context) error {
+	return p.db.Close()
+}
+
+// notUpdatedError отличает задачу, аренду которой держит другой requester, от уже завершённой.
+func (p PostgresDB) notUpdatedError(ctx context.Context, taskID string) error {
+	var status string
+	if err := p.db.QueryRowContext(ctx, `SELECT status FROM tasks WHERE id = $1`, taskID).
+		Scan(&status); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to get task status: %v", err)
+	}
+
+	if models.TaskStatus(status) == models.StatusInProcess {
+		return storage.ErrTaskLeased
+	}
+
+	return storage.ErrTaskFinalized
+}
+
+func checkTaskUpdated(res sql.Result) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %v", err)
+	}
+
+	if rows == 0 {
+		return storage.ErrTaskFinalized
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDeadLetter(row rowScanner, withPayload bool) (models.DeadLetter, error) {
+	var (
+		deadLetter models.DeadLetter
+		reason     string
+	)
+	dest := []any{
+		&deadLetter.ID,
+		&deadLetter.TaskID,
+		&reason,
+		&deadLetter.ErrorMessage,
+		&deadLetter.CreatedAt,
+		&deadLetter.ReplayedAt,
+		&deadLetter.ReplayCount,
+	}
+	if withPayload {
+		dest = append(dest, &deadLetter.Payload)
+	}
+
+	if err := row.Scan(dest...); err != nil {
+		return models.DeadLetter{}, err
+	}
+	deadLetter.Reason = models.DeadLetterReason(reason)
+
+	return deadLetter, nil
 }
@@ -0,0 +1,245 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ASsssker/proxy/internal/models"
+	"github.com/ASsssker/proxy/internal/services"
+	"github.com/ASsssker/proxy/internal/storage"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskBatchInserts(t *testing.T) {
+	runAt := time.Now().Add(time.Hour)
+	tasks := []models.Task{
+		{ID: uuid.NewString(), URL: "http://example.com/1", Method: "GET"},
+		{ID: uuid.NewString(), URL: "http://example.com/2", Method: "POST", RunAt: &runAt},
+		{ID: uuid.NewString(), URL: "http://example.com/3", Method: "GET", CallbackURL: "http://example.com/cb"},
+	}
+
+	inserts, err := taskBatchInserts(tasks, uuid.NewString())
+	require.NoError(t, err)
+	require.Len(t, inserts, 3)
+
+	rows := map[string]int{"tasks": 3, "task_outbox": 2, "scheduled_tasks": 1}
+	placeholder := regexp.MustCompile(`\$(\d+)`)
+	for _, insert := range inserts {
+		stmt := insert.statement()
+		require.Len(t, insert.rows, rows[insert.table], insert.table)
+		require.Len(t, insert.args, rows[insert.table]*len(insert.columns), insert.table)
+
+		// Каждая строка содержит по параметру на колонку, а параметры идут подряд без пропусков.
+		_, values, ok := strings.Cut(stmt, " VALUES ")
+		require.True(t, ok)
+		require.Contains(t, stmt, "("+strings.Join(insert.columns, ", ")+")")
+		for _, row := range strings.Split(values, "), (") {
+			require.Len(t, placeholder.FindAllString(row, -1), len(insert.columns), stmt)
+		}
+
+		matches := placeholder.FindAllStringSubmatch(stmt, -1)
+		require.Len(t, matches, len(insert.args))
+		for i, match := range matches {
+			require.Equal(t, strconv.Itoa(i+1), match[1])
+		}
+	}
+
+	inserts, err = taskBatchInserts(tasks[:1], uuid.NewString())
+	require.NoError(t, err)
+	require.Len(t, inserts, 2)
+}
+
+// newTestDB подключается к базе с применёнными миграциями, адрес которой передаётся в POSTGRES_TEST_DSN,
+// например после make migrations-up.
+func newTestDB(t *testing.T) (context.Context, *PostgresDB) {
+	t.Helper()
+
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	ctx := context.WithValue(context.Background(), services.RequestIDKey, uuid.NewString())
+	db, err := NewPostgresDB(ctx, slog.New(slog.DiscardHandler), dsn, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close(ctx) })
+
+	return ctx, db
+}
+
+func TestPostgresDB_PromoteScheduledTasksConcurrent(t *testing.T) {
+	ctx, db := newTestDB(t)
+
+	const tasksCount = 200
+	runAt := time.Now().Add(-time.Minute)
+	tasks := make([]models.Task, tasksCount)
+	ids := make([]string, tasksCount)
+	for i := range tasks {
+		tasks[i] = models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET", RunAt: &runAt}
+		ids[i] = tasks[i].ID
+	}
+	require.NoError(t, db.AddTasks(ctx, tasks))
+	t.Cleanup(func() {
+		_, _ = db.db.ExecContext(context.Background(), `DELETE FROM tasks WHERE id = ANY($1::UUID[])`, pq.Array(ids))
+	})
+
+	// Несколько экземпляров proxy переносят задачи одновременно небольшими пачками.
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		promoted int
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				count, err := db.PromoteScheduledTasks(ctx, 7)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if count == 0 {
+					return
+				}
+
+				mu.Lock()
+				promoted += count
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var outboxRows, distinctTasks int
+	require.NoError(t, db.db.QueryRowContext(ctx, `SELECT count(*), count(DISTINCT task_id) FROM task_outbox
+			WHERE task_id = ANY($1::UUID[])`, pq.Array(ids)).Scan(&outboxRows, &distinctTasks))
+	require.GreaterOrEqual(t, promoted, tasksCount)
+	require.Equal(t, tasksCount, outboxRows)
+	require.Equal(t, tasksCount, distinctTasks)
+
+	for _, id := range ids[:10] {
+		taskResult, err := db.GetTask(ctx, id, true)
+		require.NoError(t, err)
+		require.Equal(t, models.StatusNew, taskResult.Status)
+	}
+}
+
+func TestPostgresDB_ListExpiredTasksAfterPublish(t *testing.T) {
+	ctx, db := newTestDB(t)
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "POST", Body: "body"}
+	require.NoError(t, db.AddTask(ctx, task))
+	t.Cleanup(func() {
+		_, _ = db.db.ExecContext(context.Background(), `DELETE FROM tasks WHERE id = $1`, task.ID)
+	})
+
+	// Опубликованная запись outbox удаляется, запрос задачи для reaper должен остаться в самой задаче.
+	_, err := db.db.ExecContext(ctx, `DELETE FROM task_outbox WHERE task_id = $1`, task.ID)
+	require.NoError(t, err)
+
+	lease := models.TaskLease{TaskID: task.ID, WorkerID: "lost-worker", Attempt: 1, TTL: time.Minute}
+	require.NoError(t, db.AcquireTaskLease(ctx, lease))
+	_, err = db.db.ExecContext(ctx, `UPDATE tasks SET lease_expires_at = now() - INTERVAL '1 second' WHERE id = $1`,
+		task.ID)
+	require.NoError(t, err)
+
+	expired, err := db.ListExpiredTasks(ctx, 1000)
+	require.NoError(t, err)
+
+	idx := slices.IndexFunc(expired, func(e models.ExpiredTask) bool { return e.Task.ID == task.ID })
+	require.NotEqual(t, -1, idx)
+	require.Equal(t, task.URL, expired[idx].Task.URL)
+	require.Equal(t, task.Method, expired[idx].Task.Method)
+	require.Equal(t, task.Body, expired[idx].Task.Body)
+	require.Equal(t, "lost-worker", expired[idx].WorkerID)
+}
+
+func TestPostgresDB_ReplayDeadLetterTwice(t *testing.T) {
+	ctx, db := newTestDB(t)
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET",
+		CallbackURL: "http://example.com/callback", CallbackSecret: "task-secret"}
+	require.NoError(t, db.AddTask(ctx, task))
+	t.Cleanup(func() {
+		_, _ = db.db.ExecContext(context.Background(), `DELETE FROM dead_letters WHERE task_id = $1`, task.ID)
+		_, _ = db.db.ExecContext(context.Background(), `DELETE FROM tasks WHERE id = $1`, task.ID)
+	})
+
+	redacted := task
+	redacted.CallbackSecret = ""
+	payload, err := json.Marshal(redacted)
+	require.NoError(t, err)
+	deadLetter := models.NewDeadLetter(task.ID, models.DeadLetterRetriesExhausted, "connection refused", payload)
+	require.NoError(t, db.AddDeadLetter(ctx, deadLetter))
+
+	for range 2 {
+		_, err := db.db.ExecContext(ctx, `UPDATE tasks SET status = $1 WHERE id = $2`, models.StatusError, task.ID)
+		require.NoError(t, err)
+		_, err = db.ReplayDeadLetter(ctx, deadLetter.ID)
+		require.NoError(t, err)
+	}
+
+	rows, err := db.db.QueryContext(ctx, `SELECT payload FROM task_outbox WHERE task_id = $1 ORDER BY id`, task.ID)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var replayed []models.Task
+	for rows.Next() {
+		var payload []byte
+		require.NoError(t, rows.Scan(&payload))
+
+		var outboxTask models.Task
+		require.NoError(t, json.Unmarshal(payload, &outboxTask))
+		replayed = append(replayed, outboxTask)
+	}
+	require.NoError(t, rows.Err())
+
+	// Первая запись outbox - исходная публикация задачи.
+	require.Len(t, replayed, 3)
+	for i, outboxTask := range replayed[1:] {
+		require.Equal(t, i+1, outboxTask.Replay)
+		require.Equal(t, 1, outboxTask.Attempt)
+		require.Equal(t, task.CallbackSecret, outboxTask.CallbackSecret)
+	}
+}
+
+func TestPostgresDB_TaskLeaseFencing(t *testing.T) {
+	ctx, db := newTestDB(t)
+
+	task := models.Task{ID: uuid.NewString(), URL: "http://example.com", Method: "GET"}
+	require.NoError(t, db.AddTask(ctx, task))
+	t.Cleanup(func() {
+		_, _ = db.db.ExecContext(context.Background(), `DELETE FROM tasks WHERE id = $1`, task.ID)
+	})
+
+	lease := models.TaskLease{TaskID: task.ID, WorkerID: "worker-1", Attempt: 1, TTL: time.Minute}
+	require.NoError(t, db.AcquireTaskLease(ctx, lease))
+	require.ErrorIs(t, db.AcquireTaskLease(ctx, lease), storage.ErrTaskLeased)
+
+	_, err := db.db.ExecContext(ctx, `UPDATE tasks SET lease_expires_at = now() - INTERVAL '1 second' WHERE id = $1`,
+		task.ID)
+	require.NoError(t, err)
+
+	otherLease := models.TaskLease{TaskID: task.ID, WorkerID: "worker-2", Attempt: 2, TTL: time.Minute}
+	require.NoError(t, db.AcquireTaskLease(ctx, otherLease))
+
+	err = db.UpdateTaskResult(ctx, lease, models.TaskResult{ID: task.ID, StatusCode: 200})
+	require.ErrorIs(t, err, storage.ErrTaskLeased)
+	err = db.UpdateTaskError(ctx, lease, models.TaskResult{ID: task.ID, Status: models.StatusError,
+		ErrorCode: models.ErrorConnection})
+	require.ErrorIs(t, err, storage.ErrTaskLeased)
+
+	require.NoError(t, db.UpdateTaskResult(ctx, otherLease, models.TaskResult{ID: task.ID, StatusCode: 200}))
+}
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+)
+
+type bytesReader struct {
+	*bytes.Reader
+}
+
+func (bytesReader) Close() error {
+	return nil
+}
+
+// NewBytesReader оборачивает тело, целиком прочитанное из хранилища, в io.ReadSeekCloser.
+func NewBytesReader(data []byte) io.ReadSeekCloser {
+	return bytesReader{Reader: bytes.NewReader(data)}
+}
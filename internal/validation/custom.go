@@ -1,15 +1,22 @@
 package validation
 
 import (
+	"net/http"
 	"strings"
 
+	"github.com/ASsssker/proxy/internal/models"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
 func validateMethod(fl validator.FieldLevel) bool {
-	method := strings.ToUpper(fl.Field().String())
-	return method == "GET" || method == "POST"
+	switch strings.ToUpper(fl.Field().String()) {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+		http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
 }
 
 func validateUUID(fl validator.FieldLevel) bool {
@@ -17,3 +24,23 @@ func validateUUID(fl validator.FieldLevel) bool {
 	_, err := uuid.Parse(value)
 	return err == nil
 }
+
+func validateTaskStatus(fl validator.FieldLevel) bool {
+	switch models.TaskStatus(fl.Field().String()) {
+	case models.StatusDone, models.StatusInProcess, models.StatusError, models.StatusNew, models.StatusCancelled,
+		models.StatusBlocked, models.StatusScheduled:
+		return true
+	default:
+		return false
+	}
+}
+
+func validateDeadLetterReason(fl validator.FieldLevel) bool {
+	switch models.DeadLetterReason(fl.Field().String()) {
+	case models.DeadLetterRetriesExhausted, models.DeadLetterWorkerLost, models.DeadLetterMaxDeliveries,
+		models.DeadLetterInvalidMessage, models.DeadLetterPublishFailed:
+		return true
+	default:
+		return false
+	}
+}
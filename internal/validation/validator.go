@@ -10,5 +10,11 @@ func NewValidator() (*validator.Validate, error) {
 	if err := v.RegisterValidation("uuid", validateUUID); err != nil {
 		return nil, err
 	}
+	if err := v.RegisterValidation("taskstatus", validateTaskStatus); err != nil {
+		return nil, err
+	}
+	if err := v.RegisterValidation("deadletterreason", validateDeadLetterReason); err != nil {
+		return nil, err
+	}
 	return v, nil
 }
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tasks
+    ADD COLUMN method TEXT NOT NULL DEFAULT '',
+    ADD COLUMN url TEXT NOT NULL DEFAULT '',
+    ADD COLUMN host TEXT NOT NULL DEFAULT '',
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS tasks_created_at_idx ON tasks (created_at, id);
+CREATE INDEX IF NOT EXISTS tasks_status_created_at_idx ON tasks (status, created_at, id);
+CREATE INDEX IF NOT EXISTS tasks_host_created_at_idx ON tasks (host, created_at, id);
+CREATE INDEX IF NOT EXISTS tasks_method_created_at_idx ON tasks (method, created_at, id);
+CREATE INDEX IF NOT EXISTS tasks_status_code_created_at_idx ON tasks (status_code, created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS tasks_status_code_created_at_idx;
+DROP INDEX IF EXISTS tasks_method_created_at_idx;
+DROP INDEX IF EXISTS tasks_host_created_at_idx;
+DROP INDEX IF EXISTS tasks_status_created_at_idx;
+DROP INDEX IF EXISTS tasks_created_at_idx;
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS host,
+    DROP COLUMN IF EXISTS url,
+    DROP COLUMN IF EXISTS method;
+-- +goose StatementEnd
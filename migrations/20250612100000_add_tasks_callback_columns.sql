@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tasks
+    ADD COLUMN callback_url TEXT NOT NULL DEFAULT '',
+    ADD COLUMN callback_status TEXT NOT NULL DEFAULT 'none',
+    ADD COLUMN callback_attempts INT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS callback_attempts,
+    DROP COLUMN IF EXISTS callback_status,
+    DROP COLUMN IF EXISTS callback_url;
+-- +goose StatementEnd
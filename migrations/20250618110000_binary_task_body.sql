@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tasks ADD COLUMN body_encoding TEXT NOT NULL DEFAULT 'text';
+
+ALTER TABLE tasks ALTER COLUMN body DROP DEFAULT;
+ALTER TABLE tasks ALTER COLUMN body TYPE BYTEA USING convert_to(body, 'UTF8');
+ALTER TABLE tasks ALTER COLUMN body SET DEFAULT ''::BYTEA;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE tasks ALTER COLUMN body DROP DEFAULT;
+ALTER TABLE tasks ALTER COLUMN body TYPE TEXT USING
+    CASE
+        WHEN body_encoding = 'base64' THEN encode(body, 'base64')
+        ELSE convert_from(body, 'UTF8')
+    END;
+ALTER TABLE tasks ALTER COLUMN body SET DEFAULT '';
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS body_encoding;
+-- +goose StatementEnd
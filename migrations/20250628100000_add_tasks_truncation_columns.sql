@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tasks
+    ADD COLUMN truncated BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN original_content_length BIGINT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS original_content_length,
+    DROP COLUMN IF EXISTS truncated;
+-- +goose StatementEnd
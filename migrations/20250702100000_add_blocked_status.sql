@@ -0,0 +1,10 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+ALTER TYPE statuses ADD VALUE IF NOT EXISTS 'blocked';
+
+-- +goose Down
+UPDATE tasks SET status = 'error' WHERE status = 'blocked';
+ALTER TYPE statuses RENAME TO statuses_old;
+CREATE TYPE statuses AS ENUM('done', 'in process', 'error', 'new', 'cancelled');
+ALTER TABLE tasks ALTER COLUMN status TYPE statuses USING status::TEXT::statuses;
+DROP TYPE statuses_old;
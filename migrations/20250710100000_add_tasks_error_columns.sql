@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tasks
+    ADD COLUMN error_code TEXT NOT NULL DEFAULT '',
+    ADD COLUMN error_message TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS error_message,
+    DROP COLUMN IF EXISTS error_code;
+-- +goose StatementEnd
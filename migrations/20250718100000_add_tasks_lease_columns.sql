@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS worker_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS attempt INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMPTZ;
+-- Задачи, выполнявшиеся до появления аренды, получают время на завершение, после чего их подберёт reaper.
+UPDATE tasks SET lease_expires_at = now() + INTERVAL '5 minutes' WHERE status = 'in process';
+CREATE INDEX IF NOT EXISTS tasks_lease_expires_at_idx ON tasks (lease_expires_at) WHERE status = 'in process';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS tasks_lease_expires_at_idx;
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS lease_expires_at,
+    DROP COLUMN IF EXISTS attempt,
+    DROP COLUMN IF EXISTS worker_id;
+-- +goose StatementEnd
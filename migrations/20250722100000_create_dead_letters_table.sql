@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id UUID PRIMARY KEY,
+    task_id TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL,
+    error_message TEXT NOT NULL DEFAULT '',
+    payload BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    replayed_at TIMESTAMPTZ,
+    replay_count INT NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS dead_letters_created_at_idx ON dead_letters (created_at, id);
+CREATE INDEX IF NOT EXISTS dead_letters_reason_created_at_idx ON dead_letters (reason, created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS dead_letters;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+ALTER TYPE statuses ADD VALUE IF NOT EXISTS 'scheduled';
+
+-- +goose Down
+UPDATE tasks SET status = 'cancelled' WHERE status = 'scheduled';
+-- Условие частичного индекса ссылается на старый тип и не даёт сменить тип колонки.
+DROP INDEX IF EXISTS tasks_lease_expires_at_idx;
+ALTER TYPE statuses RENAME TO statuses_old;
+CREATE TYPE statuses AS ENUM('done', 'in process', 'error', 'new', 'cancelled', 'blocked');
+ALTER TABLE tasks ALTER COLUMN status TYPE statuses USING status::TEXT::statuses;
+DROP TYPE statuses_old;
+CREATE INDEX IF NOT EXISTS tasks_lease_expires_at_idx ON tasks (lease_expires_at) WHERE status = 'in process';
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tasks ADD COLUMN run_at TIMESTAMPTZ;
+
+-- scheduled_tasks хранит сообщения отложенных задач до run_at, после чего scheduler переносит их в task_outbox.
+CREATE TABLE IF NOT EXISTS scheduled_tasks (
+    task_id UUID PRIMARY KEY REFERENCES tasks (id) ON DELETE CASCADE,
+    request_id TEXT NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    run_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS scheduled_tasks_run_at_idx ON scheduled_tasks (run_at, task_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS scheduled_tasks;
+ALTER TABLE tasks DROP COLUMN IF EXISTS run_at;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS request JSONB;
+-- Relay удаляет опубликованные записи outbox, поэтому запрос задачи для reaper хранится в самой задаче.
+UPDATE tasks t
+SET request = COALESCE(
+    (SELECT payload FROM task_outbox o WHERE o.task_id = t.id ORDER BY o.id DESC LIMIT 1),
+    (SELECT payload FROM scheduled_tasks s WHERE s.task_id = t.id)
+);
+DELETE FROM task_outbox WHERE sent_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+INSERT INTO task_outbox (task_id, payload, sent_at)
+SELECT id, request, now()
+FROM tasks t
+WHERE request IS NOT NULL AND NOT EXISTS (SELECT 1 FROM task_outbox o WHERE o.task_id = t.id);
+ALTER TABLE tasks DROP COLUMN IF EXISTS request;
+-- +goose StatementEnd